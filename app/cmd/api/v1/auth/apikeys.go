@@ -0,0 +1,290 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mitchs-dev/library-go/networking"
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiKeyRequest is the request body for the API key actions
+type apiKeyRequest struct {
+	Database   string   `json:"database" yaml:"database"`
+	ID         string   `json:"id" yaml:"id"`
+	Name       string   `json:"name" yaml:"name"`
+	UserID     string   `json:"userID" yaml:"userID"`
+	Roles      []string `json:"roles" yaml:"roles"`
+	AllowedIPs []string `json:"allowedIPs" yaml:"allowedIPs"`
+	ExpiresAt  string   `json:"expiresAt" yaml:"expiresAt"`
+}
+
+// APIKeyCreate creates an API key for the user (Or for another user when requested by an admin)
+// The secret is only returned in the response as just its hash is stored
+func APIKeyCreate(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	c := configuration.Current()
+	if !c.Session.APIKeys.Enabled {
+		respondWithAPIKeyError(r, w, errors.New(globals.ErrorAPIKeysDisabled), "Failed to create API key", correlationID)
+		return
+	}
+	// A key could otherwise be used to create keys which outlive it
+	if authPkg.IsAPIKey(r.Header.Get(globals.AuthenticationAuthorizationHeader)) {
+		respondWithAPIKeyError(r, w, errors.New(globals.ErrorPermissionDenied+": API keys can not be created with an API key"), "Failed to create API key", correlationID)
+		return
+	}
+	request, wrapper, ok := openAPIKeyRequest(r, w, correlationID)
+	if !ok {
+		return
+	}
+	defer wrapper.Release()
+	if request.UserID != "" && request.UserID != userID {
+		permissions, err := authPkg.UserPermissions(request.Database, userID, r.Header.Get(globals.AuthenticationAuthorizationHeader))
+		if err != nil {
+			respondWithAPIKeyError(r, w, err, "Failed to create API key", correlationID)
+			return
+		}
+		if !permissions.Admin() {
+			respondWithAPIKeyError(r, w, errors.New(globals.ErrorPermissionDenied+": only admins may create API keys for other users"), "Failed to create API key", correlationID)
+			return
+		}
+	} else {
+		request.UserID = userID
+	}
+
+	key, err := newAPIKey(request, time.Now().UTC())
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to create API key", correlationID)
+		return
+	}
+	userRoles, exists, err := authPkg.UserRoles(request.Database, key.UserID)
+	if err == nil && !exists {
+		err = errors.New(globals.ErrorInvalidAPIKey + ": user (" + key.UserID + ") does not exist")
+	}
+	if err == nil {
+		err = authPkg.ValidAPIKeyRoles(key.Roles, userRoles)
+	}
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to create API key", correlationID)
+		return
+	}
+	id, secret, secretHash, err := authPkg.GenerateAPIKey()
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to create API key", correlationID)
+		return
+	}
+	key.ID = id
+	err = wrapper.CreateAPIKey(key, secretHash, userID)
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to create API key", correlationID)
+		return
+	}
+	log.Info("Created API key: " + request.Database + "/" + key.ID + " for user: " + key.UserID + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+	w.WriteHeader(http.StatusCreated)
+	response := globals.Response{
+		Status:  "success",
+		Message: "API key (" + key.ID + ") created - Store the key as it can not be shown again",
+		Data:    map[string]interface{}{"correlationID": correlationID, "apiKey": key, "key": key.ID + globals.APIKeySeparator + secret},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
+
+// APIKeyList lists the API keys of the user - Admins list the keys of every user (Or only of the requested user)
+func APIKeyList(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	if database == "" {
+		respondWithAPIKeyError(r, w, errors.New(globals.ErrorInvalidAPIKey+": database name is required"), "Failed to list API keys", correlationID)
+		return
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+	ownerID, err := requestOwner(r, database, userID, r.URL.Query().Get("userID"))
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to list API keys", correlationID)
+		return
+	}
+	keys, err := wrapper.APIKeys(ownerID)
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to list API keys", correlationID)
+		return
+	}
+	response := globals.Response{
+		Status:  "success",
+		Message: "QUERY_SUCCESS",
+		Data:    map[string]interface{}{"correlationID": correlationID, "apiKeys": keys},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Debug("Listed API keys for database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+}
+
+// APIKeyRevoke revokes an API key of the user - Admins may revoke the keys of every user
+func APIKeyRevoke(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	request, wrapper, ok := openAPIKeyRequest(r, w, correlationID)
+	if !ok {
+		return
+	}
+	defer wrapper.Release()
+	if request.ID == "" {
+		respondWithAPIKeyError(r, w, errors.New(globals.ErrorInvalidAPIKey+": id of the API key is required"), "Failed to revoke API key", correlationID)
+		return
+	}
+	ownerID, err := requestOwner(r, request.Database, userID, "")
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to revoke API key", correlationID)
+		return
+	}
+	key, err := wrapper.RevokeAPIKey(request.ID, ownerID, userID)
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to revoke API key ("+request.ID+")", correlationID)
+		return
+	}
+	log.Info("Revoked API key: " + request.Database + "/" + key.ID + " of user: " + key.UserID + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+	response := globals.Response{
+		Status:  "success",
+		Message: "API key (" + key.ID + ") revoked",
+		Data:    map[string]interface{}{"correlationID": correlationID, "apiKey": key},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
+
+// newAPIKey validates the request and returns the API key which is created from it
+// Keys must expire within the max lifetime of API keys when it is configured
+func newAPIKey(request apiKeyRequest, now time.Time) (sqlWrapper.APIKey, error) {
+	c := configuration.Current()
+	key := sqlWrapper.APIKey{
+		UserID:     request.UserID,
+		Name:       strings.TrimSpace(request.Name),
+		Roles:      request.Roles,
+		AllowedIPs: request.AllowedIPs,
+		CreatedAt:  now.Format(time.RFC3339),
+	}
+	if key.Roles == nil {
+		key.Roles = []string{}
+	}
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+	err := authPkg.ValidAPIKeyAddresses(key.AllowedIPs)
+	if err != nil {
+		return key, err
+	}
+	var maxLifetime time.Duration
+	if c.Session.APIKeys.MaxLifetime != "" {
+		maxLifetime, err = time.ParseDuration(c.Session.APIKeys.MaxLifetime)
+		if err != nil {
+			return key, err
+		}
+	}
+	if request.ExpiresAt == "" {
+		if maxLifetime > 0 {
+			return key, errors.New(globals.ErrorInvalidAPIKey + ": expiresAt is required as keys may not be valid for longer than " + c.Session.APIKeys.MaxLifetime)
+		}
+		return key, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, request.ExpiresAt)
+	if err != nil {
+		return key, errors.New(globals.ErrorInvalidAPIKey + ": expiresAt (" + request.ExpiresAt + ") must be an RFC3339 timestamp (Ex: 2030-01-01T00:00:00Z)")
+	}
+	if !expiresAt.After(now) {
+		return key, errors.New(globals.ErrorInvalidAPIKey + ": expiresAt (" + request.ExpiresAt + ") must be in the future")
+	}
+	if maxLifetime > 0 && expiresAt.Sub(now) > maxLifetime {
+		return key, errors.New(globals.ErrorInvalidAPIKey + ": expiresAt (" + request.ExpiresAt + ") is further away than the max lifetime of keys (" + c.Session.APIKeys.MaxLifetime + ")")
+	}
+	key.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	return key, nil
+}
+
+// requestOwner returns the user whose API keys or sessions the request may see - Empty is returned for admins which did not ask for a user
+func requestOwner(r *http.Request, database, userID, requestedUserID string) (string, error) {
+	permissions, err := authPkg.UserPermissions(database, userID, r.Header.Get(globals.AuthenticationAuthorizationHeader))
+	if err != nil {
+		return "", err
+	}
+	if permissions.Admin() {
+		return requestedUserID, nil
+	}
+	return userID, nil
+}
+
+// openAPIKeyRequest decodes the body of an API key request and opens its database - The caller releases the database once it is done with it
+// The database of the query string takes precedence over the body as it is the one the request was authenticated against
+func openAPIKeyRequest(r *http.Request, w http.ResponseWriter, correlationID string) (apiKeyRequest, *sqlWrapper.SQLiteWrapper, bool) {
+	var request apiKeyRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Error("Failed to unmarshal request body: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		respondWithAPIKeyError(r, w, errors.New(globals.ErrorInvalidAPIKey+": ensure that the request body is in the valid API key format"), "Invalid request body", correlationID)
+		return request, nil, false
+	}
+	if r.URL.Query().Get("database") != "" {
+		request.Database = r.URL.Query().Get("database")
+	}
+	if request.Database == "" {
+		respondWithAPIKeyError(r, w, errors.New(globals.ErrorInvalidAPIKey+": database name is required"), "Invalid request body", correlationID)
+		return request, nil, false
+	}
+	wrapper, err := sqlWrapper.Open(request.Database)
+	if err != nil {
+		respondWithAPIKeyError(r, w, err, "Failed to open database", correlationID)
+		return request, nil, false
+	}
+	return request, wrapper, true
+}
+
+// respondWithAPIKeyError maps an API key error to a response status code and writes the response
+func respondWithAPIKeyError(r *http.Request, w http.ResponseWriter, err error, message, correlationID string) {
+	status := http.StatusInternalServerError
+	responseMessage := "INTERNAL_SERVER_ERROR"
+	switch {
+	case strings.HasPrefix(err.Error(), globals.ErrorAPIKeyNotFound):
+		status = http.StatusNotFound
+		responseMessage = message + " - The API key does not exist"
+	case strings.HasPrefix(err.Error(), globals.ErrorAPIKeysDisabled):
+		status = http.StatusForbidden
+		responseMessage = message + " - API keys are disabled on this server"
+	case strings.HasPrefix(err.Error(), globals.ErrorPermissionDenied):
+		status = http.StatusForbidden
+		responseMessage = message + " - " + err.Error()
+	case strings.HasPrefix(err.Error(), globals.ErrorInvalidAPIKey):
+		status = http.StatusBadRequest
+		responseMessage = message + " - " + err.Error()
+	case strings.Contains(err.Error(), globals.ErrorNotExist):
+		status = http.StatusNotFound
+		responseMessage = message + " - The database does not exist"
+	}
+	if status == http.StatusInternalServerError {
+		log.Error(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	} else {
+		log.Warn(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "error",
+		Message: responseMessage,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
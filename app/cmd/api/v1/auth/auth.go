@@ -1,19 +1,64 @@
 package auth
 
 import (
-	"crypto/sha256"
-	"database/sql"
-	"encoding/hex"
+	"errors"
 	"fmt"
 
-	"github.com/mitchs-dev/library-go/processor"
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
 	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
 )
 
-func validateSystemRoles(rolesAsInterface interface{}) bool {
+// userWhere builds the WHERE clause for the users table from the request body
+// The id, name and roles fields are combined with the filter tree of the request
+func userWhere(request *authPkg.AuthRequestBody) (string, []interface{}, error) {
+	var nodes []*filter.Node
+	if request.Data.ID != "" {
+		nodes = append(nodes, filter.Equal(globals.UserEntryIDColumnName, request.Data.ID))
+	}
+	if request.Data.Name != "" {
+		if strings.Contains(request.Data.Name, "*") {
+			nodes = append(nodes, &filter.Node{Column: globals.UserNameColumnName, Op: globals.FilterOperatorLike, Value: strings.ReplaceAll(request.Data.Name, "*", "%")})
+		} else {
+			nodes = append(nodes, filter.Equal(globals.UserNameColumnName, request.Data.Name))
+		}
+	}
+	// Passwords are stored as salted hashes so they can not be matched in a query
+	if request.Data.Password != "" {
+		return "", nil, errors.New(globals.ErrorInvalidFilter + ": " + globals.UserPasswordColumnName + " can not be used as a filter")
+	}
+	for _, role := range request.Data.Roles {
+		role = strings.Trim(role, "*%")
+		if role == "" {
+			continue
+		}
+		nodes = append(nodes, &filter.Node{Column: globals.UserRolesColumnName, Op: globals.FilterOperatorLike, Value: "%" + role + "%"})
+	}
+	requestFilter, err := filter.Parse(request.Filter)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, column := range filter.Columns(requestFilter) {
+		if column == globals.UserPasswordColumnName {
+			return "", nil, errors.New(globals.ErrorInvalidFilter + ": " + globals.UserPasswordColumnName + " can not be used as a filter")
+		}
+	}
+	nodes = append(nodes, requestFilter)
+	return filter.Where(filter.And(nodes...))
+}
+
+// adminCountQuery returns the query and arguments used to count the system admin users
+func adminCountQuery() (string, []interface{}) {
+	return "SELECT COUNT(*) FROM " + globals.UsersTable + " WHERE " + globals.UserRolesColumnName + " LIKE ?", []interface{}{"%" + globals.RolesSystemAdmin + "%"}
+}
+
+// validateRoles checks that every role is a system role or a custom role of the database
+func validateRoles(wrapper *sqlWrapper.SQLiteWrapper, rolesAsInterface interface{}) bool {
 	log.Debug("Role data: ", rolesAsInterface)
 	log.Debug("Type of rolesAsInterface: ", fmt.Sprintf("%T", rolesAsInterface))
 
@@ -32,117 +77,34 @@ func validateSystemRoles(rolesAsInterface interface{}) bool {
 		}
 		roles = append(roles, roleStr)
 	}
+	if len(roles) == 0 {
+		log.Error("No roles seem to be specified for role validation")
+		return false
+	}
 
 	for _, role := range roles {
-		if role != globals.RolesSystemAdmin && role != globals.RolesSystemUser && role != globals.RolesSystemReadOnly {
-			log.Debug("Role: " + role + " is not a valid system role")
-			return false
-		} else {
+		if role == globals.RolesSystemAdmin || role == globals.RolesSystemUser || role == globals.RolesSystemReadOnly {
 			log.Debug("Role: " + role + " is a valid system role")
-			return true
+			continue
 		}
-	}
-	log.Error("No roles seem to be specified for system role validation")
-	return false
-}
-
-func commitJWT(id, jwt string, timeout int64, database string) error {
-	log.Debug("Committing JWT for user: " + id)
-	c.GetConfig()
-	// Generate SHA256 hash
-	hash := sha256.Sum256([]byte(jwt))
-	jwtSHA256 := "0x" + hex.EncodeToString(hash[:]) // Check if the database exists
-	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	log.Debug("Checking database file path: " + dbFilePath)
-	if !processor.DirectoryOrFileExists(dbFilePath) {
-		return fmt.Errorf("Database (" + database + ") does not exist")
-	}
-	// Create a wrapper for the SQL query
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
-	if err != nil {
-		return fmt.Errorf("Error when creating database wrapper: " + err.Error())
-	}
-	defer wrapper.Close()
-
-	// Check if the ID exists in the JWT table
-	selectQuery := "SELECT id FROM " + globals.JWTTable + " WHERE id = ?"
-	selectArgs := []interface{}{id}
-	log.Debug("JWT select query: " + selectQuery)
-	log.Debug("JWT select args: ", selectArgs)
-	var existingID string
-	err = wrapper.QueryRow(selectQuery, selectArgs...).Scan(&existingID)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("Failed to execute select query: " + err.Error())
-	}
-
-	//If the ID exists, perform an UPDATE
-	if existingID != "" {
-		updateQuery := "UPDATE " + globals.JWTTable + " SET token = ?, sha256 = ?, expiration = ? WHERE id = ?"
-		updateArgs := []interface{}{jwt, jwtSHA256, timeout, id}
-		log.Debug("JWT update query: " + updateQuery)
-		log.Debug("JWT update args: ", updateArgs)
-		_, err = wrapper.Execute(updateQuery, id, updateArgs...)
-		if err != nil {
-			return fmt.Errorf("Failed to execute update query: " + err.Error())
-		}
-	} else {
-		// If the ID does not exist, perform an INSERT
-		insertQuery := "INSERT INTO " + globals.JWTTable + " (id, token,sha256, expiration) VALUES (?,?, ?, ?)"
-		insertArgs := []interface{}{id, jwt, jwtSHA256, timeout}
-		log.Debug("JWT insert query: " + insertQuery)
-		log.Debug("JWT insert args: ", insertArgs)
-		_, err = wrapper.Execute(insertQuery, id, insertArgs...)
-		if err != nil {
-			return fmt.Errorf("Failed to execute insert query: " + err.Error())
+		if !customRoleExists(wrapper, role) {
+			log.Debug("Role: " + role + " is not a valid system or custom role")
+			return false
 		}
+		log.Debug("Role: " + role + " is a valid custom role")
 	}
-	log.Debug("JWT committed for user: " + id)
-	return nil
-
+	return true
 }
 
-// Removes the JWT from the database hence removing the session
-func deleteJWT(id, database string) error {
-	log.Debug("Deleting JWT for user: " + id)
-	c.GetConfig()
-	// Check if the database exists
-	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	log.Debug("Checking database file path: " + dbFilePath)
-	if !processor.DirectoryOrFileExists(dbFilePath) {
-		return fmt.Errorf("Database (" + database + ") does not exist")
+// customRoleExists checks if the role is a custom role (Ex: role:<name>) of the database
+func customRoleExists(wrapper *sqlWrapper.SQLiteWrapper, role string) bool {
+	if !strings.HasPrefix(role, globals.RolesCustomPrefix) {
+		return false
 	}
-	// Create a wrapper for the SQL query
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	exists, err := wrapper.RoleExists(strings.TrimPrefix(role, globals.RolesCustomPrefix))
 	if err != nil {
-		return fmt.Errorf("Error when creating database wrapper: " + err.Error())
-	}
-	defer wrapper.Close()
-
-	// Check if the ID exists in the JWT table
-	selectQuery := "SELECT id FROM " + globals.JWTTable + " WHERE id = ?"
-	selectArgs := []interface{}{id}
-	log.Debug("JWT select query: " + selectQuery)
-	log.Debug("JWT select args: ", selectArgs)
-	var existingID string
-	err = wrapper.QueryRow(selectQuery, selectArgs...).Scan(&existingID)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("Failed to execute select query: " + err.Error())
-	}
-
-	// If the ID exists, perform a DELETE
-	if existingID != "" {
-		log.Debug("Found existing ID (" + existingID + ") in the JWT table")
-		deleteQuery := "DELETE FROM " + globals.JWTTable + " WHERE id = ?"
-		deleteArgs := []interface{}{existingID}
-		log.Debug("JWT delete query: " + deleteQuery)
-		log.Debug("JWT delete args: ", deleteArgs)
-		_, err = wrapper.Execute(deleteQuery, id, deleteArgs...)
-		if err != nil {
-			return fmt.Errorf("Failed to execute delete query: " + err.Error())
-		}
-	} else {
-		return fmt.Errorf(globals.ErrorNotExist)
+		log.Error("Failed to check if role (" + role + ") exists: " + err.Error())
+		return false
 	}
-	log.Debug("JWT deleted for user: " + id)
-	return nil
+	return exists
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/mitchs-dev/library-go/generator"
 	"github.com/mitchs-dev/library-go/networking"
 	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	passwordPkg "github.com/mitchs-dev/simplQL/pkg/api/auth/password"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
@@ -18,11 +19,9 @@ import (
 
 var arb authPkg.AuthRequestBody
 
-var c configuration.Configuration
-
 func Create(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
 
-	c.GetConfig()
+	c := configuration.Current()
 
 	// Form the request body
 	authBody, err := io.ReadAll(r.Body)
@@ -134,16 +133,44 @@ func Create(r *http.Request, w http.ResponseWriter, userID, correlationID string
 		log.Debug("Password is not provided - Generating a random password (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		password = generator.RandomString(globals.UserPasswordLength)
 	}
-	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(database)
 	if err != nil {
-		log.Fatal("Error when creating database wrapper: " + err.Error())
+		log.Error("Failed to open database: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		w.WriteHeader(500)
+		response := globals.Response{
+			Status:  "error",
+			Message: "INTERNAL_SERVER_ERROR",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
+	defer wrapper.Release()
+	// Custom roles must be created before they are given to a user
+	for _, role := range roles {
+		if strings.HasPrefix(role, globals.RolesCustomPrefix) && !customRoleExists(wrapper, role) {
+			log.Error("Query contains a custom role which does not exist: " + role + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			w.WriteHeader(http.StatusBadRequest)
+			response := globals.Response{
+				Status:  "error",
+				Message: "Invalid role (" + role + ") - The custom role does not exist in the database (" + database + ")",
+				Data:    map[string]string{"correlationID": correlationID},
+			}
+			err := json.NewEncoder(w).Encode(response)
+			if err != nil {
+				log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+			}
+			return
+		}
 	}
-	defer wrapper.Close()
 	// Make sure that the user does not already exist
-	selectQuery := "SELECT id FROM " + globals.UsersTable + " WHERE name = ?"
+	nameCondition, nameArgs := authPkg.UserLookup(globals.UserNameColumnName, name)
+	selectQuery := "SELECT id FROM " + globals.UsersTable + " WHERE " + nameCondition
 	log.Debug("Select Query: " + selectQuery + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	rows, err := wrapper.Query(selectQuery, name)
+	rows, err := wrapper.Query(selectQuery, nameArgs...)
 	if err != nil {
 		log.Error("Failed to execute select query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		response := globals.Response{
@@ -190,7 +217,21 @@ func Create(r *http.Request, w http.ResponseWriter, userID, correlationID string
 	// Create the user
 	insertQuery := "INSERT INTO " + globals.UsersTable + " (id, name, password, roles) VALUES (?, ?, ?, ?)"
 	log.Debug("Insert Query: " + insertQuery + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	args := []interface{}{id, name, password, string(rolesJSON)}
+	passwordHash, err := passwordPkg.Hash(password)
+	if err != nil {
+		log.Error("Failed to hash password: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		response := globals.Response{
+			Status:  "error",
+			Message: "INTERNAL_SERVER_ERROR",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
+	args := []interface{}{id, name, passwordHash, string(rolesJSON)}
 	_, err = wrapper.Execute(insertQuery, userID, args...)
 	if err != nil {
 		log.Error("Failed to execute insert query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
@@ -15,8 +15,6 @@ import (
 )
 
 func Delete(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
-	c.GetConfig()
-
 	authBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Error("Failed to read request body: " + err.Error() + " (C: " + correlationID + ")")
@@ -36,43 +34,24 @@ func Delete(r *http.Request, w http.ResponseWriter, userID, correlationID string
 
 	database := arb.Database
 	table := globals.UsersTable
-	var filtersAsList []string
-	id := arb.Data.ID
-	password := arb.Data.Password
 	name := arb.Data.Name
-	roles := arb.Data.Roles
-	var args []interface{}
 
-	if id != "" {
-		filtersAsList = append(filtersAsList, "id= ? ")
-		args = append(args, id)
-	}
-	if name != "" {
-		if strings.Contains(name, "*") {
-			name = strings.ReplaceAll(name, "*", "%")
-			name = "name LIKE '" + name + "'"
-			filtersAsList = append(filtersAsList, name)
-		} else {
-			filtersAsList = append(filtersAsList, "name= ? ")
-			args = append(args, name)
+	whereClause, args, err := userWhere(&arb)
+	if err != nil {
+		log.Error("Invalid filter: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		response := globals.Response{
+			Status:  "error",
+			Message: err.Error(),
+			Data:    map[string]string{"correlationID": correlationID},
 		}
-	}
-	if password != "" {
-		filtersAsList = append(filtersAsList, "password= ? ")
-		args = append(args, password)
-	}
-	if len(roles) > 0 {
-		if len(roles) == 1 && roles[0] == "" {
-			log.Warn("Empty roles provided - Ignoring roles filter (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-		} else {
-			rolesString := strings.Join(roles, ",")
-			filtersAsList = append(filtersAsList, "roles= ? ")
-			args = append(args, rolesString)
+		w.WriteHeader(http.StatusBadRequest)
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 		}
+		return
 	}
-
-	filters := strings.Join(filtersAsList, " AND ")
-	if filters == "" {
+	if whereClause == "" {
 		log.Error("No filters provided for delete query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		response := globals.Response{
 			Status:  "error",
@@ -88,37 +67,26 @@ func Delete(r *http.Request, w http.ResponseWriter, userID, correlationID string
 
 	log.Info("Using database: " + database + "/" + table + " for query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-	selectQuery := "SELECT " + globals.UserEntryIDColumnName + ",roles FROM " + table
-
-	if filters != "" {
-		if strings.Contains(filters, "=") && !strings.Contains(filters, "'") {
-			var filterList []string
-			for _, filter := range strings.Split(filters, ",") {
-				filter = strings.ReplaceAll(filter, "\"", "")
-				if strings.Contains(filter, "*") {
-					filter = strings.ReplaceAll(filter, "*", "%")
-					filter = strings.ReplaceAll(filter, "=", " LIKE '")
-					filter = strings.ReplaceAll(filter, "' ", "'")
-					filter = strings.ReplaceAll(filter, " '", "'")
-				} else {
-					filter = strings.ReplaceAll(filter, "=", " = '")
-				}
-				filter = filter + "'"
-				filterList = append(filterList, filter)
-			}
-			filters = strings.Join(filterList, " AND ")
-		}
-		selectQuery += " WHERE " + strings.TrimSuffix(filters, " AND ")
-	}
+	selectQuery := "SELECT " + globals.UserEntryIDColumnName + ",roles FROM " + table + whereClause
 
 	log.Debug("Select Query: " + selectQuery + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(database)
 	if err != nil {
-		log.Fatal("Error when creating database wrapper: " + err.Error())
+		log.Error("Failed to open database: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		w.WriteHeader(500)
+		response := globals.Response{
+			Status:  "error",
+			Message: "INTERNAL_SERVER_ERROR",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
 	}
-	defer wrapper.Close()
+	defer wrapper.Release()
 
 	// Execute the select query
 	rows, err := wrapper.Query(selectQuery, args...)
@@ -201,7 +169,7 @@ func Delete(r *http.Request, w http.ResponseWriter, userID, correlationID string
 		log.Debug("User's (" + name + ") roles: " + fmt.Sprint(rowData["roles"]) + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		for index, column := range columns {
 			if column == "roles" {
-				if strings.Contains(fmt.Sprint(rowData[column]), globals.RolesSystemAdmin) {
+				if strings.Contains(fmt.Sprint(data.Process(rowData[column])), globals.RolesSystemAdmin) {
 					selectedAdminRows++
 				}
 			}
@@ -210,9 +178,9 @@ func Delete(r *http.Request, w http.ResponseWriter, userID, correlationID string
 
 	}
 
-	adminCountSelectQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE roles LIKE '%%%s%%'", table, globals.RolesSystemAdmin)
+	adminCountSelectQuery, adminCountArgs := adminCountQuery()
 	log.Debug("Admin Count Select Query: " + adminCountSelectQuery + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	adminCountRows, err := wrapper.Query(adminCountSelectQuery)
+	adminCountRows, err := wrapper.Query(adminCountSelectQuery, adminCountArgs...)
 	if err != nil {
 		log.Error("Failed to execute admin count select query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		response := globals.Response{
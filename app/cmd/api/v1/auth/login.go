@@ -2,20 +2,18 @@ package auth
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strings"
 
-	"github.com/mitchs-dev/library-go/generator"
-	jwtLib "github.com/mitchs-dev/library-go/jwt"
 	"github.com/mitchs-dev/library-go/networking"
 	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	log "github.com/sirupsen/logrus"
 )
 
 func Login(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
-	c.GetConfig()
+	c := configuration.Current()
 
 	if !c.Session.JWT.Enabled {
 		if strings.HasPrefix(r.Header.Get(globals.AuthenticationAuthorizationHeader), globals.AuthenticationAuthorizationHeaderBearerPrefix) {
@@ -185,53 +183,19 @@ func Login(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 	}
 
 	log.Debug("User " + name + " (" + name + ") exists (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	// Generate JWT
-	subject := "JWT Token for " + name + " for use in " + database
-	data := generator.RandomString(globals.JWTRandomDataLength)
-	jwt, _, jwttimeout, err := jwtLib.GenerateToken(authPkg.GetJWTSigningKey(database), globals.JWTTimeZone, globals.JWTTimeoutPeriod, authPkg.SetJWTIssuer(database), subject, userID, data)
-	if err != nil {
-		log.Error("Failed to generate JWT", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		w.WriteHeader(500)
-		response := globals.Response{
-			Status:  "error",
-			Message: "Failed to generate JWT",
-			Data:    map[string]string{"correlationID": correlationID},
-		}
-		err := json.NewEncoder(w).Encode(response)
-		if err != nil {
-			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		}
-		return
+	// Start a new session or renew the session of the JWT
+	var grant authPkg.SessionGrant
+	if jwt != "" {
+		grant, err = authPkg.RenewSession(database, jwt, name)
+	} else {
+		grant, err = authPkg.StartSession(database, userID, name)
 	}
-
-	// Commit JWT to database
-	err = commitJWT(userID, jwt, jwttimeout, database)
 	if err != nil {
-		log.Error("Failed to commit JWT to database: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		w.WriteHeader(500)
-		response := globals.Response{
-			Status:  "error",
-			Message: "Failed to commit JWT to database",
-			Data:    map[string]string{"correlationID": correlationID},
-		}
-		err := json.NewEncoder(w).Encode(response)
-		if err != nil {
-			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		}
+		// Renewing a session which reached its max lifetime requires a login with the password
+		respondWithSessionError(r, w, err, "Failed to start session", correlationID)
 		return
 	}
 	log.Debug("JWT generated for user (" + name + ") (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	w.Header().Set(globals.AuthenticationHeaderJWTSessionToken, jwt)
-	w.Header().Set(globals.AuthenticationHeaderSessionTimeout, fmt.Sprint(jwttimeout))
-
-	response := globals.Response{
-		Status:  "success",
-		Message: "User " + name + " (" + userID + ") authenticated",
-		Data:    map[string]string{"correlationID": correlationID, "jwt": jwt, "timeout": fmt.Sprint(jwttimeout)},
-	}
-	err = json.NewEncoder(w).Encode(response)
-	if err != nil {
-		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-	}
-	log.Info("User " + name + " (" + userID + ") logged in to database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	respondWithSession(r, w, grant, "User "+name+" ("+userID+") authenticated", correlationID)
+	log.Info("User " + name + " (" + userID + ") logged in to database: " + database + " with session: " + grant.Session + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 }
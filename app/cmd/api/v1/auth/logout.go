@@ -7,12 +7,14 @@ import (
 
 	"github.com/mitchs-dev/library-go/networking"
 	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
 	log "github.com/sirupsen/logrus"
 )
 
 func Logout(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
-	c.GetConfig()
+	c := configuration.Current()
 
 	if !c.Session.JWT.Enabled {
 		if strings.HasPrefix(r.Header.Get(globals.AuthenticationAuthorizationHeader), globals.AuthenticationAuthorizationHeaderBearerPrefix) {
@@ -177,10 +179,34 @@ func Logout(r *http.Request, w http.ResponseWriter, userID, correlationID string
 	}
 
 	log.Debug("User " + name + " (" + userID + ") exists (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	err = deleteJWT(userID, database)
+
+	// A JWT logs out of its own session while Basic authentication logs out of every session of the user
+	// Either can request a specific session (Admins may end the sessions of other users) or every session of the user
+	sessionID := r.URL.Query().Get("session")
+	ownerID := userID
+	if sessionID != "" {
+		ownerID, err = requestOwner(r, database, userID, "")
+		if err != nil {
+			respondWithSessionError(r, w, err, "Failed to log out", correlationID)
+			return
+		}
+	} else if jwt != "" && r.URL.Query().Get("all") != "true" {
+		sessionID, err = authPkg.JWTSession(jwt)
+		if err != nil {
+			respondWithSessionError(r, w, err, "Failed to log out", correlationID)
+			return
+		}
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		respondWithSessionError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+	sessions, err := wrapper.RevokeSessions(sessionID, ownerID, userID)
 	if err != nil {
-		if strings.Contains(err.Error(), globals.ErrorNotExist) {
-			log.Warn("JWT does not exist for user " + name + " (" + userID + ") - User is considered already logged out (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		if strings.HasPrefix(err.Error(), globals.ErrorSessionNotFound) && sessionID == "" {
+			log.Warn("No sessions exist for user " + name + " (" + userID + ") - User is considered already logged out (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 			w.WriteHeader(200)
 			response := globals.Response{
 				Status:  "error",
@@ -193,28 +219,23 @@ func Logout(r *http.Request, w http.ResponseWriter, userID, correlationID string
 			}
 			return
 		}
-		log.Error("Failed to delete JWT: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		response := globals.Response{
-			Status:  "error",
-			Message: "Failed to delete JWT for user (" + userID + ")",
-			Data:    map[string]string{"correlationID": correlationID},
-		}
-		err := json.NewEncoder(w).Encode(response)
-		if err != nil {
-			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		}
+		respondWithSessionError(r, w, err, "Failed to log out", correlationID)
 		return
 	}
+	revoked := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		revoked = append(revoked, session.ID)
+	}
 
 	w.WriteHeader(200)
 	response := globals.Response{
 		Status:  "success",
 		Message: "User " + name + " (" + userID + ") logged out successfully",
-		Data:    map[string]string{"correlationID": correlationID},
+		Data:    map[string]interface{}{"correlationID": correlationID, "sessions": revoked},
 	}
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 	}
-	log.Info("User " + name + " (" + userID + ") logged out of database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	log.Info("User " + name + " (" + userID + ") logged out of database: " + database + " ending session(s): " + strings.Join(revoked, ",") + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/mitchs-dev/library-go/networking"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
 	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
 	log "github.com/sirupsen/logrus"
 )
@@ -34,7 +35,6 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 
 	arb.GetAuthRequest(requestBody, correlationID)
 
-	c.GetConfig()
 	database := arb.Database
 	table := globals.UsersTable
 	page := r.URL.Query().Get("page")
@@ -42,7 +42,6 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 	sort := r.URL.Query().Get("sort")
 
 	var field string
-	var args []interface{}
 	if len(arb.Data.Select) == 0 {
 		log.Error("Invalid query parameters - Select must be provided" + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		response := globals.Response{
@@ -56,69 +55,62 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 		}
 		return
-	} else {
-		field = strings.Join(arb.Data.Select, ",")
 	}
-
-	var filtersAsList []string
-	if arb.Data.ID != "" {
-		filtersAsList = append(filtersAsList, "id = '"+arb.Data.ID+"'")
-	}
-	if arb.Data.Name != "" {
-		filtersAsList = append(filtersAsList, "name = '"+arb.Data.Name+"'")
-	}
-	if arb.Data.Password != "" {
-		filtersAsList = append(filtersAsList, "password = '"+arb.Data.Password+"'")
-	}
-	if len(arb.Data.Roles) > 0 {
-		var rolesList []string
-		for _, role := range arb.Data.Roles {
-			if strings.HasPrefix(role, "*") && strings.HasSuffix(role, "*") || strings.HasPrefix(role, "%") || strings.HasSuffix(role, "%") {
-				role = strings.TrimPrefix(role, "*")
-				role = strings.TrimSuffix(role, "*")
-				if !strings.HasPrefix(role, "%") && !strings.HasSuffix(role, "%") {
-					role = "%" + role + "%"
-				}
-				rolesList = append(rolesList, "roles LIKE '"+role+"'")
-			} else {
-				rolesList = append(rolesList, "roles= ? ")
-				args = append(args, role)
+	for _, selectField := range arb.Data.Select {
+		selectField = strings.Trim(selectField, "\"'")
+		if !filter.ValidColumn(selectField) || selectField == globals.UserPasswordColumnName {
+			log.Error("Invalid select field: " + selectField + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			response := globals.Response{
+				Status:  "error",
+				Message: "Invalid select field (" + selectField + ")",
+				Data:    map[string]string{"correlationID": correlationID},
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			err := json.NewEncoder(w).Encode(response)
+			if err != nil {
+				log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 			}
+			return
 		}
-		filtersAsList = append(filtersAsList, strings.Join(rolesList, " AND "))
+		field = strings.TrimPrefix(field+","+selectField, ",")
 	}
-	filters := strings.Join(filtersAsList, " AND ")
 
-	log.Info("Using database: " + database + "/" + table + " for query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	whereClause, args, err := userWhere(&arb)
+	if err != nil {
+		log.Error("Invalid filter: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		response := globals.Response{
+			Status:  "error",
+			Message: err.Error(),
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
 
-	// Prepare the query with placeholders
-	field = strings.TrimPrefix(field, "\"")
-	field = strings.TrimSuffix(field, "\"")
-	field = strings.TrimPrefix(field, "'")
-	field = strings.TrimSuffix(field, "'")
-	query := "SELECT " + field + " FROM " + table
+	log.Info("Using database: " + database + "/" + table + " for query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-	if filters != "" {
-		if strings.Contains(filters, "=") && !strings.Contains(filters, "'") {
-			var filterList []string
-			for _, filter := range strings.Split(filters, ",") {
-				filter = strings.ReplaceAll(filter, "\"", "")
-				if strings.Contains(filter, "*") {
-					filter = strings.ReplaceAll(filter, "*", "%")
-					filter = strings.ReplaceAll(filter, "=", " LIKE '")
-					args = append(args, filter)
-				} else {
-					filter = strings.ReplaceAll(filter, "=", " = '")
-				}
-				filter = filter + "'"
-				filterList = append(filterList, filter)
+	query := "SELECT " + field + " FROM " + table + whereClause
+	if sort != "" {
+		orderBy, err := filter.OrderBy(sort)
+		if err != nil {
+			log.Error("Invalid sort: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			response := globals.Response{
+				Status:  "error",
+				Message: err.Error(),
+				Data:    map[string]string{"correlationID": correlationID},
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			err := json.NewEncoder(w).Encode(response)
+			if err != nil {
+				log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 			}
-			filters = strings.Join(filterList, " AND ")
+			return
 		}
-		query += " WHERE " + strings.TrimSuffix(filters, " AND ")
-	}
-	if sort != "" {
-		query += " ORDER BY " + sort
+		query += orderBy
 	}
 	if limit == "" && page != "" {
 		log.Error("Invalid query parameters - Both limit and page must be provided together when using page" + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
@@ -143,12 +135,22 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 	}
 
 	log.Debug("Query: " + query)
-	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(database)
 	if err != nil {
-		log.Fatal("Error when creating database wrapper: " + err.Error())
+		log.Error("Failed to open database: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		w.WriteHeader(500)
+		response := globals.Response{
+			Status:  "error",
+			Message: "INTERNAL_SERVER_ERROR",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
 	}
-	defer wrapper.Close()
+	defer wrapper.Release()
 	rows, err := wrapper.Query(query, args...)
 	if err != nil {
 		var responseMessage string
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// roleRequest is the request body for the role actions
+type roleRequest struct {
+	Database string               `json:"database" yaml:"database"`
+	Role     string               `json:"role" yaml:"role"`
+	Grant    sqlWrapper.RoleGrant `json:"grant" yaml:"grant"`
+	Table    string               `json:"table" yaml:"table"`
+	Actions  []string             `json:"actions" yaml:"actions"`
+}
+
+// RoleCreate creates a custom role without any grants
+func RoleCreate(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	request, wrapper, ok := openRoleRequest(r, w, correlationID)
+	if !ok {
+		return
+	}
+	defer wrapper.Release()
+	role, err := wrapper.CreateRole(request.Role, userID)
+	if err != nil {
+		respondWithRoleError(r, w, err, "Failed to create role ("+request.Role+")", correlationID)
+		return
+	}
+	log.Info("Created role: " + request.Database + "/" + role.Name + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+	respondWithRole(r, w, http.StatusCreated, "Role ("+role.Name+") created", role, correlationID)
+}
+
+// RoleList lists the custom roles of a database along with their grants
+func RoleList(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	if database == "" {
+		respondWithRoleError(r, w, errors.New(globals.ErrorInvalidRole+": database name is required"), "Failed to list roles", correlationID)
+		return
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		respondWithRoleError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+	roles, err := wrapper.Roles()
+	if err != nil {
+		respondWithRoleError(r, w, err, "Failed to list roles", correlationID)
+		return
+	}
+	response := globals.Response{
+		Status:  "success",
+		Message: "QUERY_SUCCESS",
+		Data:    map[string]interface{}{"correlationID": correlationID, "roles": roles},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Debug("Listed roles for database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+}
+
+// RoleGrant gives a custom role the actions on a table - A previous grant of the role on the table is replaced
+func RoleGrant(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	request, wrapper, ok := openRoleRequest(r, w, correlationID)
+	if !ok {
+		return
+	}
+	defer wrapper.Release()
+	role, err := wrapper.GrantRole(request.Role, request.Grant, userID)
+	if err != nil {
+		respondWithRoleError(r, w, err, "Failed to grant role ("+request.Role+")", correlationID)
+		return
+	}
+	log.Info("Granted " + strings.Join(request.Grant.Actions, ",") + " on table (" + request.Grant.Table + ") to role: " + request.Database + "/" + role.Name + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+	respondWithRole(r, w, http.StatusOK, "Role ("+role.Name+") granted", role, correlationID)
+}
+
+// RoleRevoke removes actions (Or the whole grant when no actions are listed) of a custom role on a table
+func RoleRevoke(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	request, wrapper, ok := openRoleRequest(r, w, correlationID)
+	if !ok {
+		return
+	}
+	defer wrapper.Release()
+	role, err := wrapper.RevokeRole(request.Role, request.Table, request.Actions, userID)
+	if err != nil {
+		respondWithRoleError(r, w, err, "Failed to revoke role ("+request.Role+")", correlationID)
+		return
+	}
+	log.Info("Revoked grant on table (" + request.Table + ") from role: " + request.Database + "/" + role.Name + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+	respondWithRole(r, w, http.StatusOK, "Role ("+role.Name+") revoked", role, correlationID)
+}
+
+// openRoleRequest decodes the body of a role request and opens its database - The caller releases the database once it is done with it
+// The database of the query string takes precedence over the body as it is the one the request was authenticated against
+// The role may be given with or without the custom role prefix
+func openRoleRequest(r *http.Request, w http.ResponseWriter, correlationID string) (roleRequest, *sqlWrapper.SQLiteWrapper, bool) {
+	var request roleRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		log.Error("Failed to unmarshal request body: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		respondWithRoleError(r, w, errors.New(globals.ErrorInvalidRole+": ensure that the request body is in the valid role format"), "Invalid request body", correlationID)
+		return request, nil, false
+	}
+	if r.URL.Query().Get("database") != "" {
+		request.Database = r.URL.Query().Get("database")
+	}
+	if request.Database == "" {
+		respondWithRoleError(r, w, errors.New(globals.ErrorInvalidRole+": database name is required"), "Invalid request body", correlationID)
+		return request, nil, false
+	}
+	request.Role = strings.TrimPrefix(request.Role, globals.RolesCustomPrefix)
+	wrapper, err := sqlWrapper.Open(request.Database)
+	if err != nil {
+		respondWithRoleError(r, w, err, "Failed to open database", correlationID)
+		return request, nil, false
+	}
+	return request, wrapper, true
+}
+
+// respondWithRole writes the role as the response
+func respondWithRole(r *http.Request, w http.ResponseWriter, status int, message string, role sqlWrapper.Role, correlationID string) {
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "success",
+		Message: message,
+		Data:    map[string]interface{}{"correlationID": correlationID, "role": role},
+	}
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
+
+// respondWithRoleError maps a role error to a response status code and writes the response
+func respondWithRoleError(r *http.Request, w http.ResponseWriter, err error, message, correlationID string) {
+	status := http.StatusInternalServerError
+	responseMessage := "INTERNAL_SERVER_ERROR"
+	switch {
+	case strings.HasPrefix(err.Error(), globals.ErrorRoleNotFound):
+		status = http.StatusNotFound
+		responseMessage = message + " - The role does not exist"
+	case strings.HasPrefix(err.Error(), globals.ErrorRoleExists):
+		status = http.StatusConflict
+		responseMessage = message + " - The role already exists"
+	case strings.HasPrefix(err.Error(), globals.ErrorInvalidRole):
+		status = http.StatusBadRequest
+		responseMessage = message + " - " + err.Error()
+	case strings.Contains(err.Error(), globals.ErrorNotExist):
+		status = http.StatusNotFound
+		responseMessage = message + " - The database does not exist"
+	}
+	if status == http.StatusInternalServerError {
+		log.Error(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	} else {
+		log.Warn(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "error",
+		Message: responseMessage,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/networking"
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// refreshRequest is the request body for the refresh action
+type refreshRequest struct {
+	Database     string `json:"database" yaml:"database"`
+	RefreshToken string `json:"refreshToken" yaml:"refreshToken"`
+}
+
+// Refresh issues a new access token for the session of the refresh token
+// The refresh token is rotated on every use so the one which is returned has to be used next time
+func Refresh(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	c := configuration.Current()
+	if !c.Session.JWT.Enabled {
+		respondWithSessionError(r, w, errors.New(globals.ErrorPermissionDenied+": JWT is disabled on this server"), "Failed to refresh session", correlationID)
+		return
+	}
+	var request refreshRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		respondWithSessionError(r, w, errors.New(globals.ErrorInvalidRefreshToken+": ensure that the request body contains the refresh token"), "Invalid request body", correlationID)
+		return
+	}
+	if r.URL.Query().Get("database") != "" {
+		request.Database = r.URL.Query().Get("database")
+	}
+	if request.Database == "" || request.RefreshToken == "" {
+		respondWithSessionError(r, w, errors.New(globals.ErrorInvalidRefreshToken+": database name and refresh token are required"), "Invalid request body", correlationID)
+		return
+	}
+	grant, err := authPkg.RefreshSession(request.Database, request.RefreshToken)
+	if err != nil {
+		respondWithSessionError(r, w, err, "Failed to refresh session", correlationID)
+		return
+	}
+	respondWithSession(r, w, grant, "Session ("+grant.Session+") of user "+grant.Name+" ("+grant.UserID+") refreshed", correlationID)
+	log.Debug("Refreshed session (" + grant.Session + ") of user " + grant.Name + " (" + grant.UserID + ") in database: " + request.Database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+}
+
+// SessionList lists the sessions of the user - Admins list the sessions of every user (Or only of the requested user)
+// The session of the JWT of the request is marked as the current one
+func SessionList(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	if database == "" {
+		respondWithSessionError(r, w, errors.New(globals.ErrorSessionNotFound+": database name is required"), "Failed to list sessions", correlationID)
+		return
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		respondWithSessionError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+	ownerID, err := requestOwner(r, database, userID, r.URL.Query().Get("userID"))
+	if err != nil {
+		respondWithSessionError(r, w, err, "Failed to list sessions", correlationID)
+		return
+	}
+	sessions, err := wrapper.Sessions(ownerID)
+	if err != nil {
+		respondWithSessionError(r, w, err, "Failed to list sessions", correlationID)
+		return
+	}
+	response := globals.Response{
+		Status:  "success",
+		Message: "QUERY_SUCCESS",
+		Data:    map[string]interface{}{"correlationID": correlationID, "sessions": sessions, "current": currentSession(r)},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Debug("Listed sessions for database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+}
+
+// currentSession returns the session of the JWT of the request - Empty is returned for other authentication methods
+func currentSession(r *http.Request) string {
+	value := r.Header.Get(globals.AuthenticationAuthorizationHeader)
+	if !strings.HasPrefix(value, globals.AuthenticationAuthorizationHeaderBearerPrefix) {
+		return ""
+	}
+	_, _, jwt, err := authPkg.AuthenticationHeaderData(value, "")
+	if err != nil || jwt == "" {
+		return ""
+	}
+	session, err := authPkg.JWTSession(jwt)
+	if err != nil {
+		return ""
+	}
+	return session
+}
+
+// respondWithSession writes the tokens of the session to the response
+func respondWithSession(r *http.Request, w http.ResponseWriter, grant authPkg.SessionGrant, message, correlationID string) {
+	w.Header().Set(globals.AuthenticationHeaderJWTSessionToken, grant.JWT)
+	w.Header().Set(globals.AuthenticationHeaderSessionTimeout, fmt.Sprint(grant.Timeout))
+	response := globals.Response{
+		Status:  "success",
+		Message: message,
+		Data: map[string]string{
+			"correlationID":  correlationID,
+			"session":        grant.Session,
+			"jwt":            grant.JWT,
+			"timeout":        fmt.Sprint(grant.Timeout),
+			"refreshToken":   grant.RefreshToken,
+			"refreshTimeout": fmt.Sprint(grant.RefreshTimeout),
+		},
+	}
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
+
+// respondWithSessionError maps a session error to a response status code and writes the response
+func respondWithSessionError(r *http.Request, w http.ResponseWriter, err error, message, correlationID string) {
+	status := http.StatusInternalServerError
+	responseMessage := "INTERNAL_SERVER_ERROR"
+	switch {
+	case strings.HasPrefix(err.Error(), globals.ErrorInvalidRefreshToken):
+		status = http.StatusUnauthorized
+		responseMessage = message + " - " + err.Error()
+	case strings.HasPrefix(err.Error(), globals.ErrorSessionNotFound):
+		status = http.StatusNotFound
+		responseMessage = message + " - " + err.Error()
+	case strings.HasPrefix(err.Error(), globals.ErrorPermissionDenied):
+		status = http.StatusForbidden
+		responseMessage = message + " - " + err.Error()
+	case strings.Contains(err.Error(), globals.ErrorNotExist), strings.Contains(err.Error(), "does not exist"):
+		status = http.StatusNotFound
+		responseMessage = message + " - The database does not exist"
+	}
+	if status == http.StatusInternalServerError {
+		log.Error(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	} else {
+		log.Warn(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "error",
+		Message: responseMessage,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
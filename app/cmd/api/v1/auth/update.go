@@ -2,20 +2,22 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
 	"github.com/mitchs-dev/library-go/networking"
+	passwordPkg "github.com/mitchs-dev/simplQL/pkg/api/auth/password"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
 	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
 	log "github.com/sirupsen/logrus"
 )
 
 func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
-	c.GetConfig()
-
 	table := globals.UsersTable
 
 	// Get the request body
@@ -39,16 +41,23 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 
 	log.Info("Using database: " + arb.Database + " for query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-	dbFilePath := c.Storage.Path + "/" + arb.Database + ".db"
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(arb.Database)
 	if err != nil {
-		log.Fatal("Error when creating database wrapper: " + err.Error())
+		log.Error("Failed to open database: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		w.WriteHeader(500)
+		response := globals.Response{
+			Status:  "error",
+			Message: "INTERNAL_SERVER_ERROR",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
 	}
-	defer wrapper.Close()
+	defer wrapper.Release()
 
-	var filters string
-	var filtersAsList []string
-	var filterArgs []interface{}
 	var args []interface{}
 
 	if arb.Data.Update == nil {
@@ -71,11 +80,11 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 	var setClauses []string
 	for field, value := range updateData {
 		if field == "roles" {
-			if !validateSystemRoles(value) {
+			if !validateRoles(wrapper, value) {
 				log.Error("Invalid role format or role (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 				response := globals.Response{
 					Status:  "error",
-					Message: "Invalid role or role format - Ensure that the role format is valid (Ex: " + globals.SystemRolePrefix + "<role>) and the role is one of: " + globals.RolesSystemAdmin + ", " + globals.RolesSystemUser + ", " + globals.RolesSystemReadOnly,
+					Message: "Invalid role or role format - Ensure that the role format is valid (Ex: " + globals.SystemRolePrefix + "<role>) and the role is one of: " + globals.RolesSystemAdmin + ", " + globals.RolesSystemUser + ", " + globals.RolesSystemReadOnly + " or an existing custom role (Ex: " + globals.RolesCustomPrefix + "<role>)",
 					Data:    map[string]string{"correlationID": correlationID},
 				}
 				w.WriteHeader(http.StatusBadRequest)
@@ -86,43 +95,76 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 				return
 			}
 		}
+		if !filter.ValidColumn(field) || field == globals.TableEntryIDColumnName {
+			log.Error("Invalid update field: " + field + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			response := globals.Response{
+				Status:  "error",
+				Message: "Invalid update field (" + field + ")",
+				Data:    map[string]string{"correlationID": correlationID},
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			err := json.NewEncoder(w).Encode(response)
+			if err != nil {
+				log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+			}
+			return
+		}
+		if field == globals.UserPasswordColumnName {
+			newPassword, ok := value.(string)
+			if !ok || newPassword == "" {
+				log.Error("Invalid password value (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+				response := globals.Response{
+					Status:  "error",
+					Message: "Invalid update field (" + field + ") - Password must be a non-empty string",
+					Data:    map[string]string{"correlationID": correlationID},
+				}
+				w.WriteHeader(http.StatusBadRequest)
+				err := json.NewEncoder(w).Encode(response)
+				if err != nil {
+					log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+				}
+				return
+			}
+			value, err = passwordPkg.Hash(newPassword)
+			if err != nil {
+				log.Error("Failed to hash password: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+				w.WriteHeader(http.StatusInternalServerError)
+				response := globals.Response{
+					Status:  "error",
+					Message: "INTERNAL_SERVER_ERROR",
+					Data:    map[string]string{"correlationID": correlationID},
+				}
+				err := json.NewEncoder(w).Encode(response)
+				if err != nil {
+					log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+				}
+				return
+			}
+		}
 		setClauses = append(setClauses, field+" = ?")
 		args = append(args, value)
 	}
 
-	if arb.Data.ID != "" {
-		filtersAsList = append(filtersAsList, "id = ?")
-		filterArgs = append(filterArgs, arb.Data.ID)
-	}
-	if arb.Data.Name != "" {
-		filtersAsList = append(filtersAsList, "name = ?")
-		filterArgs = append(filterArgs, arb.Data.Name)
+	whereClause, filterArgs, err := userWhere(&arb)
+	if err == nil && whereClause == "" {
+		err = errors.New(globals.ErrorInvalidFilter + ": at least one filter is required")
 	}
-	if arb.Data.Password != "" {
-		filtersAsList = append(filtersAsList, "password = ?")
-		filterArgs = append(filterArgs, arb.Data.Password)
-	}
-	if len(arb.Data.Roles) > 0 {
-		var rolesList []string
-		for _, role := range arb.Data.Roles {
-			rolesList = append(rolesList, "roles LIKE ?")
-			if !strings.Contains(role, "%") {
-				if strings.HasPrefix(role, "*") || strings.HasSuffix(role, "*") {
-					role = strings.TrimSuffix(strings.TrimPrefix(role, "*"), "*")
-					filterArgs = append(filterArgs, "%"+role+"%")
-				} else {
-					filterArgs = append(filterArgs, "%"+role+"%")
-				}
-			} else {
-				filterArgs = append(filterArgs, role)
-			}
+	if err != nil {
+		log.Error("Invalid filter: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		response := globals.Response{
+			Status:  "error",
+			Message: err.Error(),
+			Data:    map[string]string{"correlationID": correlationID},
 		}
-		filtersAsList = append(filtersAsList, strings.Join(rolesList, " AND "))
+		w.WriteHeader(http.StatusBadRequest)
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
 	}
-	filters = strings.Join(filtersAsList, " AND ")
-	args = append(args, filterArgs...)
 
-	selectQuery := "SELECT roles," + globals.UserEntryIDColumnName + " FROM " + table + " WHERE " + filters
+	selectQuery := "SELECT roles," + globals.UserEntryIDColumnName + " FROM " + table + whereClause
 	log.Debug("Select query to check for selected admins: " + selectQuery)
 	log.Debug("Select query args: ", filterArgs)
 
@@ -144,6 +186,7 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 	defer rows.Close()
 
 	var selectedAdminRowsInt64 int64
+	var ids []interface{}
 	for rows.Next() {
 		// Check if the user is a system admin
 		var roles string
@@ -152,7 +195,9 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 			log.Error("Failed to scan row: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 			continue
 		}
-		if strings.Contains(roles, globals.RolesSystemAdmin) {
+		// The stored ID is matched as is since it may still be encrypted with a previous key
+		ids = append(ids, data.Plaintext(id))
+		if strings.Contains(fmt.Sprint(data.Process(roles)), globals.RolesSystemAdmin) {
 			selectedAdminRowsInt64++
 		}
 	}
@@ -173,9 +218,9 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 	log.Info("Rows affected: ", selectedAdminRowsInt64)
 	selectedAdminRows := int(selectedAdminRowsInt64)
 
-	adminCountSelectQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE roles LIKE '%%%s%%'", table, globals.RolesSystemAdmin)
+	adminCountSelectQuery, adminCountArgs := adminCountQuery()
 	log.Debug("Admin Count Select Query: " + adminCountSelectQuery + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-	adminCountRows, err := wrapper.Query(adminCountSelectQuery)
+	adminCountRows, err := wrapper.Query(adminCountSelectQuery, adminCountArgs...)
 	if err != nil {
 		log.Error("Failed to execute admin count select query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 		response := globals.Response{
@@ -217,52 +262,57 @@ func Update(r *http.Request, w http.ResponseWriter, userID, correlationID string
 		}
 	}
 
-	updateQuery := "UPDATE " + table + " SET " + strings.Join(setClauses, ", ") + " WHERE " + filters
+	if len(ids) == 0 {
+		log.Error("No entry found for update query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		response := globals.Response{
+			Status:  "error",
+			Message: "ENTRY_NOT_FOUND",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		w.WriteHeader(http.StatusNotFound)
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
+
+	// Each user is updated by ID so that the previous values are recorded per user
+	updateQuery := "UPDATE " + table + " SET " + strings.Join(setClauses, ", ") + " WHERE " + globals.UserEntryIDColumnName + " = ?"
 	log.Debug("Update query: ", updateQuery)
 	log.Debug("Update query args: ", args)
 
-	result, err := wrapper.Execute(updateQuery, userID, args...)
-	if err != nil {
-		if strings.Contains(err.Error(), globals.ErrorTransactionNoEntry) {
-			log.Error("No entry found for update query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	var rowsAffected int64
+	for _, id := range ids {
+		result, err := wrapper.Execute(updateQuery, userID, append(append([]interface{}{}, args...), id)...)
+		if err != nil {
+			log.Error("Failed to execute update query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 			response := globals.Response{
 				Status:  "error",
-				Message: "ENTRY_NOT_FOUND",
+				Message: "INTERNAL_SERVER_ERROR",
 				Data:    map[string]string{"correlationID": correlationID},
 			}
-			w.WriteHeader(http.StatusNotFound)
 			err := json.NewEncoder(w).Encode(response)
 			if err != nil {
 				log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 			}
 			return
 		}
-		log.Error("Failed to execute update query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-		response := globals.Response{
-			Status:  "error",
-			Message: "INTERNAL_SERVER_ERROR",
-			Data:    map[string]string{"correlationID": correlationID},
-		}
-		err := json.NewEncoder(w).Encode(response)
+		affected, err := result.RowsAffected()
 		if err != nil {
-			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-		}
-		return
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Error("Failed to retrieve affected rows: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-		response := globals.Response{
-			Status:  "error",
-			Message: "INTERNAL_SERVER_ERROR",
-			Data:    map[string]string{"correlationID": correlationID},
-		}
-		err := json.NewEncoder(w).Encode(response)
-		if err != nil {
-			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+			log.Error("Failed to retrieve affected rows: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			response := globals.Response{
+				Status:  "error",
+				Message: "INTERNAL_SERVER_ERROR",
+				Data:    map[string]string{"correlationID": correlationID},
+			}
+			err := json.NewEncoder(w).Encode(response)
+			if err != nil {
+				log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+			}
+			return
 		}
-		return
+		rowsAffected += affected
 	}
 
 	response := globals.Response{
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+func Create(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	var requestBody createDatabaseRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		log.Error("Failed to unmarshal request body: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		w.WriteHeader(400)
+		response := globals.Response{
+			Status:  "error",
+			Message: "Invalid request body - Ensure that the request body is in the valid database creation format",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
+
+	database := requestBody.ConfigurationDatabaseEntry
+	adminName, adminPassword, err := sqlWrapper.ProvisionDatabase(database, requestBody.Admin.Name, requestBody.Admin.Password)
+	if err != nil {
+		respondWithDatabaseError(r, w, err, "Failed to create database ("+database.Name+")", correlationID)
+		return
+	}
+
+	admin := map[string]string{"name": adminName}
+	if adminPassword != "" {
+		// The generated password is only returned once
+		admin["password"] = adminPassword
+	}
+	w.WriteHeader(201)
+	response := globals.Response{
+		Status:  "success",
+		Message: "Database (" + database.Name + ") created",
+		Data: map[string]interface{}{
+			"correlationID": correlationID,
+			"database":      database.Name,
+			"admin":         admin,
+		},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Created database: " + database.Name + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
@@ -0,0 +1,62 @@
+// database provides the runtime provisioning and deprovisioning of databases
+package database
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+// createDatabaseRequest is the request body for the create action
+type createDatabaseRequest struct {
+	configuration.ConfigurationDatabaseEntry
+	Admin struct {
+		Name     string `json:"name" yaml:"name"`
+		Password string `json:"password" yaml:"password"`
+	} `json:"admin" yaml:"admin"`
+}
+
+// respondWithDatabaseError maps a provisioning error to a response status code and writes the response
+func respondWithDatabaseError(r *http.Request, w http.ResponseWriter, err error, message, correlationID string) {
+	status := 500
+	responseMessage := "INTERNAL_SERVER_ERROR"
+	switch {
+	case strings.Contains(err.Error(), globals.ErrorNotExist):
+		status = 404
+		responseMessage = message + " - The database does not exist"
+	case strings.Contains(err.Error(), globals.ErrorDatabaseExists):
+		status = 409
+		responseMessage = message + " - The database already exists"
+	case strings.Contains(err.Error(), globals.ErrorDatabaseConfigured):
+		status = 409
+		responseMessage = message + " - The database is defined in the configuration file and must be removed from there"
+	case strings.Contains(err.Error(), globals.ErrorInvalidDatabaseName),
+		strings.Contains(err.Error(), globals.ErrorInvalidTableName),
+		strings.Contains(err.Error(), globals.ErrorInvalidColumnName),
+		strings.Contains(err.Error(), globals.ErrorInvalidColumnType),
+		strings.Contains(err.Error(), globals.ErrorInvalidConstraint),
+		strings.Contains(err.Error(), globals.ErrorInvalidIndex):
+		status = 400
+		responseMessage = message + " - " + err.Error()
+	}
+	if status == 500 {
+		log.Error(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	} else {
+		log.Warn(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "error",
+		Message: responseMessage,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
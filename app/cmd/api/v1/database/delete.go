@@ -0,0 +1,32 @@
+package database
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+func Delete(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+
+	err := sqlWrapper.DeprovisionDatabase(database)
+	if err != nil {
+		respondWithDatabaseError(r, w, err, "Failed to delete database ("+database+")", correlationID)
+		return
+	}
+
+	response := globals.Response{
+		Status:  "success",
+		Message: "Database (" + database + ") deleted",
+		Data:    map[string]string{"correlationID": correlationID, "database": database},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Deleted database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
+	log "github.com/sirupsen/logrus"
+)
+
+// entryFilter builds the filter tree for an entry from its filter and the column/value pairs in its data
+// System parameters (Ex: __select, __update) are not used as filters
+func entryFilter(entry globals.EntryRequestEntry) (*filter.Node, error) {
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		if strings.HasPrefix(key, globals.SystemParameterPrefix) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var nodes []*filter.Node
+	for _, key := range keys {
+		switch value := entry.Data[key].(type) {
+		case []interface{}:
+			// Legacy format where each value is either column=value or a value for the column
+			for _, item := range value {
+				itemString := fmt.Sprint(item)
+				if !strings.Contains(itemString, "=") {
+					itemString = key + "=" + itemString
+				}
+				node, err := filter.FromLegacy([]string{itemString})
+				if err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, node)
+			}
+		case map[string]interface{}:
+			return nil, errors.New(globals.ErrorInvalidFilter + ": value of (" + key + ") must be a single value - Use filter for other comparisons")
+		default:
+			nodes = append(nodes, filter.Equal(key, value))
+		}
+	}
+
+	requestFilter, err := filter.Parse(entry.Filter)
+	if err != nil {
+		return nil, err
+	}
+	nodes = append(nodes, requestFilter)
+	return filter.And(nodes...), nil
+}
+
+// entrySelect returns the columns to select for an entry
+func entrySelect(entry globals.EntryRequestEntry) (string, error) {
+	selectValue, exists := entry.Data[globals.RequestSelectParameter]
+	if !exists {
+		return "*", nil
+	}
+	selectFields, ok := selectValue.([]interface{})
+	if !ok || len(selectFields) == 0 {
+		return "", errors.New(globals.ErrorInvalidColumnName + ": " + globals.RequestSelectParameter + " must be a list of columns")
+	}
+	columns := make([]string, 0, len(selectFields))
+	for _, selectField := range selectFields {
+		column := strings.Trim(fmt.Sprint(selectField), "\"'")
+		if !filter.ValidColumn(column) {
+			return "", errors.New(globals.ErrorInvalidColumnName + ": " + column)
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ","), nil
+}
+
+// respondWithBadRequest responds with a 400 and the reason the request was rejected
+func respondWithBadRequest(r *http.Request, w http.ResponseWriter, correlationID, message string) {
+	response := globals.Response{
+		Status:  "error",
+		Message: message,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
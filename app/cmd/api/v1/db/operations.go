@@ -0,0 +1,446 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/generator"
+	"github.com/mitchs-dev/library-go/networking"
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	"github.com/mitchs-dev/simplQL/pkg/database/transactions"
+	log "github.com/sirupsen/logrus"
+)
+
+// querier is implemented by both wrappers and transactions so that reads can run in either
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	BlindIndexedColumns(table string) (map[string]bool, error)
+	PlaintextColumns(table string) (map[string]bool, error)
+	ColumnTypes(table string) (map[string]string, error)
+	Policy(table string) (*configuration.ConfigurationDatabaseEntryPolicy, error)
+}
+
+// entryTransaction returns the transaction which the entry operations of the request run in
+// Requests with a transaction ID run in the client-managed transaction, otherwise a transaction is opened for the request
+// The returned function must be called with the outcome of the request - A failed request rolls back the transaction
+func entryTransaction(r *http.Request, database, userID string) (*sqlWrapper.Transaction, func(error) error, error) {
+	transactionID := r.Header.Get(globals.TransactionHeaderID)
+	if transactionID != "" {
+		transaction, release, err := transactions.Acquire(transactionID, database, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		finish := func(cause error) error {
+			defer release()
+			if cause != nil {
+				transactions.Abort(transactionID, cause)
+			}
+			return nil
+		}
+		return transaction, finish, nil
+	}
+
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		wrapper.Release()
+		return nil, nil, err
+	}
+	finish := func(cause error) error {
+		defer wrapper.Release()
+		if cause != nil {
+			err := transaction.Rollback(cause)
+			if err != nil {
+				log.Error("Failed to roll back transaction: " + err.Error())
+			}
+			return nil
+		}
+		return transaction.Commit()
+	}
+	return transaction, finish, nil
+}
+
+// entryQuerier returns what the reads of the request run in along with a function which must be called once the reads are done
+// Requests with a transaction ID read from the client-managed transaction so that uncommitted changes are visible
+func entryQuerier(r *http.Request, database, userID string) (querier, func(), error) {
+	transactionID := r.Header.Get(globals.TransactionHeaderID)
+	if transactionID != "" {
+		return transactions.Acquire(transactionID, database, userID)
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wrapper, wrapper.Release, nil
+}
+
+// validateTable ensures that the table can be used by entry operations
+func validateTable(table string) error {
+	if !filter.ValidIdentifier(table) || strings.HasPrefix(table, globals.SystemTablePrefix) {
+		return errors.New(globals.ErrorInvalidTableName + ": " + table)
+	}
+	return nil
+}
+
+// entryStorage returns how the columns of the table are stored (The blind index of searchable columns and the unencrypted columns)
+// Nil is returned while encryption is disabled as values can be compared as is
+func entryStorage(q querier, table string) (*filter.Storage, error) {
+	c := configuration.Current()
+	if !c.Storage.Encryption.Enabled {
+		return nil, nil
+	}
+	blindIndexed, err := q.BlindIndexedColumns(table)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := q.PlaintextColumns(table)
+	if err != nil {
+		return nil, err
+	}
+	if len(blindIndexed) == 0 && len(plaintext) == 0 {
+		return nil, nil
+	}
+	return &filter.Storage{
+		BlindIndexed: blindIndexed,
+		BlindIndex: func(value interface{}) interface{} {
+			return data.BlindIndex(value)
+		},
+		Plaintext: plaintext,
+		PlaintextValue: func(value interface{}) interface{} {
+			return data.Plaintext(value)
+		},
+	}, nil
+}
+
+// entryWhere returns the WHERE clause for the filter tree where conditions compare against the stored values of the columns of the table
+func entryWhere(q querier, table string, node *filter.Node) (string, []interface{}, error) {
+	storage, err := entryStorage(q, table)
+	if err != nil {
+		return "", nil, err
+	}
+	return filter.WhereStored(node, storage)
+}
+
+// blindIndexValue returns the value which is stored in the blind index column of a searchable column
+func blindIndexValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return data.BlindIndex(value)
+}
+
+// storedValue returns the value which is passed on to be stored in the column - Values of unencrypted columns are stored as is
+func storedValue(storage *filter.Storage, column string, value interface{}) interface{} {
+	if storage != nil && storage.Plaintext[column] {
+		return data.Plaintext(value)
+	}
+	return value
+}
+
+// validateTypes validates the values against the declared types of their columns and returns the values as they are stored
+// Every field which does not match is reported in a single error
+func validateTypes(q querier, table string, values map[string]interface{}) (map[string]interface{}, error) {
+	columnTypes, err := q.ColumnTypes(table)
+	if err != nil {
+		return nil, err
+	}
+	validated := make(map[string]interface{}, len(values))
+	typeError := &data.TypeError{Fields: make(map[string]string)}
+	for column, value := range values {
+		stored, ok := data.ValidateType(columnTypes[column], value)
+		if !ok {
+			typeError.Fields[column] = columnTypes[column]
+			continue
+		}
+		validated[column] = stored
+	}
+	if len(typeError.Fields) > 0 {
+		return nil, typeError
+	}
+	return validated, nil
+}
+
+// createEntry creates an entry as part of the transaction and returns its sys_eid
+func createEntry(transaction *sqlWrapper.Transaction, permissions *authPkg.Permissions, entry globals.EntryRequestEntry) (string, error) {
+	err := validateTable(entry.Table)
+	if err != nil {
+		return "", err
+	}
+	if len(entry.Data) == 0 {
+		return "", errors.New(globals.ErrorInvalidColumnName + ": at least one column is required")
+	}
+	columns := make([]string, 0, len(entry.Data))
+	for column := range entry.Data {
+		if !filter.ValidColumn(column) || column == globals.TableEntryIDColumnName {
+			return "", errors.New(globals.ErrorInvalidColumnName + ": " + column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	err = permissions.Check(entry.Table, globals.RoleActionCreate, columns)
+	if err != nil {
+		return "", err
+	}
+	// The owner column of a table with a policy is set to the user regardless of the value in the request
+	ownerColumn, owner, err := policyOwner(transaction, permissions, entry.Table)
+	if err != nil {
+		return "", err
+	}
+	if ownerColumn != "" {
+		stampedData := make(map[string]interface{}, len(entry.Data)+1)
+		for column, value := range entry.Data {
+			stampedData[column] = value
+		}
+		if _, exists := stampedData[ownerColumn]; !exists {
+			columns = append(columns, ownerColumn)
+			sort.Strings(columns)
+		}
+		stampedData[ownerColumn] = owner
+		entry.Data = stampedData
+	}
+	entryData, err := validateTypes(transaction, entry.Table, entry.Data)
+	if err != nil {
+		return "", err
+	}
+	storage, err := entryStorage(transaction, entry.Table)
+	if err != nil {
+		return "", err
+	}
+
+	// Uniqueness is left to the constraints of the table (Mapped to 409 and 422 by operationErrorStatus)
+	values := make([]interface{}, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, storedValue(storage, column, entryData[column]))
+	}
+
+	insertColumns := append([]string{}, columns...)
+	if storage != nil {
+		for _, column := range columns {
+			if storage.BlindIndexed[column] {
+				insertColumns = append(insertColumns, globals.BlindIndexColumnPrefix+column)
+				values = append(values, blindIndexValue(entryData[column]))
+			}
+		}
+	}
+
+	entryID := globals.TableEntryIDPrefix + generator.RandomString(globals.TableEntryIDLength) + globals.TableEntryIDSuffix
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(insertColumns)+1), ", ")
+	query := "INSERT INTO " + entry.Table + " (" + globals.TableEntryIDColumnName + ", " + strings.Join(insertColumns, ", ") + ") VALUES (" + placeholders + ")"
+	log.Debug("Create query: " + query)
+	_, err = transaction.Execute(query, append([]interface{}{entryID}, values...)...)
+	if err != nil {
+		return "", err
+	}
+	return entryID, nil
+}
+
+// updateEntries updates the entries matching the filter of the entry as part of the transaction and returns their sys_eids
+func updateEntries(transaction *sqlWrapper.Transaction, permissions *authPkg.Permissions, entry globals.EntryRequestEntry) ([]string, error) {
+	err := validateTable(entry.Table)
+	if err != nil {
+		return nil, err
+	}
+	updateFields, ok := entry.Data[globals.RequestUpdateParameter].(map[string]interface{})
+	if !ok || len(updateFields) == 0 {
+		return nil, errors.New(globals.ErrorInvalidColumnName + ": " + globals.RequestUpdateParameter + " must contain at least one column to update")
+	}
+	fields := make([]string, 0, len(updateFields))
+	for field := range updateFields {
+		if !filter.ValidColumn(field) || field == globals.TableEntryIDColumnName {
+			return nil, errors.New(globals.ErrorInvalidColumnName + ": " + field)
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	entryFilterNode, err := entryFilter(entry)
+	if err != nil {
+		return nil, err
+	}
+	err = permissions.Check(entry.Table, globals.RoleActionUpdate, append(append([]string{}, fields...), filter.Columns(entryFilterNode)...))
+	if err != nil {
+		return nil, err
+	}
+	ownerColumn, _, err := policyOwner(transaction, permissions, entry.Table)
+	if err != nil {
+		return nil, err
+	}
+	if _, exists := updateFields[ownerColumn]; exists {
+		return nil, errors.New(globals.ErrorPermissionDenied + ": owner column (" + ownerColumn + ") of table (" + entry.Table + ") can not be updated")
+	}
+	updateFields, err = validateTypes(transaction, entry.Table, updateFields)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := entryStorage(transaction, entry.Table)
+	if err != nil {
+		return nil, err
+	}
+	setClauses := make([]string, 0, len(fields))
+	setArgs := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		setClauses = append(setClauses, field+" = ?")
+		setArgs = append(setArgs, storedValue(storage, field, updateFields[field]))
+		if storage != nil && storage.BlindIndexed[field] {
+			setClauses = append(setClauses, globals.BlindIndexColumnPrefix+field+" = ?")
+			setArgs = append(setArgs, blindIndexValue(updateFields[field]))
+		}
+	}
+
+	whereClause, filterArgs, err := filter.WhereStored(entryFilterNode, storage)
+	if err != nil {
+		return nil, err
+	}
+	whereClause, filterArgs, err = policyWhere(transaction, permissions, entry.Table, whereClause, filterArgs)
+	if err != nil {
+		return nil, err
+	}
+	storedEntryIDs, entryIDs, err := selectEntryIDs(transaction, "SELECT "+globals.TableEntryIDColumnName+" FROM "+entry.Table+whereClause, filterArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry is updated by its sys_eid so that the previous values are recorded per entry
+	// The stored sys_eid is matched as is since it may still be encrypted with a previous key (See: data.Reencrypt)
+	query := "UPDATE " + entry.Table + " SET " + strings.Join(setClauses, ", ") + " WHERE " + globals.TableEntryIDColumnName + " = ?"
+	log.Debug("Update query: " + query)
+	for _, storedEntryID := range storedEntryIDs {
+		_, err = transaction.Execute(query, append(append([]interface{}{}, setArgs...), data.Plaintext(storedEntryID))...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entryIDs, nil
+}
+
+// deleteEntries deletes the entries matching the filter as part of the transaction and returns their sys_eids
+func deleteEntries(transaction *sqlWrapper.Transaction, permissions *authPkg.Permissions, table string, node *filter.Node) ([]string, error) {
+	err := validateTable(table)
+	if err != nil {
+		return nil, err
+	}
+	err = permissions.Check(table, globals.RoleActionDelete, filter.Columns(node))
+	if err != nil {
+		return nil, err
+	}
+	whereClause, args, err := entryWhere(transaction, table, node)
+	if err != nil {
+		return nil, err
+	}
+	if whereClause == "" {
+		return nil, errors.New(globals.ErrorInvalidFilter + ": at least one filter is required to delete entries")
+	}
+	whereClause, args, err = policyWhere(transaction, permissions, table, whereClause, args)
+	if err != nil {
+		return nil, err
+	}
+	// The stored sys_eids are used as is since DELETE arguments are not processed
+	storedEntryIDs, entryIDs, err := selectEntryIDs(transaction, "SELECT "+globals.TableEntryIDColumnName+" FROM "+table+whereClause, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(storedEntryIDs) == 0 {
+		return entryIDs, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(storedEntryIDs)), ", ")
+	query := "DELETE FROM " + table + " WHERE " + globals.TableEntryIDColumnName + " IN (" + placeholders + ")"
+	log.Debug("Delete query: " + query)
+	_, err = transaction.Execute(query, storedEntryIDs...)
+	if err != nil {
+		return nil, err
+	}
+	return entryIDs, nil
+}
+
+// selectEntryIDs runs a select query for sys_eids as part of the transaction and returns them as they are stored and decrypted
+func selectEntryIDs(transaction *sqlWrapper.Transaction, query string, args []interface{}) ([]interface{}, []string, error) {
+	rows, err := transaction.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var storedEntryIDs []interface{}
+	var entryIDs []string
+	for rows.Next() {
+		var storedEntryID string
+		err = rows.Scan(&storedEntryID)
+		if err != nil {
+			return nil, nil, err
+		}
+		storedEntryIDs = append(storedEntryIDs, storedEntryID)
+		entryIDs = append(entryIDs, fmt.Sprint(data.Process(storedEntryID)))
+	}
+	return storedEntryIDs, entryIDs, rows.Err()
+}
+
+// operationErrorStatus returns the HTTP status code and message for an error returned by an entry operation
+func operationErrorStatus(err error) (int, string) {
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, globals.ErrorInvalidFilter),
+		strings.HasPrefix(message, globals.ErrorInvalidDataType),
+		strings.HasPrefix(message, globals.ErrorInvalidColumnName),
+		strings.HasPrefix(message, globals.ErrorInvalidTableName),
+		strings.HasPrefix(message, globals.ErrorInvalidOperation),
+		strings.HasPrefix(message, "no such table"),
+		strings.HasPrefix(message, "no such column"),
+		strings.Contains(message, "has no column named"):
+		return http.StatusBadRequest, message
+	case strings.HasPrefix(message, globals.ErrorPermissionDenied):
+		return http.StatusForbidden, message
+	case strings.HasPrefix(message, globals.ErrorConstraintUnique),
+		strings.HasPrefix(message, globals.ErrorConstraintForeignKey):
+		return http.StatusConflict, message
+	case strings.HasPrefix(message, globals.ErrorConstraintNotNull),
+		strings.HasPrefix(message, globals.ErrorConstraintCheck):
+		return http.StatusUnprocessableEntity, message
+	case strings.HasPrefix(message, globals.ErrorTransactionNoEntry):
+		return http.StatusNotFound, "ENTRY_NOT_FOUND"
+	case strings.HasPrefix(message, globals.ErrorTransactionNotFound):
+		return http.StatusNotFound, message
+	case strings.HasPrefix(message, globals.ErrorNotExist):
+		return http.StatusNotFound, "DATABASE_NOT_FOUND"
+	case strings.HasPrefix(message, globals.ErrorDatabaseBusy):
+		return http.StatusServiceUnavailable, message
+	default:
+		return http.StatusInternalServerError, "INTERNAL_SERVER_ERROR"
+	}
+}
+
+// respondWithOperationError responds with the status matching the error of an entry operation
+// The index of the operation (or entry) which failed is included in the response (-1 when the transaction itself failed)
+func respondWithOperationError(r *http.Request, w http.ResponseWriter, correlationID string, requestIndex int, err error) {
+	status, message := operationErrorStatus(err)
+	log.Error("Operation " + fmt.Sprint(requestIndex) + " failed: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	responseData := map[string]interface{}{
+		"correlationID": correlationID,
+		"requestIndex":  requestIndex,
+	}
+	// The fields whose values do not match the type of their column are listed so that they can all be corrected at once
+	var typeError *data.TypeError
+	if errors.As(err, &typeError) {
+		responseData["fields"] = typeError.Fields
+	}
+	response := globals.Response{
+		Status:  "error",
+		Message: message,
+		Data:    responseData,
+	}
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"strings"
+
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
+)
+
+// readColumns checks that the selected, filtered, and sorted columns of the table can be read and returns the columns to select
+// Selecting every column (*) is limited to sys_eid and the granted columns when the read grant is limited to columns
+func readColumns(permissions *authPkg.Permissions, table, field string, node *filter.Node, sortColumns []string) (string, error) {
+	columns := append(filter.Columns(node), sortColumns...)
+	if field != "*" {
+		columns = append(columns, strings.Split(field, ",")...)
+	}
+	err := permissions.Check(table, globals.RoleActionRead, columns)
+	if err != nil {
+		return "", err
+	}
+	if field != "*" {
+		return field, nil
+	}
+	grantedColumns, limited := permissions.Columns(table, globals.RoleActionRead)
+	if !limited {
+		return field, nil
+	}
+	return strings.Join(append([]string{globals.TableEntryIDColumnName}, grantedColumns...), ","), nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"strings"
+
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+)
+
+// entryPolicy returns the row-level security policy of the table which applies to the user
+// Nil is returned when the table does not have a policy or the user sees every row
+func entryPolicy(q querier, permissions *authPkg.Permissions, table string) (*configuration.ConfigurationDatabaseEntryPolicy, error) {
+	if permissions.BypassesPolicies() {
+		return nil, nil
+	}
+	return q.Policy(table)
+}
+
+// policyWhere adds the condition of the policy of the table to the WHERE clause so that only the rows of the user are matched
+// The owner column is compared like an eq filter while the placeholders of a predicate are bound unencrypted as parameters
+func policyWhere(q querier, permissions *authPkg.Permissions, table, whereClause string, args []interface{}) (string, []interface{}, error) {
+	policy, err := entryPolicy(q, permissions, table)
+	if err != nil || policy == nil {
+		return whereClause, args, err
+	}
+	var condition string
+	var conditionArgs []interface{}
+	if policy.OwnerColumn != "" {
+		storage, err := entryStorage(q, table)
+		if err != nil {
+			return "", nil, err
+		}
+		condition, conditionArgs, err = filter.BuildStored(filter.Equal(policy.OwnerColumn, permissions.Attributes()[policy.Attribute]), storage)
+		if err != nil {
+			return "", nil, err
+		}
+	} else {
+		condition, conditionArgs = sqlWrapper.PolicyPredicate(policy.Predicate, permissions.Attributes())
+		for i, arg := range conditionArgs {
+			conditionArgs[i] = data.Plaintext(arg)
+		}
+	}
+	if whereClause == "" {
+		return " WHERE (" + condition + ")", conditionArgs, nil
+	}
+	return " WHERE (" + strings.TrimPrefix(whereClause, " WHERE ") + ") AND (" + condition + ")", append(append([]interface{}{}, args...), conditionArgs...), nil
+}
+
+// policyOwner returns the owner column of the policy of the table along with the value it holds for the user
+// An empty column is returned when the table does not have an owner column or the user sees every row
+func policyOwner(q querier, permissions *authPkg.Permissions, table string) (string, interface{}, error) {
+	policy, err := entryPolicy(q, permissions, table)
+	if err != nil || policy == nil || policy.OwnerColumn == "" {
+		return "", nil, err
+	}
+	return policy.OwnerColumn, permissions.Attributes()[policy.Attribute], nil
+}
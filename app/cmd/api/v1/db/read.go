@@ -7,13 +7,13 @@ import (
 	"strings"
 
 	"github.com/mitchs-dev/library-go/networking"
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
-	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
 	log "github.com/sirupsen/logrus"
 )
 
 func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
-	c.GetConfig()
 	page := r.URL.Query().Get("page")
 	limit := r.URL.Query().Get("limit")
 	sort := r.URL.Query().Get("sort")
@@ -33,76 +33,79 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 		err := json.NewEncoder(w).Encode(response)
 		if err != nil {
 			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
-			return
 		}
+		return
 	}
 
 	database := erb.Database
 
 	var data []map[string]interface{}
 
+	permissions, err := authPkg.UserPermissions(database, userID, r.Header.Get(globals.AuthenticationAuthorizationHeader))
+	if err != nil {
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+
+	reader, release, err := entryQuerier(r, database, userID)
+	if err != nil {
+		log.Error("Failed to open database for query: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+	defer release()
+
 	log.Debug("Query has " + fmt.Sprint(len(erb.Entries)) + " entries (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-	for _, entry := range erb.Entries {
+	for i, entry := range erb.Entries {
 		table := entry.Table
 		log.Info("Using database: " + database + "/" + table + " for query (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-		var field string
-		var filters string
-		for dataKey, dataValue := range entry.Data {
-			if dataKey == "__select" {
-				for _, selectFilter := range dataValue.([]interface{}) {
+		// System tables (Ex: users, sessions, API keys) are only reachable through the auth endpoints
+		err := validateTable(table)
+		if err != nil {
+			log.Error("Invalid table name: " + table + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			respondWithBadRequest(r, w, correlationID, err.Error())
+			return
+		}
+		field, err := entrySelect(entry)
+		if err != nil {
+			log.Error("Invalid select: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			respondWithBadRequest(r, w, correlationID, err.Error())
+			return
+		}
+		log.Debug("Field: " + field + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
-					filters = filters + selectFilter.(string) + ","
-				}
-				log.Debug("Select filters: " + filters + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-			} else {
-				for _, data := range dataValue.([]interface{}) {
-					field = dataKey
-					filters = filters + data.(string) + ","
-				}
-				log.Debug("Field: " + field + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
-			}
+		entryFilterNode, err := entryFilter(entry)
+		if err != nil {
+			log.Error("Invalid filter: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			respondWithBadRequest(r, w, correlationID, err.Error())
+			return
 		}
-		filters = strings.TrimSuffix(filters, ",")
-
-		var filterList []string
-		var params []interface{}
-
-		// Prepare the query with placeholders
-		field = strings.TrimPrefix(field, "\"")
-		field = strings.TrimSuffix(field, "\"")
-		field = strings.TrimPrefix(field, "'")
-		field = strings.TrimSuffix(field, "'")
-		query := "SELECT " + field + " FROM " + table
-		var args []interface{}
-
-		if filters != "" {
-			if strings.Contains(filters, "=") && !strings.Contains(filters, "'") {
-				for _, filter := range strings.Split(filters, ",") {
-					filter = strings.ReplaceAll(filter, "\"", "")
-					var key, value string
-					if strings.Contains(filter, "*") {
-						filter = strings.ReplaceAll(filter, "*", "%")
-						parts := strings.SplitN(filter, "=", 2)
-						key = parts[0]
-						value = parts[1]
-						filterList = append(filterList, key+" LIKE ?")
-					} else {
-						parts := strings.SplitN(filter, "=", 2)
-						key = parts[0]
-						value = parts[1]
-						filterList = append(filterList, key+" = ?")
-					}
-					params = append(params, value)
-				}
+		var orderBy string
+		if sort != "" {
+			orderBy, err = filter.OrderBy(sort)
+			if err != nil {
+				log.Error("Invalid sort: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+				respondWithBadRequest(r, w, correlationID, err.Error())
+				return
 			}
-			query = strings.Join(filterList, " AND ")
-			args = append(args, params...)
 		}
-		if sort != "" {
-			query += " ORDER BY " + sort
+		field, err = readColumns(permissions, table, field, entryFilterNode, filter.SortColumns(sort))
+		if err != nil {
+			respondWithOperationError(r, w, correlationID, i, err)
+			return
 		}
+		whereClause, args, err := entryWhere(reader, table, entryFilterNode)
+		if err == nil {
+			whereClause, args, err = policyWhere(reader, permissions, table, whereClause, args)
+		}
+		if err != nil {
+			respondWithOperationError(r, w, correlationID, i, err)
+			return
+		}
+
+		query := "SELECT " + field + " FROM " + table + whereClause + orderBy
 		if limit == "" && page != "" {
 			log.Error("Invalid query parameters - Both limit and page must be provided together when using page" + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 			response := globals.Response{
@@ -139,13 +142,7 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 			}
 			return
 		}
-		dbFilePath := c.Storage.Path + "/" + database + ".db"
-		wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
-		if err != nil {
-			log.Fatal("Error when creating database wrapper: " + err.Error())
-		}
-		defer wrapper.Close()
-		rows, err := wrapper.Query(query, args...)
+		rows, err := reader.Query(query, args...)
 		if err != nil {
 			var responseMessage string
 			var responseDataMap map[string]string
@@ -215,6 +212,10 @@ func Read(r *http.Request, w http.ResponseWriter, userID, correlationID string)
 			}
 			rowData := make(map[string]interface{})
 			for i, colName := range columns {
+				// Blind indexes are only used to filter
+				if strings.HasPrefix(colName, globals.BlindIndexColumnPrefix) {
+					continue
+				}
 				rowData[colName] = columnValues[i]
 			}
 			data = append(data, rowData)
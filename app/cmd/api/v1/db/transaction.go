@@ -0,0 +1,114 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	authPkg "github.com/mitchs-dev/simplQL/pkg/api/auth"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// Transaction runs an ordered list of create/update/delete operations in a single transaction
+// If any operation fails, every operation is rolled back
+func Transaction(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	var requestBody globals.TransactionRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		log.Error("Failed to decode request body: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithBadRequest(r, w, correlationID, "Invalid request body - Ensure that the request body is in the valid transaction format")
+		return
+	}
+	if requestBody.Database == "" {
+		log.Error("Database name is empty (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithBadRequest(r, w, correlationID, "Invalid database name - Ensure that the database name is not empty")
+		return
+	}
+	if len(requestBody.Operations) == 0 {
+		log.Error("No operations provided (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithBadRequest(r, w, correlationID, globals.ErrorInvalidOperation+": at least one operation is required")
+		return
+	}
+	log.Info("Using database: " + requestBody.Database + " for transaction (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+
+	permissions, err := authPkg.UserPermissions(requestBody.Database, userID, r.Header.Get(globals.AuthenticationAuthorizationHeader))
+	if err != nil {
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+
+	transaction, finish, err := entryTransaction(r, requestBody.Database, userID)
+	if err != nil {
+		log.Error("Failed to begin transaction: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+
+	var responseReceipt globals.TransactionResponse
+	for requestIndex, operation := range requestBody.Operations {
+		entryIDs, err := runOperation(transaction, permissions, operation)
+		if err != nil {
+			finish(err)
+			respondWithOperationError(r, w, correlationID, requestIndex, err)
+			return
+		}
+		log.Debug("Operation " + operation.Action + " on table: " + operation.Table + " affected " + fmt.Sprint(len(entryIDs)) + " entries (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		if entryIDs == nil {
+			entryIDs = []string{}
+		}
+		responseReceipt.Receipts = append(responseReceipt.Receipts, globals.TransactionResponseReceipt{
+			RequestIndex: requestIndex,
+			Action:       operation.Action,
+			Table:        operation.Table,
+			EntryIDs:     entryIDs,
+		})
+	}
+	err = finish(nil)
+	if err != nil {
+		log.Error("Failed to commit transaction: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+	log.Info("Transaction with " + fmt.Sprint(len(requestBody.Operations)) + " operations committed (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+
+	response := globals.Response{
+		Status:  "ok",
+		Message: "TRANSACTION_COMMITTED",
+		Data:    responseReceipt,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
+
+// runOperation runs a single operation of a transaction request and returns the affected sys_eids
+func runOperation(transaction *sqlWrapper.Transaction, permissions *authPkg.Permissions, operation globals.TransactionRequestOperation) ([]string, error) {
+	entry := globals.EntryRequestEntry{
+		Table:  operation.Table,
+		Data:   operation.Data,
+		Filter: operation.Filter,
+	}
+	switch operation.Action {
+	case globals.OperationActionCreate:
+		entryID, err := createEntry(transaction, permissions, entry)
+		if err != nil {
+			return nil, err
+		}
+		return []string{entryID}, nil
+	case globals.OperationActionUpdate:
+		return updateEntries(transaction, permissions, entry)
+	case globals.OperationActionDelete:
+		node, err := entryFilter(entry)
+		if err != nil {
+			return nil, err
+		}
+		return deleteEntries(transaction, permissions, operation.Table, node)
+	default:
+		return nil, errors.New(globals.ErrorInvalidOperation + ": unknown action (" + operation.Action + ") - Valid actions are: " + globals.OperationActionCreate + ", " + globals.OperationActionUpdate + ", " + globals.OperationActionDelete)
+	}
+}
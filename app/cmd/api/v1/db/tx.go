@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/transactions"
+	log "github.com/sirupsen/logrus"
+)
+
+// TxBegin opens a client-managed transaction and returns its ID
+// The ID is passed in the X-Transaction-ID header of subsequent db requests until the transaction is committed or rolled back
+func TxBegin(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database, ok := txDatabase(r, w, correlationID)
+	if !ok {
+		return
+	}
+	transactionID, err := transactions.Begin(database, userID)
+	if err != nil {
+		log.Error("Failed to begin transaction: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+	w.Header().Set(globals.TransactionHeaderID, transactionID)
+	response := globals.Response{
+		Status:  "success",
+		Message: "TRANSACTION_STARTED",
+		Data: map[string]string{
+			"correlationID": correlationID,
+			"transactionID": transactionID,
+			"idleTimeout":   globals.TransactionIdleTimeout.String(),
+		},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
+
+// TxCommit commits the client-managed transaction in the X-Transaction-ID header
+func TxCommit(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database, ok := txDatabase(r, w, correlationID)
+	if !ok {
+		return
+	}
+	transactionID := r.Header.Get(globals.TransactionHeaderID)
+	err := transactions.Commit(transactionID, database, userID)
+	if err != nil {
+		log.Error("Failed to commit transaction (" + transactionID + "): " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+	respondWithTxOutcome(r, w, correlationID, transactionID, "TRANSACTION_COMMITTED")
+}
+
+// TxRollback rolls back the client-managed transaction in the X-Transaction-ID header
+func TxRollback(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database, ok := txDatabase(r, w, correlationID)
+	if !ok {
+		return
+	}
+	transactionID := r.Header.Get(globals.TransactionHeaderID)
+	err := transactions.Rollback(transactionID, database, userID, errors.New("rolled back by request ("+correlationID+")"))
+	if err != nil {
+		log.Error("Failed to roll back transaction (" + transactionID + "): " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithOperationError(r, w, correlationID, -1, err)
+		return
+	}
+	respondWithTxOutcome(r, w, correlationID, transactionID, "TRANSACTION_ROLLED_BACK")
+}
+
+// txDatabase reads the database of a transaction request from its body
+func txDatabase(r *http.Request, w http.ResponseWriter, correlationID string) (string, bool) {
+	var requestBody globals.EntryRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil || requestBody.Database == "" {
+		log.Error("Invalid transaction request body (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+		respondWithBadRequest(r, w, correlationID, "Invalid request body - Ensure that the request body contains the database")
+		return "", false
+	}
+	return requestBody.Database, true
+}
+
+// respondWithTxOutcome responds with the outcome of a client-managed transaction
+func respondWithTxOutcome(r *http.Request, w http.ResponseWriter, correlationID, transactionID, outcome string) {
+	response := globals.Response{
+		Status:  "success",
+		Message: outcome,
+		Data: map[string]string{
+			"correlationID": correlationID,
+			"transactionID": transactionID,
+		},
+	}
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+func AlterTable(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	var requestBody alterTableRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		log.Error("Failed to unmarshal request body: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		w.WriteHeader(400)
+		response := globals.Response{
+			Status:  "error",
+			Message: "Invalid request body - Ensure that the request body is in the valid table alteration format",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
+
+	wrapper, err := openDatabase(requestBody.Database)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+
+	err = wrapper.AlterTable(requestBody.Table, requestBody.Steps, userID)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to alter table ("+requestBody.Table+")", correlationID)
+		return
+	}
+
+	description, err := wrapper.DescribeTable(requestBody.Table)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to describe table ("+requestBody.Table+")", correlationID)
+		return
+	}
+
+	w.WriteHeader(200)
+	response := globals.Response{
+		Status:  "success",
+		Message: "Table (" + requestBody.Table + ") altered",
+		Data:    description,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Altered table: " + requestBody.Database + "/" + requestBody.Table + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
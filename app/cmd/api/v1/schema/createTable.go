@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+func CreateTable(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	var requestBody createTableRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		log.Error("Failed to unmarshal request body: ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		w.WriteHeader(400)
+		response := globals.Response{
+			Status:  "error",
+			Message: "Invalid request body - Ensure that the request body is in the valid table creation format",
+			Data:    map[string]string{"correlationID": correlationID},
+		}
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+		}
+		return
+	}
+
+	wrapper, err := openDatabase(requestBody.Database)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+
+	err = wrapper.CreateTable(requestBody.Table, userID)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to create table ("+requestBody.Table.Name+")", correlationID)
+		return
+	}
+
+	w.WriteHeader(201)
+	response := globals.Response{
+		Status:  "success",
+		Message: "Table (" + requestBody.Table.Name + ") created",
+		Data:    map[string]string{"correlationID": correlationID, "table": requestBody.Table.Name},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Created table: " + requestBody.Database + "/" + requestBody.Table.Name + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
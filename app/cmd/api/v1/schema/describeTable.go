@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+func DescribeTable(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	table := r.URL.Query().Get("table")
+
+	wrapper, err := openDatabase(database)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+
+	description, err := wrapper.DescribeTable(table)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to describe table ("+table+")", correlationID)
+		return
+	}
+
+	response := globals.Response{
+		Status:  "success",
+		Message: "QUERY_SUCCESS",
+		Data:    description,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Debug("Described table: " + database + "/" + table + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+}
@@ -0,0 +1,39 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+func DropTable(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	table := r.URL.Query().Get("table")
+
+	wrapper, err := openDatabase(database)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+
+	err = wrapper.DropTable(table, userID)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to drop table ("+table+")", correlationID)
+		return
+	}
+
+	response := globals.Response{
+		Status:  "success",
+		Message: "Table (" + table + ") dropped",
+		Data:    map[string]string{"correlationID": correlationID, "table": table},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Dropped table: " + database + "/" + table + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
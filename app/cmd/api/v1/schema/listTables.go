@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+func ListTables(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+
+	wrapper, err := openDatabase(database)
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to open database", correlationID)
+		return
+	}
+	defer wrapper.Release()
+
+	tables, err := wrapper.ListTables()
+	if err != nil {
+		respondWithSchemaError(r, w, err, "Failed to list tables", correlationID)
+		return
+	}
+
+	response := globals.Response{
+		Status:  "success",
+		Message: "QUERY_SUCCESS",
+		Data:    map[string]interface{}{"tables": tables},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Debug("Listed tables for database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+}
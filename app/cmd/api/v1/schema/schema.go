@@ -0,0 +1,78 @@
+// schema provides the runtime table management actions for a database
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// createTableRequest is the request body for the create-table action
+type createTableRequest struct {
+	Database string                                              `json:"database" yaml:"database"`
+	Table    configuration.ConfigurationDatabaseEntryTablesEntry `json:"table" yaml:"table"`
+}
+
+// alterTableRequest is the request body for the alter-table action
+type alterTableRequest struct {
+	Database string                                                  `json:"database" yaml:"database"`
+	Table    string                                                  `json:"table" yaml:"table"`
+	Steps    []configuration.ConfigurationDatabaseEntryMigrationStep `json:"steps" yaml:"steps"`
+}
+
+// openDatabase returns the shared wrapper of an existing database - The caller releases it once it is done with it
+func openDatabase(database string) (*sqlWrapper.SQLiteWrapper, error) {
+	if database == "" {
+		return nil, errors.New("database name is required")
+	}
+	return sqlWrapper.Open(database)
+}
+
+// respondWithSchemaError maps a schema error to a response status code and writes the response
+func respondWithSchemaError(r *http.Request, w http.ResponseWriter, err error, message, correlationID string) {
+	status := 500
+	responseMessage := "INTERNAL_SERVER_ERROR"
+	switch {
+	case strings.Contains(err.Error(), globals.ErrorNotExist):
+		status = 404
+		responseMessage = message + " - The database or table does not exist"
+	case strings.Contains(err.Error(), globals.ErrorTableExists):
+		status = 409
+		responseMessage = message + " - The table already exists"
+	case strings.Contains(err.Error(), globals.ErrorConstraintForeignKey):
+		status = 409
+		responseMessage = message + " - The table is referenced by the entries of another table"
+	case strings.Contains(err.Error(), globals.ErrorInvalidTableName),
+		strings.Contains(err.Error(), globals.ErrorInvalidColumnName),
+		strings.Contains(err.Error(), globals.ErrorInvalidColumnType),
+		strings.Contains(err.Error(), globals.ErrorInvalidMigrationStep),
+		strings.Contains(err.Error(), globals.ErrorInvalidConstraint),
+		strings.Contains(err.Error(), globals.ErrorInvalidIndex),
+		strings.Contains(err.Error(), globals.ErrorInvalidPolicy),
+		strings.Contains(err.Error(), "database name is required"):
+		status = 400
+		responseMessage = message + " - " + err.Error()
+	}
+	if status == 500 {
+		log.Error(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	} else {
+		log.Warn(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "error",
+		Message: responseMessage,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
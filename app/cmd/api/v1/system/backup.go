@@ -0,0 +1,116 @@
+package system
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// Backup writes a consistent snapshot of a database to the backups directory or streams it as a download
+func Backup(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	download := strings.ToLower(r.URL.Query().Get("download")) == "true"
+
+	if download {
+		snapshotFile, err := os.CreateTemp("", database+"-*"+globals.BackupFileExtension)
+		if err != nil {
+			respondWithSystemError(r, w, err, "Failed to back up database ("+database+")", correlationID)
+			return
+		}
+		snapshotFile.Close()
+		defer os.Remove(snapshotFile.Name())
+		err = sqlWrapper.BackupDatabase(database, snapshotFile.Name(), userID)
+		if err != nil {
+			respondWithSystemError(r, w, err, "Failed to back up database ("+database+")", correlationID)
+			return
+		}
+		snapshotFile, err = os.Open(snapshotFile.Name())
+		if err != nil {
+			respondWithSystemError(r, w, err, "Failed to back up database ("+database+")", correlationID)
+			return
+		}
+		defer snapshotFile.Close()
+		w.Header().Set("Content-Type", globals.BackupContentType)
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+sqlWrapper.SnapshotName(database, false)+"\"")
+		w.WriteHeader(200)
+		_, err = io.Copy(w, snapshotFile)
+		if err != nil {
+			log.Error("Failed to stream snapshot: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			return
+		}
+		log.Info("Streamed snapshot of database: " + database + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+		return
+	}
+
+	backupPath, err := sqlWrapper.BackupPath()
+	if err != nil {
+		respondWithSystemError(r, w, err, "Failed to back up database ("+database+")", correlationID)
+		return
+	}
+	c := configuration.Current()
+	snapshot := sqlWrapper.SnapshotName(database, c.Storage.Backups.Compression == globals.BackupCompressionGzip)
+	err = sqlWrapper.BackupDatabase(database, filepath.Join(backupPath, snapshot), userID)
+	if err != nil {
+		respondWithSystemError(r, w, err, "Failed to back up database ("+database+")", correlationID)
+		return
+	}
+
+	response := globals.Response{
+		Status:  "success",
+		Message: "Database (" + database + ") backed up",
+		Data:    map[string]string{"correlationID": correlationID, "database": database, "snapshot": snapshot},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Backed up database: " + database + " to snapshot: " + snapshot + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
+
+// respondWithSystemError maps a backup, restore, or key rotation error to a response status code and writes the response
+func respondWithSystemError(r *http.Request, w http.ResponseWriter, err error, message, correlationID string) {
+	status := 500
+	responseMessage := "INTERNAL_SERVER_ERROR"
+	switch {
+	case strings.Contains(err.Error(), globals.ErrorNotExist):
+		status = 404
+		responseMessage = message + " - " + err.Error()
+	case strings.Contains(err.Error(), globals.ErrorDatabaseBusy),
+		strings.Contains(err.Error(), globals.ErrorKeyRotationInProgress):
+		status = 409
+		responseMessage = message + " - " + err.Error()
+	case strings.Contains(err.Error(), globals.ErrorInvalidDatabaseName),
+		strings.Contains(err.Error(), globals.ErrorSnapshotInvalid),
+		strings.Contains(err.Error(), globals.ErrorEncryptionDisabled):
+		status = 400
+		responseMessage = message + " - " + err.Error()
+	case strings.Contains(err.Error(), globals.ErrorSnapshotVersion),
+		strings.Contains(err.Error(), globals.ErrorSnapshotEncryption):
+		status = 422
+		responseMessage = message + " - " + err.Error()
+	}
+	if status == 500 {
+		log.Error(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	} else {
+		log.Warn(message+": ", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	w.WriteHeader(status)
+	response := globals.Response{
+		Status:  "error",
+		Message: responseMessage,
+		Data:    map[string]string{"correlationID": correlationID},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"strings"
 
 	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/backups"
+	"github.com/mitchs-dev/simplQL/pkg/database/rotation"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -27,11 +30,17 @@ func Healthz(r *http.Request, w http.ResponseWriter, userID, correlationID strin
 	}
 
 	log.Debug("Healthz requested (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	c := configuration.Current()
+	// Scheduled backups and key rotation are included so that failures can be alerted on
+	data := map[string]interface{}{"backups": backups.GetStatus()}
+	if c.Storage.Encryption.Enabled {
+		data["keyRotation"] = rotation.GetStatus()
+	}
 	w.WriteHeader(200)
 	response := globals.Response{
 		Status:  "ok",
 		Message: "Alive",
-		Data:    nil,
+		Data:    data,
 	}
 	err := json.NewEncoder(w).Encode(response)
 	if err != nil {
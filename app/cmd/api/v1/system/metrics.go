@@ -0,0 +1,15 @@
+package system
+
+import (
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Metrics returns the metrics of the server in the Prometheus exposition format
+func Metrics(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	log.Debug("Metrics requested (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+	metrics.Handler().ServeHTTP(w, r)
+}
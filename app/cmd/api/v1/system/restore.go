@@ -0,0 +1,38 @@
+package system
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	"github.com/mitchs-dev/simplQL/pkg/database/transactions"
+	log "github.com/sirupsen/logrus"
+)
+
+// Restore replaces a database with a snapshot from the backups directory
+func Restore(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	database := r.URL.Query().Get("database")
+	snapshot := r.URL.Query().Get("snapshot")
+
+	// Open transactions hold a connection to the database which is about to be replaced
+	err := transactions.Restore(database, func() error {
+		return sqlWrapper.RestoreDatabase(database, snapshot, userID)
+	})
+	if err != nil {
+		respondWithSystemError(r, w, err, "Failed to restore database ("+database+")", correlationID)
+		return
+	}
+
+	response := globals.Response{
+		Status:  "success",
+		Message: "Database (" + database + ") restored",
+		Data:    map[string]string{"correlationID": correlationID, "database": database, "snapshot": snapshot},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+	log.Info("Restored database: " + database + " from snapshot: " + snapshot + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+}
@@ -0,0 +1,33 @@
+package system
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mitchs-dev/library-go/networking"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/rotation"
+	log "github.com/sirupsen/logrus"
+)
+
+// RotateKey activates a new encryption key and re-encrypts every database with it in the background
+// Data encrypted with the previous keys stays readable until the re-encryption completes
+func RotateKey(r *http.Request, w http.ResponseWriter, userID, correlationID string) {
+	keyID, err := rotation.Rotate()
+	if err != nil {
+		respondWithSystemError(r, w, err, "Failed to rotate the encryption key", correlationID)
+		return
+	}
+	log.Info("Rotating the encryption key to: " + keyID + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + " U: " + userID + ")")
+
+	w.WriteHeader(202)
+	response := globals.Response{
+		Status:  "success",
+		Message: "KEY_ROTATION_STARTED",
+		Data:    map[string]interface{}{"correlationID": correlationID, "keyID": keyID, "rotation": rotation.GetStatus()},
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Error("Failed to encode response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+	}
+}
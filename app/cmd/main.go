@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/mitchs-dev/simplQL/pkg/api/requests"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/initalization"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/version"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	"github.com/mitchs-dev/simplQL/pkg/database/transactions"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -22,5 +29,34 @@ func main() {
 	} else {
 		log.Info(globals.ApplicationName + " (v" + version.SymanticString() + ")")
 	}
+	// Shut down gracefully once a termination signal is received
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	shutdownDone := make(chan struct{})
+	go func() {
+		shutdown(<-signals)
+		close(shutdownDone)
+	}()
 	requests.Handler()
+	<-shutdownDone
+}
+
+// shutdown stops accepting connections and waits for in-flight requests and open transactions up to the shutdown timeout
+// The WAL of every database is then checkpointed into its file before the databases are closed
+func shutdown(received os.Signal) {
+	log.Info("Received signal (" + received.String() + ") - Shutting down within " + globals.ServerShutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), globals.ServerShutdownTimeout)
+	defer cancel()
+	err := requests.Shutdown(ctx)
+	if err != nil {
+		log.Warn("Stopped waiting for in-flight requests: " + err.Error())
+	} else {
+		log.Info("In-flight requests drained")
+	}
+	// Open transactions can no longer be committed as no more requests are accepted
+	rolledBack, remaining := transactions.RollbackAll(ctx, errors.New(globals.ErrorShuttingDown))
+	if rolledBack > 0 || remaining > 0 {
+		log.Warn("Rolled back open transactions: " + fmt.Sprint(rolledBack) + " | Still in use: " + fmt.Sprint(remaining))
+	}
+	sqlWrapper.CloseDatabases()
 }
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiKeyLastUsedResolution is how stale the last used timestamp of an API key may get before it is written again
+var apiKeyLastUsedResolution = time.Minute
+
+// IsAPIKey returns true if the value of the authentication header is an API key
+func IsAPIKey(value string) bool {
+	return strings.HasPrefix(strings.ToLower(value), strings.ToLower(globals.AuthenticationAuthorizationHeaderAPIKeyPrefix))
+}
+
+// GenerateAPIKey generates the ID and secret of a new API key along with the hash of the secret which is stored
+// Secrets are random so a single SHA-256 is enough to store them without slowing down every request like a password hash would
+func GenerateAPIKey() (string, string, string, error) {
+	idBytes := make([]byte, globals.APIKeyIDLength/2)
+	secretBytes := make([]byte, globals.APIKeySecretLength)
+	_, err := rand.Read(idBytes)
+	if err == nil {
+		_, err = rand.Read(secretBytes)
+	}
+	if err != nil {
+		return "", "", "", errors.New("failed to generate API key: " + err.Error())
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	return hex.EncodeToString(idBytes), secret, hashSecret(secret), nil
+}
+
+// CheckAPIKey checks the API key of the authentication header and returns boolean, the id of its user, and the roles the key may use
+// Keys which are expired or used from an address which is not allowed are treated like unknown keys
+func CheckAPIKey(value, database, ipAddress string) (bool, string, []string, error) {
+	c := configuration.Current()
+	if !c.Session.APIKeys.Enabled {
+		return false, "", nil, errors.New(globals.ErrorAPIKeysDisabled)
+	}
+	id, secret, err := splitAPIKey(value)
+	if err != nil {
+		return false, "", nil, err
+	}
+
+	// Check if the database exists
+	dbFilePath := c.Storage.Path + "/" + database + ".db"
+	if !processor.DirectoryOrFileExists(dbFilePath) {
+		return false, "", nil, fmt.Errorf("Database (" + database + ") does not exist")
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return false, "", nil, fmt.Errorf("Error when opening database: " + err.Error())
+	}
+	defer wrapper.Release()
+	key, secretHash, exists, err := wrapper.APIKey(id)
+	if err != nil {
+		return false, "", nil, fmt.Errorf("Failed to read API key: " + err.Error())
+	}
+	if !exists || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(secretHash)) != 1 {
+		log.Debug("API key does not exist or its secret does not match: " + id)
+		return false, "", nil, nil
+	}
+	now := time.Now().UTC()
+	if key.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, key.ExpiresAt)
+		if err != nil || !now.Before(expiresAt) {
+			log.Warn("API key is expired: " + id + " (U: " + key.UserID + ")")
+			return false, "", nil, nil
+		}
+	}
+	if !apiKeyAddressAllowed(key.AllowedIPs, ipAddress) {
+		log.Warn("API key (" + id + ") used from an address which is not allowed: " + ipAddress + " (U: " + key.UserID + ")")
+		return false, "", nil, nil
+	}
+
+	userRoles, exists, err := userRoles(wrapper, key.UserID)
+	if err != nil {
+		return false, "", nil, err
+	}
+	if !exists {
+		log.Debug("User of API key does not exist: " + key.UserID)
+		return false, "", nil, nil
+	}
+	roles := apiKeyRoles(key, userRoles)
+	if len(roles) == 0 {
+		return false, "", nil, nil
+	}
+
+	lastUsedAt, err := time.Parse(time.RFC3339, key.LastUsedAt)
+	if err != nil || now.Sub(lastUsedAt) >= apiKeyLastUsedResolution {
+		err = wrapper.TouchAPIKey(id, now.Format(time.RFC3339))
+		if err != nil {
+			log.Error("Failed to record use of API key (" + id + "): " + err.Error())
+		}
+	}
+	return true, key.UserID, roles, nil
+}
+
+// APIKeyRoles returns the roles of the user which the API key of the authentication header may use
+// The roles are returned as is when the header is not an API key
+func APIKeyRoles(wrapper *sqlWrapper.SQLiteWrapper, value string, roles []string) ([]string, error) {
+	if !IsAPIKey(value) {
+		return roles, nil
+	}
+	id, _, err := splitAPIKey(value)
+	if err != nil {
+		return nil, err
+	}
+	key, _, exists, err := wrapper.APIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New(globals.ErrorAPIKeyNotFound + ": " + id)
+	}
+	return apiKeyRoles(key, roles), nil
+}
+
+// ValidAPIKeyRoles returns an error if any of the roles is not one of the roles of the user
+func ValidAPIKeyRoles(roles, userRoles []string) error {
+	for _, role := range roles {
+		if !containsFold(userRoles, role) {
+			return errors.New(globals.ErrorInvalidAPIKey + ": role (" + role + ") is not a role of the user")
+		}
+	}
+	return nil
+}
+
+// ValidAPIKeyAddresses returns an error if any of the allowed addresses is not an IP address or CIDR range
+func ValidAPIKeyAddresses(addresses []string) error {
+	for _, address := range addresses {
+		if net.ParseIP(address) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			return errors.New(globals.ErrorInvalidAPIKey + ": allowed address (" + address + ") is not an IP address or CIDR range")
+		}
+	}
+	return nil
+}
+
+// UserRoles returns the roles of the user and whether the user exists
+func UserRoles(database, userID string) ([]string, bool, error) {
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return nil, false, err
+	}
+	defer wrapper.Release()
+	return userRoles(wrapper, userID)
+}
+
+// userRoles reads the roles of the user from the users table
+func userRoles(wrapper *sqlWrapper.SQLiteWrapper, userID string) ([]string, bool, error) {
+	_, roles, exists, err := userIdentity(wrapper, userID)
+	return roles, exists, err
+}
+
+// userIdentity reads the name and roles of the user from the users table
+func userIdentity(wrapper *sqlWrapper.SQLiteWrapper, userID string) (string, []string, bool, error) {
+	idCondition, idArgs := UserLookup(globals.UserEntryIDColumnName, userID)
+	rows, err := wrapper.Query("SELECT "+globals.UserNameColumnName+","+globals.UserRolesColumnName+" FROM "+globals.UsersTable+" WHERE "+idCondition, idArgs...)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("Failed to execute select query: " + err.Error())
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", nil, false, rows.Err()
+	}
+	var name, rolesAsString string
+	err = rows.Scan(&name, &rolesAsString)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("Failed to scan row: " + err.Error())
+	}
+	roles, _ := data.Process(rolesAsString).([]string)
+	return fmt.Sprint(data.Process(name)), roles, true, nil
+}
+
+// apiKeyRoles returns the roles of the key which the user still has - Every role of the user is used when the key does not list any
+func apiKeyRoles(key sqlWrapper.APIKey, userRoles []string) []string {
+	if len(key.Roles) == 0 {
+		return userRoles
+	}
+	var roles []string
+	for _, role := range key.Roles {
+		if containsFold(userRoles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// apiKeyAddressAllowed returns true if the key may be used from the address - Keys without allowed addresses may be used from anywhere
+func apiKeyAddressAllowed(allowedIPs []string, ipAddress string) bool {
+	if len(allowedIPs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range allowedIPs {
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil {
+			if allowedIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(allowed)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAPIKey returns the ID and secret of the API key of the authentication header
+func splitAPIKey(value string) (string, string, error) {
+	value = strings.TrimSpace(value[len(globals.AuthenticationAuthorizationHeaderAPIKeyPrefix):])
+	id, secret, found := strings.Cut(value, globals.APIKeySeparator)
+	if !found || id == "" || secret == "" {
+		return "", "", errors.New("API key is not in the correct format (<id>" + globals.APIKeySeparator + "<secret>)")
+	}
+	return id, secret, nil
+}
+
+// hashSecret returns the hash of the secret of an API key or refresh token which is stored
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// containsFold returns true if the value is in the list ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,14 +2,17 @@
 package auth
 
 import (
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
-	"github.com/mitchs-dev/library-go/encryption"
 	"github.com/mitchs-dev/library-go/processor"
 	"github.com/mitchs-dev/library-go/streaming"
+	passwordPkg "github.com/mitchs-dev/simplQL/pkg/api/auth/password"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	"github.com/mitchs-dev/simplQL/pkg/database/data"
@@ -20,26 +23,41 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-var c configuration.Configuration
+// RunAuthChecks will check the authentication header and then check if the user exists via username and password, JWT, or API key and return a boolean, and the user's id
+// The IP address is checked against the allowed addresses of an API key
+func RunAuthChecks(value, database, correlationID, ipAddress string, roleCheckList []string) (string, error) {
 
-// RunAuthChecks will check the authentication header and then check if the user exists via username and password or JWT and return a boolean, and the user's id
-func RunAuthChecks(value, database, correlationID string, roleCheckList []string) (string, error) {
-
-	c.GetConfig()
 	log.Debug("Running authentication checks (C: " + correlationID + ")")
 
-	// Get the name, password, and JWT from the authentication header
-	name, password, jwt, err := AuthenticationHeaderData(value, correlationID)
-	if err != nil {
-		return "", fmt.Errorf("failed to get authentication header data: " + err.Error())
-	}
-
 	var (
 		userExists bool
 		userID     string
 		roles      []string
+		name       string
+		password   string
+		jwt        string
+		err        error
 	)
 
+	if IsAPIKey(value) {
+		// API keys are checked on their own as they are not understood by AuthenticationHeaderData
+		log.Debug("Checking if user exists via API key (C: " + correlationID + ")")
+		userExists, userID, roles, err = CheckAPIKey(value, database, ipAddress)
+		if err != nil {
+			return "", fmt.Errorf("failed to check if user exists via API key: " + err.Error())
+		}
+		if !userExists {
+			log.Debug("User does not exist via API key (C: " + correlationID + ")")
+			return "", errors.New(globals.ErrorAuthenticationUserNotFound)
+		}
+	} else {
+		// Get the name, password, and JWT from the authentication header
+		name, password, jwt, err = AuthenticationHeaderData(value, correlationID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get authentication header data: " + err.Error())
+		}
+	}
+
 	// Check if the user exists via username and password
 	if name != "" && password != "" {
 		log.Debug("Checking if user exists via username and password (C: " + correlationID + ")")
@@ -73,22 +91,10 @@ func RunAuthChecks(value, database, correlationID string, roleCheckList []string
 	// Check if the user has the required roles
 	if len(roleCheckList) != 0 {
 		log.Debug("Checking if user has the required roles (C: " + correlationID + ")")
-		for _, userRole := range roles {
-			log.Debug("Checking user role: " + userRole + " (C: " + correlationID + ")")
-			if strings.Contains(strings.ToLower(userRole), strings.ToLower(globals.RolesSystemAdmin)) {
-				log.Debug("User is a system admin and has all roles (C: " + correlationID + ")")
-				log.Info("User authenticated: " + userID + " (C: " + correlationID + ")")
-				return userID, nil
-			}
-			for _, checkRole := range roleCheckList {
-				checkRole = strings.ToLower(globals.SystemRolePrefix + checkRole)
-				log.Debug("Checking required role: " + checkRole + " (C: " + correlationID + ")")
-				if checkRole == strings.ToLower(userRole) {
-					log.Debug("User has the required role: " + checkRole + " (C: " + correlationID + ")")
-					log.Info("User authenticated: " + userID + " (C: " + correlationID + ")")
-					return userID, nil
-				}
-			}
+		if hasRole(roles, roleCheckList) {
+			log.Debug("User has a required role (C: " + correlationID + ")")
+			log.Info("User authenticated: " + userID + " (C: " + correlationID + ")")
+			return userID, nil
 		}
 		log.Debug("User does not have any required roles (C: " + correlationID + ")")
 		return userID, errors.New(globals.ErrorAuthenticationNoRoles)
@@ -100,6 +106,39 @@ func RunAuthChecks(value, database, correlationID string, roleCheckList []string
 
 }
 
+// CheckSuperAdmin will check the authentication header against the server-level super-admin and return the super-admin's id
+func CheckSuperAdmin(value, correlationID string) (string, error) {
+	c := configuration.Current()
+	log.Debug("Running super-admin authentication checks (C: " + correlationID + ")")
+
+	superAdminName := c.Session.SuperAdmin.Name
+	if os.Getenv(globals.SessionSuperAdminNameEnvironmentVariable) != "" {
+		superAdminName = os.Getenv(globals.SessionSuperAdminNameEnvironmentVariable)
+	}
+	superAdminPassword := c.Session.SuperAdmin.Password
+	if os.Getenv(globals.SessionSuperAdminPasswordEnvironmentVariable) != "" {
+		superAdminPassword = os.Getenv(globals.SessionSuperAdminPasswordEnvironmentVariable)
+	}
+	if superAdminName == "" || superAdminPassword == "" {
+		log.Warn("Super-admin credentials are not configured - Super-admin requests are disabled (C: " + correlationID + ")")
+		return "", errors.New(globals.ErrorAuthenticationSuperAdminDisabled)
+	}
+
+	// Only Basic authentication is supported for the super-admin
+	name, password, _, err := AuthenticationHeaderData(value, correlationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get authentication header data: " + err.Error())
+	}
+	nameMatches := subtle.ConstantTimeCompare([]byte(name), []byte(superAdminName)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(superAdminPassword)) == 1
+	if name == "" || !nameMatches || !passwordMatches {
+		log.Debug("Super-admin credentials do not match (C: " + correlationID + ")")
+		return "", errors.New(globals.ErrorAuthenticationUserNotFound)
+	}
+	log.Info("Super-admin authenticated (C: " + correlationID + ")")
+	return globals.SuperAdminUserID, nil
+}
+
 type AuthRequestBody globals.AuthRequestBody
 
 // GetAuthRequest will unmarshal the request body as JSON or YAML and return the request body
@@ -108,6 +147,8 @@ func (authItem *AuthRequestBody) GetAuthRequest(authRequestBody []byte, correlat
 		log.Warn("Request body is empty (C: " + correlationID + ")")
 		return nil
 	}
+	// Reset the request body so that values from a previous request are not reused
+	*authItem = AuthRequestBody{}
 	// Check if the request body is formatted as JSON
 	err := json.Unmarshal(authRequestBody, authItem)
 	// Check if the request body is formatted as JSON
@@ -129,7 +170,7 @@ func (authItem *AuthRequestBody) GetAuthRequest(authRequestBody []byte, correlat
 
 // Reads the value of the authentication header and returns name, password, and JWT
 func AuthenticationHeaderData(value, correlationID string) (string, string, string, error) {
-	c.GetConfig()
+	c := configuration.Current()
 	if value == "" {
 		return "", "", "", fmt.Errorf("authentication header is empty")
 	}
@@ -176,7 +217,7 @@ func AuthenticationHeaderData(value, correlationID string) (string, string, stri
 
 // Check if the user exists via username and password and returns boolean, id, and roles
 func CheckBasic(name, password, database string) (bool, string, []string, error) {
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Checking if user (" + name + ") exists via username and password")
 
 	// Check if the database exists
@@ -186,41 +227,91 @@ func CheckBasic(name, password, database string) (bool, string, []string, error)
 		return false, "", nil, fmt.Errorf("Database (" + database + ") does not exist")
 	}
 	// Create a wrapper for the SQL query
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(database)
 	if err != nil {
-		return false, "", nil, fmt.Errorf("Error when creating database wrapper: " + err.Error())
+		return false, "", nil, fmt.Errorf("Error when opening database: " + err.Error())
 	}
-	defer wrapper.Close()
+	defer wrapper.Release()
 
-	// Check if the user exists
-	query := "SELECT " + globals.UserEntryIDColumnName + "," + globals.UserRolesColumnName + " FROM " + globals.UsersTable + " WHERE " + globals.UserNameColumnName + " = ? AND " + globals.UserPasswordColumnName + " = ?"
-	rows, err := wrapper.Query(query, name, password)
+	// Check if the user exists - The password is verified against the stored hash rather than in the query
+	nameCondition, nameArgs := UserLookup(globals.UserNameColumnName, name)
+	query := "SELECT " + globals.UserEntryIDColumnName + "," + globals.UserRolesColumnName + "," + globals.UserPasswordColumnName + " FROM " + globals.UsersTable + " WHERE " + nameCondition
+	rows, err := wrapper.Query(query, nameArgs...)
 	if err != nil {
 		return false, "", nil, fmt.Errorf("Failed to execute select query: " + err.Error())
 	}
 	defer rows.Close()
-	var rolesAsString string
-	var id string
+	var (
+		rolesAsString  string
+		id             string
+		storedPassword sql.NullString
+		matchedID      string
+		matchedStored  string
+		matchedRoles   []string
+		upgrade        bool
+	)
 
 	for rows.Next() {
-		if err := rows.Scan(&id, &rolesAsString); err != nil {
+		if err := rows.Scan(&id, &rolesAsString, &storedPassword); err != nil {
 			return false, "", nil, fmt.Errorf("Failed to scan row: " + err.Error())
 		}
+		match, needsUpgrade := passwordPkg.Verify(password, fmt.Sprint(data.Process(storedPassword.String)))
+		if !match {
+			continue
+		}
+		storedID := id
 		id = data.Process(id).(string)
 		log.Debug("User exists: " + name + "(" + id + ")")
 		roles := data.Process(rolesAsString).([]string)
 		if len(roles) != 0 {
-			return true, id, roles, nil
+			matchedID, matchedStored, matchedRoles, upgrade = id, storedID, roles, needsUpgrade
+			break
 		}
 	}
-	log.Debug("User does not exist: " + name)
+	rows.Close()
+	if matchedID == "" {
+		log.Debug("User does not exist: " + name)
+		roles := []string{}
+		return false, "", roles, nil
+	}
 
-	roles := []string{}
-	return false, id, roles, nil
+	// Passwords stored before hashing (or with outdated parameters) are replaced once the user successfully authenticates
+	if upgrade {
+		err = upgradePassword(wrapper, matchedID, matchedStored, password)
+		if err != nil {
+			log.Error("Failed to upgrade password hash for user (" + matchedID + "): " + err.Error())
+		} else {
+			log.Info("Upgraded password hash for user (" + matchedID + ") in database: " + database)
+		}
+	}
+	return true, matchedID, matchedRoles, nil
+}
+
+// upgradePassword replaces the stored password of the user with a new hash
+// The row is matched by its ID as it is stored since it may still be encrypted with a previous key (See: data.StoredForms)
+func upgradePassword(wrapper *sqlWrapper.SQLiteWrapper, id, storedID, password string) error {
+	hash, err := passwordPkg.Hash(password)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE " + globals.UsersTable + " SET " + globals.UserPasswordColumnName + " = ? WHERE " + globals.UserEntryIDColumnName + " = ?"
+	_, err = wrapper.Execute(query, id, hash, data.Plaintext(storedID))
+	return err
+}
+
+// UserLookup returns the condition and arguments which match the column of the users table against the value
+// The value is matched in every form it may be stored in so that users whose row was not re-encrypted by a key rotation yet are found (See: data.StoredForms)
+func UserLookup(column, value string) (string, []interface{}) {
+	forms := data.StoredForms(value)
+	args := make([]interface{}, 0, len(forms))
+	for _, form := range forms {
+		args = append(args, data.Plaintext(form))
+	}
+	return column + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")", args
 }
 
 func CheckJWT(requestJWT, database string) (bool, string, string, []string, error) {
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Checking if user exists via JWT")
 
 	// Get the user ID from the token
@@ -238,35 +329,28 @@ func CheckJWT(requestJWT, database string) (bool, string, string, []string, erro
 
 	// Create a wrapper for the SQL query
 	log.Debug("Ensuring the token exists in the database")
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(database)
 	if err != nil {
-		return false, "", "", nil, fmt.Errorf("Error when creating database wrapper: " + err.Error())
+		return false, "", "", nil, fmt.Errorf("Error when opening database: " + err.Error())
 	}
-	defer wrapper.Close()
+	defer wrapper.Release()
 
-	// Check if the user exists
-	query := "SELECT token,sha256 FROM " + globals.JWTTable + " WHERE token = ? AND " + globals.UserEntryIDColumnName + " = ?"
-	rows, err := wrapper.Query(query, requestJWT, id)
+	// Check if the session of the token exists
+	session, err := JWTSession(requestJWT)
 	if err != nil {
-		return false, "", "", nil, fmt.Errorf("Failed to execute select query: " + err.Error())
+		return false, "", "", nil, err
 	}
-	var dbJWT string
-	var jwtSHA256 string
-	log.Debug("Checking if user exists via JWT")
-	if rows == nil {
-		log.Debug("No rows found in JWT table")
-		roles := []string{}
-		return false, id, "", roles, nil
+	storedSession, tokens, exists, err := wrapper.Session(session)
+	if err != nil {
+		return false, "", "", nil, fmt.Errorf("Failed to read session: " + err.Error())
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		if err := rows.Scan(&dbJWT, &jwtSHA256); err != nil {
-			return false, "", "", nil, fmt.Errorf("Failed to scan row: " + err.Error())
-		}
-		dbJWT = data.Process(dbJWT).(string)
-		jwtSHA256 = data.Process(jwtSHA256).(string)
+	if !exists || storedSession.UserID != id {
+		log.Debug("Session (" + session + ") of JWT does not exist")
+		roles := []string{}
+		return false, id, "", roles, errors.New(globals.ErrorNotExist)
 	}
+	dbJWT := tokens.Token
+	jwtSHA256 := tokens.TokenSHA256
 
 	if dbJWT == "" {
 		roles := []string{}
@@ -279,12 +363,12 @@ func CheckJWT(requestJWT, database string) (bool, string, string, []string, erro
 	}
 
 	log.Debug("JWT SHA256: " + jwtSHA256)
-	isValidToken, error := jwtLib.ValidateToken(requestJWT, dbJWT, jwtSHA256, GetJWTSigningKey(database))
-	if error != nil {
+	isValidToken, err := jwtLib.ValidateToken(requestJWT, dbJWT, jwtSHA256, GetJWTSigningKey(database))
+	if err != nil {
 		if strings.Contains(err.Error(), globals.ErrorAuthenticationJWTExpiredFromJWTLib) {
 			return false, "", "", nil, fmt.Errorf(globals.ErrorAuthenticationJWTExpired)
 		}
-		return false, "", "", nil, fmt.Errorf("Failed to validate token: " + error.Error())
+		return false, "", "", nil, fmt.Errorf("Failed to validate token: " + err.Error())
 	}
 	if !isValidToken {
 		return false, "", "", nil, fmt.Errorf("token is not valid")
@@ -293,8 +377,9 @@ func CheckJWT(requestJWT, database string) (bool, string, string, []string, erro
 	log.Debug("User exists via JWT: " + id)
 
 	// Get the roles for the user
-	query = "SELECT " + globals.UserNameColumnName + "," + globals.UserRolesColumnName + " FROM " + globals.UsersTable + " WHERE " + globals.UserEntryIDColumnName + " = ?"
-	rows, err = wrapper.Query(query, id)
+	idCondition, idArgs := UserLookup(globals.UserEntryIDColumnName, id)
+	query := "SELECT " + globals.UserNameColumnName + "," + globals.UserRolesColumnName + " FROM " + globals.UsersTable + " WHERE " + idCondition
+	rows, err := wrapper.Query(query, idArgs...)
 	if err != nil {
 		return false, "", "", nil, fmt.Errorf("Failed to execute select query: " + err.Error())
 	}
@@ -318,7 +403,7 @@ func CheckJWT(requestJWT, database string) (bool, string, string, []string, erro
 // This sets the issuer for the JWT
 func SetJWTIssuer(database string) string {
 
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Generating SimplQL ID for database: " + database)
 
 	// Check if the database exists
@@ -328,11 +413,11 @@ func SetJWTIssuer(database string) string {
 		return "NULL"
 	}
 	// Create a wrapper for the SQL query
-	wrapper, err := sqlWrapper.NewSQLiteWrapper(dbFilePath)
+	wrapper, err := sqlWrapper.Open(database)
 	if err != nil {
 		return "NULL"
 	}
-	defer wrapper.Close()
+	defer wrapper.Release()
 
 	// Check if the user exists
 	query := "SELECT version FROM " + globals.MetadataTable
@@ -349,15 +434,29 @@ func SetJWTIssuer(database string) string {
 			return "NULL"
 		}
 	}
-	return strings.ReplaceAll(globals.JWTIssuer, globals.SimplQLIdPlaceholder, database+"@v"+data.Process(version).(string))
+	return strings.ReplaceAll(globals.JWTIssuer, globals.SimplQLIdPlaceholder, database+"@v"+fmt.Sprint(data.Process(version)))
 }
 
-// Get the signing key for the JWT (encrypted value for the database name)
+// Get the signing key for the JWT (Derived from the key which was provided at startup and the database name)
 func GetJWTSigningKey(database string) string {
-	encryptedData, err := encryption.Encrypt(streaming.Encode(database), globals.EncryptionKey, globals.EncryptionIV)
-	if err != nil {
-		log.Error("Failed to encrypt the database name: " + err.Error())
+	return data.JWTSigningKey(database)
+}
+
+// hasRole checks if the user has one of the required roles - System admins have every role
+// Custom roles are let through for the custom role as what they grant is checked once the request is handled
+func hasRole(roles, roleCheckList []string) bool {
+	for _, userRole := range roles {
+		if strings.Contains(strings.ToLower(userRole), strings.ToLower(globals.RolesSystemAdmin)) {
+			return true
+		}
+		for _, checkRole := range roleCheckList {
+			if checkRole == globals.RolesCustom && strings.HasPrefix(strings.ToLower(userRole), globals.RolesCustomPrefix) {
+				return true
+			}
+			if strings.ToLower(globals.SystemRolePrefix+checkRole) == strings.ToLower(userRole) {
+				return true
+			}
+		}
 	}
-	log.Debug("Using signing key: " + encryptedData)
-	return encryptedData
+	return false
 }
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	passwordPkg "github.com/mitchs-dev/simplQL/pkg/api/auth/password"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUsersDuringKeyRotation(t *testing.T) {
+	exists, userID, _, err := CheckBasic(testUser, testPassword, testDatabase)
+	if err != nil || !exists {
+		t.Fatalf("failed to log in before the rotation (Exists: %v): %v", exists, err)
+	}
+	// The users table is left encrypted with the previous key as if the rotation had not reached it yet
+	key, err := data.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = data.ActivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		check func() (bool, error)
+	}{
+		{
+			name: "basic",
+			check: func() (bool, error) {
+				exists, id, _, err := CheckBasic(testUser, testPassword, testDatabase)
+				return exists && id == userID, err
+			},
+		},
+		{
+			name: "jwt",
+			check: func() (bool, error) {
+				grant, err := StartSession(testDatabase, userID, testUser)
+				if err != nil {
+					return false, err
+				}
+				valid, id, name, _, err := CheckJWT(grant.JWT, testDatabase)
+				return valid && id == userID && name == testUser, err
+			},
+		},
+		{
+			name: "permissions",
+			check: func() (bool, error) {
+				permissions, err := UserPermissions(testDatabase, userID, "")
+				if err != nil {
+					return false, err
+				}
+				return permissions.Admin(), nil
+			},
+		},
+		{
+			name: "identity",
+			check: func() (bool, error) {
+				wrapper, err := sqlWrapper.Open(testDatabase)
+				if err != nil {
+					return false, err
+				}
+				defer wrapper.Release()
+				name, _, exists, err := userIdentity(wrapper, userID)
+				return exists && name == testUser, err
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := test.check()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !found {
+				t.Error("user was not found while its row is encrypted with the previous key")
+			}
+		})
+	}
+}
+
+func TestPasswordUpgrade(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		stored string
+	}{
+		{name: "plaintext", stored: testPassword},
+		{name: "bcrypt", stored: string(bcryptHash)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setStoredPassword(t, test.stored)
+			if stored := storedPassword(t); stored != test.stored {
+				t.Fatalf("stored password = %s, want %s", stored, test.stored)
+			}
+			exists, _, _, err := CheckBasic(testUser, testPassword, testDatabase)
+			if err != nil || !exists {
+				t.Fatalf("failed to log in (Exists: %v): %v", exists, err)
+			}
+			stored := storedPassword(t)
+			if !strings.HasPrefix(stored, globals.PasswordHashArgon2idPrefix) {
+				t.Fatalf("stored password (%s) was not upgraded to an argon2id hash", stored)
+			}
+			match, upgrade := passwordPkg.Verify(testPassword, stored)
+			if !match || upgrade {
+				t.Errorf("Verify() of the upgraded hash = (%v, %v), want (true, false)", match, upgrade)
+			}
+		})
+	}
+}
+
+// setStoredPassword replaces the stored password of the test user as it would have been stored by an older version of the server
+func setStoredPassword(t *testing.T, stored string) {
+	t.Helper()
+	wrapper, err := sqlWrapper.Open(testDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrapper.Release()
+	nameCondition, nameArgs := UserLookup(globals.UserNameColumnName, testUser)
+	query := "UPDATE " + globals.UsersTable + " SET " + globals.UserPasswordColumnName + " = ? WHERE " + nameCondition
+	_, err = wrapper.Execute(query, globals.SystemUserID, append([]interface{}{stored}, nameArgs...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedPassword reads the decrypted stored password of the test user
+func storedPassword(t *testing.T) string {
+	t.Helper()
+	wrapper, err := sqlWrapper.Open(testDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrapper.Release()
+	nameCondition, nameArgs := UserLookup(globals.UserNameColumnName, testUser)
+	var stored string
+	err = wrapper.QueryRow("SELECT "+globals.UserPasswordColumnName+" FROM "+globals.UsersTable+" WHERE "+nameCondition, nameArgs...).Scan(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprint(data.Process(stored))
+}
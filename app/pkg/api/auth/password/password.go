@@ -0,0 +1,130 @@
+// password hashes and verifies the passwords stored in the users table
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// params are the argon2id parameters which are recorded alongside each hash
+type params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+// slots limits how many hashes are computed at once (See: globals.PasswordHashConcurrency)
+// Requests which authenticate with a password wait for a slot rather than allocating the memory of another hash
+var slots = make(chan struct{}, globals.PasswordHashConcurrency)
+
+// idKey derives an argon2id key once a slot is free
+func idKey(password string, salt []byte, p params, keyLength uint32) []byte {
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	return argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, keyLength)
+}
+
+// currentParams returns the parameters which new hashes are created with
+func currentParams() params {
+	return params{
+		memory:      globals.PasswordHashMemory,
+		iterations:  globals.PasswordHashIterations,
+		parallelism: globals.PasswordHashParallelism,
+	}
+}
+
+// Hash returns a salted argon2id hash of the password in the PHC string format
+// Ex: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func Hash(password string) (string, error) {
+	salt := make([]byte, globals.PasswordHashSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+	p := currentParams()
+	key := idKey(password, salt, p, globals.PasswordHashKeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		globals.PasswordHashArgon2idPrefix,
+		argon2.Version,
+		p.memory,
+		p.iterations,
+		p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// IsHash returns true if the stored password is a hash rather than a plaintext password
+func IsHash(stored string) bool {
+	return strings.HasPrefix(stored, globals.PasswordHashArgon2idPrefix) || isBcrypt(stored)
+}
+
+// Verify compares the password against the stored value and returns whether it matches
+// The second value is true when the stored value should be replaced with a new hash (Plaintext, bcrypt, or outdated parameters)
+func Verify(password, stored string) (bool, bool) {
+	switch {
+	case strings.HasPrefix(stored, globals.PasswordHashArgon2idPrefix):
+		p, salt, key, err := decode(stored)
+		if err != nil {
+			return false, false
+		}
+		candidate := idKey(password, salt, p, uint32(len(key)))
+		if subtle.ConstantTimeCompare(candidate, key) != 1 {
+			return false, false
+		}
+		return true, p != currentParams() || len(salt) != globals.PasswordHashSaltLength || len(key) != int(globals.PasswordHashKeyLength)
+	case isBcrypt(stored):
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+			return false, false
+		}
+		return true, true
+	default:
+		// Passwords which were stored before hashing was introduced
+		if stored == "" || subtle.ConstantTimeCompare([]byte(password), []byte(stored)) != 1 {
+			return false, false
+		}
+		return true, true
+	}
+}
+
+// decode parses an argon2id hash in the PHC string format
+func decode(stored string) (params, []byte, []byte, error) {
+	var p params
+	parts := strings.Split(stored, "$")
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	if len(parts) != 6 {
+		return p, nil, nil, errors.New("invalid argon2id hash format")
+	}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return p, nil, nil, errors.New("unsupported argon2id version")
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism)
+	if err != nil || p.iterations == 0 || p.parallelism == 0 {
+		return p, nil, nil, errors.New("invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, errors.New("invalid argon2id hash")
+	}
+	return p, salt, key, nil
+}
+
+// isBcrypt returns true if the stored value is a bcrypt hash
+func isBcrypt(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
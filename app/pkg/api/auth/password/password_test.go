@@ -0,0 +1,146 @@
+package password
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashWith returns an argon2id hash of the password created with the parameters rather than the current ones
+func hashWith(t *testing.T, password string, p params, saltLength int) string {
+	t.Helper()
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, globals.PasswordHashKeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", globals.PasswordHashArgon2idPrefix, argon2.Version, p.memory, p.iterations, p.parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+func TestHash(t *testing.T) {
+	first, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Error("hashes of the same password are equal - The salt is not random")
+	}
+	p := currentParams()
+	prefix := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$", globals.PasswordHashArgon2idPrefix, argon2.Version, p.memory, p.iterations, p.parallelism)
+	if !strings.HasPrefix(first, prefix) {
+		t.Errorf("hash (%s) does not start with %s", first, prefix)
+	}
+	if !IsHash(first) {
+		t.Errorf("hash (%s) is not recognized as a hash", first)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	current, err := Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outdated := hashWith(t, "secret", params{memory: 64 * 1024, iterations: 3, parallelism: 2}, globals.PasswordHashSaltLength)
+	shortSalt := hashWith(t, "secret", currentParams(), 8)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(current, "$")
+
+	tests := []struct {
+		name     string
+		password string
+		stored   string
+		match    bool
+		upgrade  bool
+	}{
+		{name: "current hash", password: "secret", stored: current, match: true},
+		{name: "current hash with wrong password", password: "wrong", stored: current},
+		{name: "outdated parameters", password: "secret", stored: outdated, match: true, upgrade: true},
+		{name: "outdated parameters with wrong password", password: "wrong", stored: outdated},
+		{name: "short salt", password: "secret", stored: shortSalt, match: true, upgrade: true},
+		{name: "bcrypt", password: "secret", stored: string(bcryptHash), match: true, upgrade: true},
+		{name: "bcrypt with wrong password", password: "wrong", stored: string(bcryptHash)},
+		{name: "plaintext", password: "secret", stored: "secret", match: true, upgrade: true},
+		{name: "plaintext with wrong password", password: "wrong", stored: "secret"},
+		{name: "empty stored password", password: "", stored: ""},
+		{name: "hash used as password", password: current, stored: current},
+		{name: "truncated hash", password: "secret", stored: strings.Join(parts[:5], "$")},
+		{name: "unsupported version", password: "secret", stored: strings.Replace(current, "v=19", "v=16", 1)},
+		{name: "zero iterations", password: "secret", stored: strings.Replace(current, fmt.Sprintf("t=%d", globals.PasswordHashIterations), "t=0", 1)},
+		{name: "invalid salt", password: "secret", stored: strings.Replace(current, parts[4], "!!!", 1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, upgrade := Verify(test.password, test.stored)
+			if match != test.match || upgrade != test.upgrade {
+				t.Errorf("Verify() = (%v, %v), want (%v, %v)", match, upgrade, test.match, test.upgrade)
+			}
+		})
+	}
+}
+
+func TestIsHash(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		stored string
+		isHash bool
+	}{
+		{name: "argon2id", stored: globals.PasswordHashArgon2idPrefix + "v=19$m=1,t=1,p=1$c2FsdA$a2V5", isHash: true},
+		{name: "bcrypt", stored: string(bcryptHash), isHash: true},
+		{name: "plaintext", stored: "secret"},
+		{name: "empty", stored: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if isHash := IsHash(test.stored); isHash != test.isHash {
+				t.Errorf("IsHash() = %v, want %v", isHash, test.isHash)
+			}
+		})
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	if cap(slots) != globals.PasswordHashConcurrency {
+		t.Fatalf("slots = %d, want %d", cap(slots), globals.PasswordHashConcurrency)
+	}
+	// Hashing waits while every slot is taken
+	for i := 0; i < cap(slots); i++ {
+		slots <- struct{}{}
+	}
+	done := make(chan struct{})
+	go func() {
+		Hash("secret")
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("hash was computed while every slot was taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	for i := 0; i < cap(slots); i++ {
+		<-slots
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hash was not computed once the slots were free")
+	}
+}
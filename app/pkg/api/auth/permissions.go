@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+)
+
+// Permissions holds what a user may do with the tables of a database and which rows of tables with a policy they see
+// The admin and user system roles may do everything, the readonly system role may read every table and custom roles only what they grant
+// Only the admin system role sees every row of a table with a policy
+type Permissions struct {
+	userID         string
+	userName       string
+	all            bool
+	readAll        bool
+	bypassPolicies bool
+	// grants maps the table to the action to the granted columns - A nil column map grants every column
+	grants map[string]map[string]map[string]bool
+}
+
+// UserPermissions returns the permissions of the user from their roles in the database
+// Requests which are authenticated with an API key (See: authorization) are limited to the roles of the key
+func UserPermissions(database, userID, authorization string) (*Permissions, error) {
+	permissions := &Permissions{userID: userID, grants: make(map[string]map[string]map[string]bool)}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return nil, err
+	}
+	defer wrapper.Release()
+	idCondition, idArgs := UserLookup(globals.UserEntryIDColumnName, userID)
+	rows, err := wrapper.Query("SELECT "+globals.UserNameColumnName+","+globals.UserRolesColumnName+" FROM "+globals.UsersTable+" WHERE "+idCondition, idArgs...)
+	if err != nil {
+		return nil, errors.New("failed to read roles of user (" + userID + "): " + err.Error())
+	}
+	var roles []string
+	for rows.Next() {
+		var name, rolesAsString string
+		err = rows.Scan(&name, &rolesAsString)
+		if err != nil {
+			rows.Close()
+			return nil, errors.New("failed to read roles of user (" + userID + "): " + err.Error())
+		}
+		permissions.userName = fmt.Sprint(data.Process(name))
+		userRoles, _ := data.Process(rolesAsString).([]string)
+		roles = append(roles, userRoles...)
+	}
+	rows.Close()
+	roles, err = APIKeyRoles(wrapper, authorization, roles)
+	if err != nil {
+		return nil, errors.New("failed to read roles of API key: " + err.Error())
+	}
+
+	var customRoles []string
+	for _, role := range roles {
+		switch lowerRole := strings.ToLower(role); {
+		case strings.Contains(lowerRole, globals.RolesSystemAdmin):
+			permissions.all = true
+			permissions.bypassPolicies = true
+			return permissions, nil
+		case lowerRole == globals.RolesSystemUser:
+			permissions.all = true
+		case lowerRole == globals.RolesSystemReadOnly:
+			permissions.readAll = true
+		case strings.HasPrefix(lowerRole, globals.RolesCustomPrefix):
+			customRoles = append(customRoles, role[len(globals.RolesCustomPrefix):])
+		}
+	}
+	if permissions.all || len(customRoles) == 0 {
+		return permissions, nil
+	}
+	grants, err := wrapper.RoleGrants(customRoles)
+	if err != nil {
+		return nil, errors.New("failed to read grants of user (" + userID + "): " + err.Error())
+	}
+	for _, grant := range grants {
+		permissions.add(grant)
+	}
+	return permissions, nil
+}
+
+// add merges the grant into the permissions - Grants of several roles on the same table and action are combined
+func (permissions *Permissions) add(grant sqlWrapper.RoleGrant) {
+	actions, exists := permissions.grants[grant.Table]
+	if !exists {
+		actions = make(map[string]map[string]bool)
+		permissions.grants[grant.Table] = actions
+	}
+	for _, action := range grant.Actions {
+		columns, granted := actions[action]
+		if granted && columns == nil {
+			continue
+		}
+		if len(grant.Columns) == 0 {
+			actions[action] = nil
+			continue
+		}
+		if columns == nil {
+			columns = make(map[string]bool)
+			actions[action] = columns
+		}
+		for _, column := range grant.Columns {
+			columns[column] = true
+		}
+	}
+}
+
+// Check returns an error if the action or any of the columns of the table are not granted
+// The sys_eid column is always granted along with the action and system tables are only granted to the admin system role
+func (permissions *Permissions) Check(table, action string, columns []string) error {
+	if strings.HasPrefix(table, globals.SystemTablePrefix) && !permissions.bypassPolicies {
+		return errors.New(globals.ErrorPermissionDenied + ": " + action + " is not granted on system table (" + table + ")")
+	}
+	if permissions.all || (permissions.readAll && action == globals.RoleActionRead) {
+		return nil
+	}
+	grantedColumns, granted := permissions.grants[table][action]
+	if !granted {
+		return errors.New(globals.ErrorPermissionDenied + ": " + action + " is not granted on table (" + table + ")")
+	}
+	if grantedColumns == nil {
+		return nil
+	}
+	for _, column := range columns {
+		if column != globals.TableEntryIDColumnName && !grantedColumns[column] {
+			return errors.New(globals.ErrorPermissionDenied + ": " + action + " is not granted on column (" + column + ") of table (" + table + ")")
+		}
+	}
+	return nil
+}
+
+// Columns returns the granted columns of the action on the table in order
+// False is returned when every column is granted (Or the action is not granted at all)
+func (permissions *Permissions) Columns(table, action string) ([]string, bool) {
+	if permissions.all || (permissions.readAll && action == globals.RoleActionRead) {
+		return nil, false
+	}
+	grantedColumns := permissions.grants[table][action]
+	if grantedColumns == nil {
+		return nil, false
+	}
+	columns := make([]string, 0, len(grantedColumns))
+	for column := range grantedColumns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns, true
+}
+
+// Admin returns true if the user has the admin system role
+func (permissions *Permissions) Admin() bool {
+	return permissions.bypassPolicies
+}
+
+// BypassesPolicies returns true if the user sees every row of the tables with a policy
+func (permissions *Permissions) BypassesPolicies() bool {
+	return permissions.bypassPolicies
+}
+
+// Attributes returns the attributes of the user which policies are matched against
+func (permissions *Permissions) Attributes() map[string]interface{} {
+	return map[string]interface{}{
+		globals.PolicyAttributeID:   permissions.userID,
+		globals.PolicyAttributeName: permissions.userName,
+	}
+}
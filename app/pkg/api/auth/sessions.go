@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jwtLib "github.com/mitchs-dev/library-go/jwt"
+	"github.com/mitchs-dev/library-go/streaming"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// SessionGrant is a struct that holds the tokens which are handed out when a session is started or refreshed
+type SessionGrant struct {
+	Session        string
+	UserID         string
+	Name           string
+	JWT            string
+	Timeout        int64
+	RefreshToken   string
+	RefreshTimeout int64
+}
+
+// StartSession starts a new session of the user and returns its tokens
+func StartSession(database, userID, name string) (SessionGrant, error) {
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return SessionGrant{}, fmt.Errorf("Error when opening database: " + err.Error())
+	}
+	defer wrapper.Release()
+	idBytes := make([]byte, globals.SessionIDLength/2)
+	_, err = rand.Read(idBytes)
+	if err != nil {
+		return SessionGrant{}, errors.New("failed to generate session: " + err.Error())
+	}
+	session := sqlWrapper.Session{ID: hex.EncodeToString(idBytes), UserID: userID, CreatedAt: time.Now().Unix()}
+	grant, tokens, err := newSessionTokens(database, &session, name)
+	if err != nil {
+		return SessionGrant{}, err
+	}
+	err = wrapper.CreateSession(session, tokens)
+	if err != nil {
+		return SessionGrant{}, fmt.Errorf("Failed to store session: " + err.Error())
+	}
+	log.Debug("Started session (" + session.ID + ") for user: " + userID)
+	return grant, nil
+}
+
+// RenewSession issues new tokens for the session of the JWT - The refresh token of the session is rotated as well
+func RenewSession(database, jwt, name string) (SessionGrant, error) {
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return SessionGrant{}, fmt.Errorf("Error when opening database: " + err.Error())
+	}
+	defer wrapper.Release()
+	sessionID, err := JWTSession(jwt)
+	if err != nil {
+		return SessionGrant{}, err
+	}
+	session, tokens, exists, err := wrapper.Session(sessionID)
+	if err != nil {
+		return SessionGrant{}, fmt.Errorf("Failed to read session: " + err.Error())
+	}
+	if !exists {
+		return SessionGrant{}, errors.New(globals.ErrorSessionNotFound + ": " + sessionID)
+	}
+	return rotateSession(wrapper, database, session, name, tokens.RefreshSHA256)
+}
+
+// RefreshSession issues new tokens for the session of the refresh token and rotates the refresh token
+// A refresh token which was already rotated revokes the session as it means that the token was stolen or replayed
+func RefreshSession(database, refreshToken string) (SessionGrant, error) {
+	sessionID, secret, found := strings.Cut(refreshToken, globals.SessionRefreshTokenSeparator)
+	if !found || sessionID == "" || secret == "" {
+		return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": refresh token is not in the correct format (<session>" + globals.SessionRefreshTokenSeparator + "<secret>)")
+	}
+	if !sqlWrapper.DatabaseExists(database) {
+		return SessionGrant{}, fmt.Errorf("Database (" + database + ") does not exist")
+	}
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return SessionGrant{}, fmt.Errorf("Error when opening database: " + err.Error())
+	}
+	defer wrapper.Release()
+	session, tokens, exists, err := wrapper.Session(sessionID)
+	if err != nil {
+		return SessionGrant{}, fmt.Errorf("Failed to read session: " + err.Error())
+	}
+	if !exists {
+		return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": session (" + sessionID + ") does not exist")
+	}
+
+	presentedRefreshSHA256 := hashSecret(secret)
+	if subtle.ConstantTimeCompare([]byte(presentedRefreshSHA256), []byte(tokens.RefreshSHA256)) != 1 {
+		if tokens.PreviousRefreshSHA256 != "" && subtle.ConstantTimeCompare([]byte(presentedRefreshSHA256), []byte(tokens.PreviousRefreshSHA256)) == 1 {
+			log.Warn("Refresh token of session (" + sessionID + ") was reused - Revoking the session of user: " + session.UserID)
+			_, err = wrapper.RevokeSessions(sessionID, "", globals.SystemUserID)
+			if err != nil {
+				log.Error("Failed to revoke session (" + sessionID + "): " + err.Error())
+			}
+			return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": refresh token was already used")
+		}
+		return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": refresh token does not match")
+	}
+	if time.Now().Unix() >= session.RefreshExpiration {
+		return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": refresh token is expired")
+	}
+
+	// The user may have been deleted or lost the roles which may start sessions since the session was started
+	name, roles, exists, err := userIdentity(wrapper, session.UserID)
+	if err != nil {
+		return SessionGrant{}, err
+	}
+	if !exists || !hasRole(roles, globals.SessionRoles) {
+		log.Warn("User (" + session.UserID + ") of session (" + sessionID + ") does not exist or does not have a role which may start sessions - Revoking the session")
+		_, err = wrapper.RevokeSessions(sessionID, "", globals.SystemUserID)
+		if err != nil {
+			log.Error("Failed to revoke session (" + sessionID + "): " + err.Error())
+		}
+		return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": user of the session does not exist or may no longer start sessions")
+	}
+	return rotateSession(wrapper, database, session, name, presentedRefreshSHA256)
+}
+
+// JWTSession returns the ID of the session which is carried in the data of the JWT
+func JWTSession(jwt string) (string, error) {
+	decoded, err := streaming.DecodeToByte(jwt)
+	if err != nil {
+		return "", errors.New("token invalid: could not decode token: " + err.Error())
+	}
+	var token jwtLib.JWTToken
+	err = json.Unmarshal(decoded, &token)
+	if err != nil || token.Payload.Data == "" {
+		return "", errors.New("token invalid: token does not belong to a session")
+	}
+	return token.Payload.Data, nil
+}
+
+// rotateSession issues new tokens for the session as long as its refresh token was not rotated by another request in the meantime
+func rotateSession(wrapper *sqlWrapper.SQLiteWrapper, database string, session sqlWrapper.Session, name, presentedRefreshSHA256 string) (SessionGrant, error) {
+	grant, tokens, err := newSessionTokens(database, &session, name)
+	if err != nil {
+		return SessionGrant{}, err
+	}
+	err = wrapper.RotateSession(session, tokens, presentedRefreshSHA256)
+	if err != nil {
+		if strings.Contains(err.Error(), globals.ErrorSessionNotFound) {
+			return SessionGrant{}, errors.New(globals.ErrorInvalidRefreshToken + ": session (" + session.ID + ") was revoked or refreshed in the meantime")
+		}
+		return SessionGrant{}, fmt.Errorf("Failed to store session: " + err.Error())
+	}
+	log.Debug("Rotated tokens of session (" + session.ID + ") for user: " + session.UserID)
+	return grant, nil
+}
+
+// newSessionTokens generates an access token and a refresh token for the session and sets their expiration on the session
+// Neither token outlives the max lifetime of the session when one is configured
+func newSessionTokens(database string, session *sqlWrapper.Session, name string) (SessionGrant, sqlWrapper.SessionTokens, error) {
+	c := configuration.Current()
+	now := time.Now()
+	accessTimeout, err := time.ParseDuration(c.Session.JWT.Timeout)
+	if err != nil {
+		return SessionGrant{}, sqlWrapper.SessionTokens{}, errors.New("Failed to parse JWT timeout: " + err.Error())
+	}
+	refreshTimeout, err := time.ParseDuration(c.Session.JWT.RefreshTimeout)
+	if err != nil {
+		return SessionGrant{}, sqlWrapper.SessionTokens{}, errors.New("Failed to parse JWT refresh timeout: " + err.Error())
+	}
+	if c.Session.JWT.MaxLifetime != "" {
+		maxLifetime, err := time.ParseDuration(c.Session.JWT.MaxLifetime)
+		if err != nil {
+			return SessionGrant{}, sqlWrapper.SessionTokens{}, errors.New("Failed to parse session max lifetime: " + err.Error())
+		}
+		end := time.Unix(session.CreatedAt, 0).Add(maxLifetime)
+		remaining := end.Sub(now).Truncate(time.Second)
+		if remaining <= 0 {
+			return SessionGrant{}, sqlWrapper.SessionTokens{}, errors.New(globals.ErrorInvalidRefreshToken + ": session (" + session.ID + ") reached its max lifetime (" + c.Session.JWT.MaxLifetime + ") - Login required")
+		}
+		accessTimeout = min(accessTimeout, remaining)
+		if now.Add(refreshTimeout).After(end) {
+			refreshTimeout = end.Sub(now)
+		}
+	}
+
+	subject := "JWT Token for " + name + " for use in " + database
+	jwt, jwtSHA256, timeout, err := jwtLib.GenerateToken(GetJWTSigningKey(database), globals.JWTTimeZone, accessTimeout.String(), SetJWTIssuer(database), subject, session.UserID, session.ID)
+	if err != nil {
+		return SessionGrant{}, sqlWrapper.SessionTokens{}, errors.New("Failed to generate JWT: " + err.Error())
+	}
+	secretBytes := make([]byte, globals.SessionRefreshSecretLength)
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return SessionGrant{}, sqlWrapper.SessionTokens{}, errors.New("failed to generate refresh token: " + err.Error())
+	}
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	session.Expiration = timeout
+	session.RefreshExpiration = now.Add(refreshTimeout).Unix()
+	grant := SessionGrant{
+		Session:        session.ID,
+		UserID:         session.UserID,
+		Name:           name,
+		JWT:            jwt,
+		Timeout:        timeout,
+		RefreshToken:   session.ID + globals.SessionRefreshTokenSeparator + secret,
+		RefreshTimeout: session.RefreshExpiration,
+	}
+	tokens := sqlWrapper.SessionTokens{
+		Token:         jwt,
+		TokenSHA256:   jwtSHA256,
+		RefreshSHA256: hashSecret(secret),
+	}
+	return grant, tokens, nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	testDatabase = "app"
+	testUser     = "root"
+	testPassword = "rootpw"
+)
+
+// testConfiguration is the configuration of the database which the sessions are started in
+var testConfiguration = `session:
+  default:
+    name: ` + testUser + `
+    password: ` + testPassword + `
+storage:
+  path: {{dir}}/databases
+  encryption:
+    enabled: true
+    path: {{dir}}/keys
+databases:
+- name: ` + testDatabase + `
+  version: 1
+  tables:
+  - name: notes
+    columns:
+    - name: body
+      type: TEXT
+`
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	dir, err := os.MkdirTemp("", "simplql-auth")
+	if err != nil {
+		log.Fatal(err)
+	}
+	code := func() int {
+		defer os.RemoveAll(dir)
+		err := setupDatabase(dir)
+		if err != nil {
+			log.SetOutput(os.Stderr)
+			log.Error("Failed to set up the test database: " + err.Error())
+			return 1
+		}
+		defer sqlWrapper.CloseDatabases()
+		return m.Run()
+	}()
+	os.Exit(code)
+}
+
+// setupDatabase creates the test database in the directory the way the server does on startup
+func setupDatabase(dir string) error {
+	for _, path := range []string{filepath.Join(dir, "databases"), filepath.Join(dir, "keys")} {
+		err := os.MkdirAll(path, 0700)
+		if err != nil {
+			return err
+		}
+	}
+	globals.ConfigFile = filepath.Join(dir, "config.yaml")
+	err := os.WriteFile(globals.ConfigFile, []byte(strings.ReplaceAll(testConfiguration, "{{dir}}", dir)), 0600)
+	if err != nil {
+		return err
+	}
+	globals.DatabaseBusyTimeout = 5 * time.Second
+	globals.DatabaseReaderConnections = 2
+	key, err := data.GenerateKey()
+	if err != nil {
+		return err
+	}
+	globals.EncryptionKey = key.Secret
+	globals.EncryptionIV = key.IV
+	globals.EncryptionIVString = string(key.IV)
+	err = data.LoadKeyring(key)
+	if err != nil {
+		return err
+	}
+	return sqlWrapper.CreateDatabases()
+}
+
+// startTestSession logs the test user in and starts a new session
+func startTestSession(t *testing.T) SessionGrant {
+	t.Helper()
+	exists, userID, _, err := CheckBasic(testUser, testPassword, testDatabase)
+	if err != nil || !exists {
+		t.Fatalf("failed to log in (Exists: %v): %v", exists, err)
+	}
+	grant, err := StartSession(testDatabase, userID, testUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return grant
+}
+
+func TestRefreshSession(t *testing.T) {
+	// latest returns the refresh token of the last grant of the session
+	latest := func(grants []SessionGrant) string {
+		return grants[len(grants)-1].RefreshToken
+	}
+	// first returns the refresh token which was handed out on login
+	first := func(grants []SessionGrant) string {
+		return grants[0].RefreshToken
+	}
+	type step struct {
+		token func(grants []SessionGrant) string
+		err   string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		// valid is whether the session can still be used once the steps ran
+		valid bool
+	}{
+		{
+			name:  "rotates on every refresh",
+			steps: []step{{token: latest}, {token: latest}, {token: latest}},
+			valid: true,
+		},
+		{
+			name: "reused refresh token revokes the session",
+			steps: []step{
+				{token: latest},
+				{token: first, err: "refresh token was already used"},
+				{token: latest, err: "does not exist"},
+			},
+		},
+		{
+			name: "refresh token of an earlier rotation does not revoke the session",
+			steps: []step{
+				{token: latest},
+				{token: latest},
+				{token: first, err: "refresh token does not match"},
+				{token: latest},
+			},
+			valid: true,
+		},
+		{
+			name: "unknown secret",
+			steps: []step{
+				{token: func(grants []SessionGrant) string {
+					return grants[0].Session + globals.SessionRefreshTokenSeparator + "secret"
+				}, err: "refresh token does not match"},
+				{token: latest},
+			},
+			valid: true,
+		},
+		{
+			name: "unknown session",
+			steps: []step{
+				{token: func([]SessionGrant) string {
+					return "session" + globals.SessionRefreshTokenSeparator + "secret"
+				}, err: "does not exist"},
+			},
+			valid: true,
+		},
+		{
+			name: "malformed refresh token",
+			steps: []step{
+				{token: func(grants []SessionGrant) string {
+					return strings.ReplaceAll(latest(grants), globals.SessionRefreshTokenSeparator, "")
+				}, err: "not in the correct format"},
+			},
+			valid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			grants := []SessionGrant{startTestSession(t)}
+			for i, step := range test.steps {
+				previous := grants[len(grants)-1]
+				grant, err := RefreshSession(testDatabase, step.token(grants))
+				if step.err != "" {
+					if err == nil || !strings.HasPrefix(err.Error(), globals.ErrorInvalidRefreshToken) || !strings.Contains(err.Error(), step.err) {
+						t.Fatalf("step %d: expected an %s error containing (%s) but got: %v", i+1, globals.ErrorInvalidRefreshToken, step.err, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i+1, err)
+				}
+				if grant.Session != previous.Session || grant.UserID != previous.UserID {
+					t.Fatalf("step %d: refresh changed the session from (%s, %s) to (%s, %s)", i+1, previous.Session, previous.UserID, grant.Session, grant.UserID)
+				}
+				if grant.RefreshToken == previous.RefreshToken {
+					t.Fatalf("step %d: refresh token was not rotated", i+1)
+				}
+				grants = append(grants, grant)
+			}
+
+			last := grants[len(grants)-1]
+			valid, _, _, _, _ := CheckJWT(last.JWT, testDatabase)
+			if valid != test.valid {
+				t.Errorf("JWT of the session is valid = %v, want %v", valid, test.valid)
+			}
+			_, _, exists, err := sessionOf(t, last.Session)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != test.valid {
+				t.Errorf("session exists = %v, want %v", exists, test.valid)
+			}
+		})
+	}
+}
+
+// sessionOf reads the session from the test database
+func sessionOf(t *testing.T, id string) (sqlWrapper.Session, sqlWrapper.SessionTokens, bool, error) {
+	t.Helper()
+	wrapper, err := sqlWrapper.Open(testDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrapper.Release()
+	return wrapper.Session(id)
+}
@@ -9,8 +9,10 @@ import (
 
 	"github.com/mitchs-dev/library-go/networking"
 	"github.com/mitchs-dev/simplQL/cmd/api/v1/auth"
+	"github.com/mitchs-dev/simplQL/cmd/api/v1/database"
 	"github.com/mitchs-dev/simplQL/cmd/api/v1/db"
 	"github.com/mitchs-dev/simplQL/cmd/api/v1/docs"
+	"github.com/mitchs-dev/simplQL/cmd/api/v1/schema"
 	"github.com/mitchs-dev/simplQL/cmd/api/v1/system"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	log "github.com/sirupsen/logrus"
@@ -21,24 +23,49 @@ import (
 
 IMPORTANT: You MUST map any functions you wish to use to keys here to be able to call them via the requests package
 The key should be the name of the category and action of the request, separated by a hyphen (I.e. "category-action")
+Actions with nested paths (I.e. "tx/begin") use hyphens in place of slashes (I.e. "db-tx-begin")
 
 IMPORTANT: Do not forget to import the package containing the functions you wish to use
 */
 var functionRegistry = map[string]requestHandlingFunction{
 	// V1
-	"auth-create":    auth.Create,
-	"auth-read":      auth.Read,
-	"auth-update":    auth.Update,
-	"auth-delete":    auth.Delete,
-	"auth-login":     auth.Login,
-	"auth-logout":    auth.Logout,
-	"db-create":      db.Create,
-	"db-read":        db.Read,
-	"db-update":      db.Update,
-	"db-delete":      db.Delete,
-	"docs-api":       docs.API,
-	"system-version": system.Version,
-	"system-healthz": system.Healthz,
+	"auth-create":           auth.Create,
+	"auth-read":             auth.Read,
+	"auth-update":           auth.Update,
+	"auth-delete":           auth.Delete,
+	"auth-login":            auth.Login,
+	"auth-logout":           auth.Logout,
+	"auth-refresh":          auth.Refresh,
+	"auth-session-list":     auth.SessionList,
+	"auth-role-create":      auth.RoleCreate,
+	"auth-role-list":        auth.RoleList,
+	"auth-role-grant":       auth.RoleGrant,
+	"auth-role-revoke":      auth.RoleRevoke,
+	"auth-apikey-create":    auth.APIKeyCreate,
+	"auth-apikey-list":      auth.APIKeyList,
+	"auth-apikey-revoke":    auth.APIKeyRevoke,
+	"database-create":       database.Create,
+	"database-delete":       database.Delete,
+	"db-create":             db.Create,
+	"db-read":               db.Read,
+	"db-update":             db.Update,
+	"db-delete":             db.Delete,
+	"db-transaction":        db.Transaction,
+	"db-tx-begin":           db.TxBegin,
+	"db-tx-commit":          db.TxCommit,
+	"db-tx-rollback":        db.TxRollback,
+	"docs-api":              docs.API,
+	"schema-create-table":   schema.CreateTable,
+	"schema-describe-table": schema.DescribeTable,
+	"schema-list-tables":    schema.ListTables,
+	"schema-alter-table":    schema.AlterTable,
+	"schema-drop-table":     schema.DropTable,
+	"system-version":        system.Version,
+	"system-healthz":        system.Healthz,
+	"system-metrics":        system.Metrics,
+	"system-backup":         system.Backup,
+	"system-restore":        system.Restore,
+	"system-rotate-key":     system.RotateKey,
 }
 
 // requestHandlingFunction is the function signature for the request handling functions - It requires a request, response writer, User ID, and a correlation ID as input and returns an error
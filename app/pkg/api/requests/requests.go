@@ -8,16 +8,20 @@ package requests
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mitchs-dev/simplQL/pkg/api/auth"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/metrics"
 
 	"github.com/gorilla/mux"
 	"github.com/mitchs-dev/library-go/generator"
@@ -30,10 +34,15 @@ import (
 
 // Variables which are pointers to various configuration structs
 var (
-	c  configuration.Configuration
 	rs requestSchemas.Schema
 )
 
+// httpServer is the server which is started by Handler
+var (
+	httpServer *http.Server
+	serverLock sync.Mutex
+)
+
 //go:embed requestSchema.yaml
 var requestSchemaFile embed.FS
 
@@ -55,11 +64,39 @@ func Startup() {
 
 func interceptor(w http.ResponseWriter, r *http.Request) {
 
-	c.GetConfig()
+	c := configuration.Current()
+
+	// Record the request once it has been handled - Requests which do not match the request schema are recorded as unknown
+	requestStart := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	w = recorder
+	metricsCategory, metricsAction := globals.MetricsLabelUnknown, globals.MetricsLabelUnknown
+	defer func() {
+		metrics.ObserveRequest(metricsCategory, metricsAction, recorder.status, requestStart)
+	}()
+
 	correlationID := generator.CorrelationID("Local")
 	w.Header().Set(globals.NetworkingHeaderCorrelationID, correlationID)
 	log.Debug("Endpoint Hit: " + r.URL.Path + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 
+	// Allow cross-origin requests from the configured origins - Preflight requests are answered here
+	origin := r.Header.Get(globals.NetworkingHeaderOrigin)
+	if origin != "" && corsOriginAllowed(c.Network.CORS.AllowedOrigins, origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", globals.NetworkingHeaderOrigin)
+		w.Header().Set("Access-Control-Expose-Headers", globals.NetworkingCORSExposedHeaders)
+		if r.Method == http.MethodOptions {
+			log.Debug("Answering preflight request from origin: " + origin + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			w.Header().Set("Access-Control-Allow-Methods", globals.NetworkingCORSAllowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.Network.CORS.AllowedHeaders, ", "))
+			if c.Network.CORS.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", fmt.Sprint(c.Network.CORS.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
 	// Check if the request path is valid (Must have API slug, API version, and endpoint)
 	if len(r.URL.Path) < 3 {
 		log.Debug("Invalid request path: " + r.URL.Path + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
@@ -114,7 +151,17 @@ func interceptor(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(globals.AuthenticationHeaderJWTSessionToken, jwtTokenValue)
 	w.Header().Set(globals.AuthenticationHeaderSessionTimeout, jwtTokenExpireTime)
 
+	if categoryIndex >= 0 && actionIndex >= 0 {
+		rs.GetSchema(globals.RequestSchemaData)
+		metricsCategory = rs.RequestSchema.Categories[categoryIndex].Name
+		metricsAction = rs.RequestSchema.Categories[categoryIndex].Actions[actionIndex].Name
+	}
+
 	if err != nil {
+		switch invalidReason {
+		case globals.ErrorAuthenticationNoRoles, globals.ErrorAuthenticationUserNotFound, globals.ErrorAuthenticationJWTExpired:
+			metrics.AuthFailure(invalidReason)
+		}
 		if invalidReason == globals.ErrorAuthenticationNoRoles {
 			log.Error("User does not have a required role: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 			w.WriteHeader(403)
@@ -128,6 +175,19 @@ func interceptor(w http.ResponseWriter, r *http.Request) {
 				log.Error("Error encoding response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
 			}
 			return
+		} else if invalidReason == globals.ErrorAuthenticationSuperAdminDisabled {
+			log.Warn("Super-admin is not configured: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
+			w.WriteHeader(403)
+			response := globals.Response{
+				Status:  "error",
+				Message: "Forbidden: Super-admin requests are disabled on this server",
+				Data:    map[string]string{"correlationID": correlationID},
+			}
+			err := json.NewEncoder(w).Encode(response)
+			if err != nil {
+				log.Error("Error encoding response", err.Error()+" (C: "+correlationID+" | M: "+r.Method+" | IP: "+networking.GetRequestIPAddress(r)+")")
+			}
+			return
 		} else if invalidReason == globals.ErrorAuthenticationUserNotFound {
 			log.Warn("User not found: " + err.Error() + " (C: " + correlationID + " | M: " + r.Method + " | IP: " + networking.GetRequestIPAddress(r) + ")")
 			w.WriteHeader(401)
@@ -203,7 +263,7 @@ func interceptor(w http.ResponseWriter, r *http.Request) {
 	category = rs.RequestSchema.Categories[categoryIndex].Name
 	action := rs.RequestSchema.Categories[categoryIndex].Actions[actionIndex].Name
 	// Combine the category and action to get make the functionRegistry key
-	functionToCall := category + "-" + action
+	functionToCall := category + "-" + strings.ReplaceAll(action, "/", "-")
 	// Finally, call the function from the registry
 	RunFunction(functionToCall, r, w, userID, correlationID)
 
@@ -211,9 +271,10 @@ func interceptor(w http.ResponseWriter, r *http.Request) {
 
 // ENDPOINT REQUEST HANDLERS
 
+// Handler serves requests until the server is shut down (See: Shutdown)
 func Handler() {
 	// Get Configuration
-	c.GetConfig()
+	c := configuration.Current()
 
 	// Set Router
 
@@ -226,6 +287,17 @@ func Handler() {
 	listenAddress := c.Network.ListenAddress
 	port := c.Network.Port
 	ApplicationName := globals.ApplicationName
+	server := &http.Server{
+		Addr:         listenAddress + ":" + fmt.Sprint(port),
+		Handler:      myRouter,
+		ReadTimeout:  globals.ServerReadTimeout,
+		WriteTimeout: globals.ServerWriteTimeout,
+		IdleTimeout:  globals.ServerIdleTimeout,
+	}
+	serverLock.Lock()
+	httpServer = server
+	serverLock.Unlock()
+	var err error
 	if c.Network.TLS.Enabled {
 		// Check that the TLS cert and key are set
 		if c.Network.TLS.Cert == "" || c.Network.TLS.Key == "" {
@@ -237,19 +309,31 @@ func Handler() {
 		}
 		log.Info(ApplicationName + " listening on port: " + fmt.Sprint(port))
 		log.Info("Using protocol: HTTPS")
-		err := http.ListenAndServeTLS(listenAddress+":"+fmt.Sprint(port), c.Network.TLS.Cert, c.Network.TLS.Key, myRouter)
-		if err != nil {
-			log.Fatal("Error in listening and serving ", err)
-		}
+		err = server.ListenAndServeTLS(c.Network.TLS.Cert, c.Network.TLS.Key)
 	} else {
 		log.Info(ApplicationName + " listening on port: " + fmt.Sprint(port))
 		log.Info("Using protocol: HTTP")
-		err := http.ListenAndServe(listenAddress+":"+fmt.Sprint(port), myRouter)
-		if err != nil {
-			log.Error("Error in listening and serving", err)
-		}
+		err = server.ListenAndServe()
 	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal("Error in listening and serving: " + err.Error())
+	}
+}
 
+// Shutdown stops accepting connections and waits for in-flight requests until the context is done
+// Connections which are still active once the context is done are closed
+func Shutdown(ctx context.Context) error {
+	serverLock.Lock()
+	server := httpServer
+	serverLock.Unlock()
+	if server == nil {
+		return nil
+	}
+	err := server.Shutdown(ctx)
+	if err != nil {
+		server.Close()
+	}
+	return err
 }
 
 /*
@@ -280,7 +364,8 @@ func runRequestValidation(r *http.Request, correlationID string) (string, int, i
 
 	// Set the request category and action
 	requestCategory := strings.Split(r.URL.Path, "/")[3]
-	requestAction := strings.Split(r.URL.Path, "/")[4]
+	// Actions may be nested (I.e. /api/v1/db/tx/begin has the action tx/begin)
+	requestAction := strings.TrimSuffix(strings.Join(strings.Split(r.URL.Path, "/")[4:], "/"), "/")
 
 	// JWT Token and Expire Time
 	var (
@@ -348,6 +433,15 @@ func runRequestValidation(r *http.Request, correlationID string) (string, int, i
 							return "Authorization header not found - Request (" + action.Method + " " + globals.NetworkingAPIEndpoint + "/" + category.Name + "/" + action.Name + ") requires authorization", i, j, "", "", "", fmt.Errorf("invalid request")
 						}
 
+						// Super-admin requests are authenticated against the server instead of a database
+						if containsRole(action.Roles, globals.RolesSuperAdmin) {
+							userID, err := auth.CheckSuperAdmin(authorizationHeader, correlationID)
+							if err != nil {
+								return err.Error(), i, j, "", "", "", err
+							}
+							return "", i, j, userID, jwtTokenValue, jwtTokenExpireTime, nil
+						}
+
 						var arb auth.AuthRequestBody
 						requestBody, err := io.ReadAll(r.Body)
 						if err != nil {
@@ -385,7 +479,7 @@ func runRequestValidation(r *http.Request, correlationID string) (string, int, i
 							return "Database could not be found but is required for authentication", i, j, "", "", "", fmt.Errorf("invalid request - database not found in request")
 						}
 
-						userID, err = auth.RunAuthChecks(authorizationHeader, database, correlationID, action.Roles)
+						userID, err = auth.RunAuthChecks(authorizationHeader, database, correlationID, networking.GetRequestIPAddress(r), action.Roles)
 						if err != nil {
 							return err.Error(), i, j, "", "", "", err
 						}
@@ -413,3 +507,38 @@ func runRequestValidation(r *http.Request, correlationID string) (string, int, i
 	return errorMessage, -1, -1, "", "", "", fmt.Errorf("invalid request")
 
 }
+
+// statusRecorder keeps the status code which was written to the response so that it can be recorded
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if !recorder.wroteHeader {
+		recorder.status = status
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// corsOriginAllowed returns true if the origin is one of the allowed origins or any origin is allowed ("*")
+func corsOriginAllowed(allowedOrigins []string, origin string) bool {
+	for _, allowedOrigin := range allowedOrigins {
+		if allowedOrigin == "*" || strings.EqualFold(strings.TrimSuffix(allowedOrigin, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsRole returns true if the role is in the list of roles
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -3,14 +3,17 @@ package configuration
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
 
 	libGoConfiguration "github.com/mitchs-dev/library-go/configuration"
-	"github.com/mitchs-dev/library-go/processor"
 	"gopkg.in/yaml.v2"
 )
 
@@ -36,61 +39,203 @@ type Configuration struct {
 			Cert    string `json:"certFile" yaml:"cert"`
 			Key     string `json:"keyFile" yaml:"key"`
 		} `json:"tls" yaml:"tls"`
+		Timeouts struct {
+			Read     string `json:"read" yaml:"read"`
+			Write    string `json:"write" yaml:"write"`
+			Idle     string `json:"idle" yaml:"idle"`
+			Shutdown string `json:"shutdown" yaml:"shutdown"`
+		} `json:"timeouts" yaml:"timeouts"`
+		CORS struct {
+			AllowedOrigins []string `json:"allowedOrigins" yaml:"allowedOrigins"`
+			AllowedHeaders []string `json:"allowedHeaders" yaml:"allowedHeaders"`
+			MaxAge         int      `json:"maxAge" yaml:"maxAge"`
+		} `json:"cors" yaml:"cors"`
 	} `json:"network" yaml:"network"`
 	Session struct {
 		JWT struct {
-			Enabled bool   `json:"enabled" yaml:"enabled"`
-			Timeout string `json:"timeout" yaml:"timeout"`
+			Enabled        bool   `json:"enabled" yaml:"enabled"`
+			Timeout        string `json:"timeout" yaml:"timeout"`
+			RefreshTimeout string `json:"refreshTimeout" yaml:"refreshTimeout"`
+			MaxLifetime    string `json:"maxLifetime" yaml:"maxLifetime"`
+			PurgeInterval  string `json:"purgeInterval" yaml:"purgeInterval"`
 		} `json:"jwt" yaml:"jwt"`
+		APIKeys struct {
+			Enabled     bool   `json:"enabled" yaml:"enabled"`
+			MaxLifetime string `json:"maxLifetime" yaml:"maxLifetime"`
+		} `json:"apiKeys" yaml:"apiKeys"`
 		Default struct {
 			Name     string `json:"name" yaml:"name"`
 			Password string `json:"password" yaml:"password"`
 		} `json:"default" yaml:"default"`
+		SuperAdmin struct {
+			Name     string `json:"name" yaml:"name"`
+			Password string `json:"password" yaml:"password"`
+		} `json:"superAdmin" yaml:"superAdmin"`
 	} `json:"session" yaml:"session"`
 	Storage struct {
 		Encryption struct {
-			Enabled bool   `json:"enabled" yaml:"enabled"`
-			Path    string `json:"path" yaml:"path"`
-			Key     string `json:"key" yaml:"key"`
+			Enabled    bool   `json:"enabled" yaml:"enabled"`
+			Provider   string `json:"provider" yaml:"provider"`
+			Path       string `json:"path" yaml:"path"`
+			Key        string `json:"key" yaml:"key"`
+			Passphrase struct {
+				Value string `json:"value" yaml:"value"`
+				KDF   string `json:"kdf" yaml:"kdf"`
+			} `json:"passphrase" yaml:"passphrase"`
+			Exec struct {
+				Command string   `json:"command" yaml:"command"`
+				Args    []string `json:"args" yaml:"args"`
+				Timeout string   `json:"timeout" yaml:"timeout"`
+			} `json:"exec" yaml:"exec"`
 		} `json:"encryption" yaml:"encryption"`
-		Path string `json:"path" yaml:"path"`
+		Path        string `json:"path" yaml:"path"`
+		Connections struct {
+			Readers     int    `json:"readers" yaml:"readers"`
+			BusyTimeout string `json:"busyTimeout" yaml:"busyTimeout"`
+		} `json:"connections" yaml:"connections"`
+		Transactions struct {
+			IdleTimeout string `json:"idleTimeout" yaml:"idleTimeout"`
+		} `json:"transactions" yaml:"transactions"`
+		Backups struct {
+			Path      string `json:"path" yaml:"path"`
+			Interval  string `json:"interval" yaml:"interval"`
+			Retention struct {
+				Count  int    `json:"count" yaml:"count"`
+				MaxAge string `json:"maxAge" yaml:"maxAge"`
+			} `json:"retention" yaml:"retention"`
+			Compression string `json:"compression" yaml:"compression"`
+		} `json:"backups" yaml:"backups"`
 	} `json:"storage" yaml:"storage"`
 	Databases []ConfigurationDatabaseEntry `json:"databases" yaml:"databases"`
 }
 
 // ConfigurationDatabaseEntry is a struct that holds the configuration for a database
 type ConfigurationDatabaseEntry struct {
-	Name    string                                  `json:"name" yaml:"name"`
-	Version int                                     `json:"version" yaml:"version"`
-	Tables  []ConfigurationDatabaseEntryTablesEntry `json:"tables" yaml:"tables"`
+	Name       string                                      `json:"name" yaml:"name"`
+	Version    int                                         `json:"version" yaml:"version"`
+	Tables     []ConfigurationDatabaseEntryTablesEntry     `json:"tables" yaml:"tables"`
+	Migrations []ConfigurationDatabaseEntryMigrationsEntry `json:"migrations" yaml:"migrations"`
 }
 
 // ConfigurationDatabaseEntryTablesEntry is a struct that holds the configuration for a database table
 type ConfigurationDatabaseEntryTablesEntry struct {
 	Name    string                                   `json:"name" yaml:"name"`
 	Columns []ConfigurationDatabaseEntryColumnsEntry `json:"columns" yaml:"columns"`
+	Indexes []ConfigurationDatabaseEntryIndexesEntry `json:"indexes,omitempty" yaml:"indexes"`
+	Policy  *ConfigurationDatabaseEntryPolicy        `json:"policy,omitempty" yaml:"policy"`
+}
+
+// ConfigurationDatabaseEntryPolicy is a struct that holds the row-level security policy of a table
+// Either the owner column is matched against an attribute of the user or the predicate (Ex: "tenant = {{user.name}}") is added to the filter
+type ConfigurationDatabaseEntryPolicy struct {
+	OwnerColumn string `json:"ownerColumn,omitempty" yaml:"ownerColumn"`
+	Attribute   string `json:"attribute,omitempty" yaml:"attribute"`
+	Predicate   string `json:"predicate,omitempty" yaml:"predicate"`
 }
 
 // ConfigurationDatabaseEntryColumnsEntry is a struct that holds the configuration for a database column
 type ConfigurationDatabaseEntryColumnsEntry struct {
-	Name       string `json:"name" yaml:"name"`
-	Type       string `json:"type" yaml:"type"`
-	PrimaryKey bool   `json:"primaryKey" yaml:"primaryKey"`
+	Name       string                                      `json:"name" yaml:"name"`
+	Type       string                                      `json:"type" yaml:"type"`
+	PrimaryKey bool                                        `json:"primaryKey" yaml:"primaryKey"`
+	Searchable bool                                        `json:"searchable" yaml:"searchable"`
+	Encrypted  *bool                                       `json:"encrypted,omitempty" yaml:"encrypted"`
+	Nullable   *bool                                       `json:"nullable,omitempty" yaml:"nullable"`
+	Unique     bool                                        `json:"unique" yaml:"unique"`
+	Default    interface{}                                 `json:"default,omitempty" yaml:"default"`
+	Check      string                                      `json:"check,omitempty" yaml:"check"`
+	References *ConfigurationDatabaseEntryColumnsReference `json:"references,omitempty" yaml:"references"`
+}
+
+// ConfigurationDatabaseEntryColumnsReference is a struct that holds the column which a column references (A foreign key)
+type ConfigurationDatabaseEntryColumnsReference struct {
+	Table    string `json:"table" yaml:"table"`
+	Column   string `json:"column" yaml:"column"`
+	OnDelete string `json:"onDelete" yaml:"onDelete"`
+}
+
+// IsEncrypted returns true if the values of the column are encrypted while storage encryption is enabled (The default)
+func (column ConfigurationDatabaseEntryColumnsEntry) IsEncrypted() bool {
+	return column.Encrypted == nil || *column.Encrypted
+}
+
+// IsNullable returns true if the column accepts null values (The default)
+func (column ConfigurationDatabaseEntryColumnsEntry) IsNullable() bool {
+	return column.Nullable == nil || *column.Nullable
+}
+
+// ConfigurationDatabaseEntryIndexesEntry is a struct that holds the configuration for a table index
+type ConfigurationDatabaseEntryIndexesEntry struct {
+	Name    string   `json:"name" yaml:"name"`
+	Columns []string `json:"columns" yaml:"columns"`
+	Unique  bool     `json:"unique" yaml:"unique"`
+	Where   string   `json:"where,omitempty" yaml:"where"`
+}
+
+// ConfigurationDatabaseEntryMigrationsEntry is a struct that holds the steps required to migrate a database to a version
+type ConfigurationDatabaseEntryMigrationsEntry struct {
+	Version int                                       `json:"version" yaml:"version"`
+	Steps   []ConfigurationDatabaseEntryMigrationStep `json:"steps" yaml:"steps"`
+}
+
+// ConfigurationDatabaseEntryMigrationStep is a struct that holds a single declarative migration step
+// The rows a backfill step updates are selected with a filter tree (See: filter.Node) which is compiled to parameterized SQL
+type ConfigurationDatabaseEntryMigrationStep struct {
+	Action  string                                   `json:"action" yaml:"action"`
+	Table   string                                   `json:"table" yaml:"table"`
+	Column  ConfigurationDatabaseEntryColumnsEntry   `json:"column" yaml:"column"`
+	NewName string                                   `json:"newName" yaml:"newName"`
+	Columns []ConfigurationDatabaseEntryColumnsEntry `json:"columns" yaml:"columns"`
+	Index   ConfigurationDatabaseEntryIndexesEntry   `json:"index" yaml:"index"`
+	Set     map[string]interface{}                   `json:"set" yaml:"set"`
+	Where   *filter.Node                             `json:"where,omitempty" yaml:"where"`
+}
+
+// current is the configuration snapshot which is in use
+var current atomic.Pointer[Configuration]
+
+// Current returns the configuration snapshot which is in use
+// The configuration file is parsed on the first call - A snapshot is never modified once it is in use so it must be treated as read-only
+func Current() *Configuration {
+	config := current.Load()
+	if config != nil {
+		return config
+	}
+	loadMutex.Lock()
+	defer loadMutex.Unlock()
+	config = current.Load()
+	if config == nil {
+		var err error
+		config, err = Load()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		current.Store(config)
+	}
+	return config
 }
 
-func (configItem *Configuration) GetConfig() *Configuration {
+// loadMutex ensures that the configuration file is parsed once when it is first used
+var loadMutex sync.Mutex
+
+// Load parses the configuration file merged with the default configuration into a new snapshot
+// The snapshot is not put in use (See: Reload)
+func Load() (*Configuration, error) {
 	// Read default configuration
 	defaultConfigData, err := defaultConfig.ReadFile(globals.DefaultConfigFileName)
 	if err != nil {
-		log.Fatal("Could not read default configuration file: " + err.Error())
+		return nil, errors.New("Could not read default configuration file: " + err.Error())
 	}
 	defaultConfigMap := make(map[interface{}]interface{})
 	err = yaml.Unmarshal(defaultConfigData, &defaultConfigMap)
 	if err != nil {
-		log.Fatal("Could not parse default configuration file: " + err.Error())
+		return nil, errors.New("Could not parse default configuration file: " + err.Error())
 	}
 	// Read configuration file
-	configFileData := processor.ReadFile(globals.ConfigFile)
+	configFileData, err := os.ReadFile(globals.ConfigFile)
+	if err != nil {
+		return nil, errors.New("Could not read configuration file: " + err.Error())
+	}
 	userConfigMap := make(map[interface{}]interface{})
 	// Determine if file is JSON or YAML
 	err = json.Unmarshal(configFileData, &userConfigMap)
@@ -98,21 +243,42 @@ func (configItem *Configuration) GetConfig() *Configuration {
 		// Try YAML
 		err := yaml.Unmarshal(configFileData, &userConfigMap)
 		if err != nil {
-			log.Fatal("Could not parse configuration file: " + err.Error())
+			return nil, errors.New("Could not parse configuration file: " + err.Error())
 		}
 	}
 	// Merge user configuration with default configuration
 	mergedConfigMap := libGoConfiguration.MergeWithDefault(defaultConfigMap, userConfigMap)
 	mergedConfigData, err := yaml.Marshal(mergedConfigMap)
 	if err != nil {
-		log.Fatal("Could not merge configuration files: " + err.Error())
+		return nil, errors.New("Could not merge configuration files: " + err.Error())
 	}
-	err = yaml.Unmarshal(mergedConfigData, &configItem)
+	config := new(Configuration)
+	err = yaml.Unmarshal(mergedConfigData, config)
 	if err != nil {
-		log.Fatal("Could not parse merged configuration file: " + err.Error())
+		return nil, errors.New("Could not parse merged configuration file: " + err.Error())
+	}
+	return config, nil
+}
+
+// Reload parses the configuration file and atomically swaps it with the snapshot in use once validate accepts it
+// validate receives the snapshot in use and the new snapshot - The new snapshot may be adjusted by validate before it is put in use
+// Requests which already hold the previous snapshot keep using it until they are done
+func Reload(validate func(previous, next *Configuration) error) (previous, next *Configuration, err error) {
+	loadMutex.Lock()
+	defer loadMutex.Unlock()
+	next, err = Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	previous = current.Load()
+	if previous != nil && validate != nil {
+		err = validate(previous, next)
+		if err != nil {
+			return previous, nil, err
+		}
 	}
-	// Return configuration
-	return configItem
+	current.Store(next)
+	return previous, next, nil
 }
 
 // GenerateDefaultConfig generates a default configuration file
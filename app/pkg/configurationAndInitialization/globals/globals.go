@@ -1,5 +1,7 @@
 package globals
 
+import "time"
+
 // Config vars
 var (
 	SimplQLIdPlaceholder  = "$ThisShouldBeReplaced"
@@ -7,10 +9,17 @@ var (
 	ConfigFile            string
 	DefaultConfigFileName = "default.yaml"
 	ApplicationName       = "SimplQL"
-	EncryptionKey         string
-	EncryptionIVString    string
-	EncryptionIV          []byte
-	EncryptionKeyFile     = "key"
+	// EncryptionKey and EncryptionIV hold the key which was provided at startup - Key rotation does not change them (See: data.ActivateKey)
+	EncryptionKey      string
+	EncryptionIVString string
+	EncryptionIV       []byte
+	EncryptionKeyFile  = "key"
+	// EncryptionKeyringFile is the file (within the encryption path) which holds the keys added by key rotation
+	EncryptionKeyringFile = "keyring"
+	// ProvisionedDatabasesFile is the file (within the storage path) which holds the definitions of databases provisioned at runtime
+	ProvisionedDatabasesFile = "databases.json"
+	// ConfigWatchInterval is how often the configuration file is checked for changes to reload
+	ConfigWatchInterval = 5 * time.Second
 	// UserPasswordLength is the length of the user password
 	UserPasswordLength = 32
 	UserIDLength       = 6
@@ -27,9 +36,39 @@ var (
 		"BLOB",
 		"CLOB (Character Large Object)",
 		"NCLOB",
+		ColumnTypeInteger,
+		ColumnTypeReal,
+		ColumnTypeNumeric,
+		ColumnTypeBoolean,
+		ColumnTypeDateTime,
+		ColumnTypeJSON,
+		ColumnTypeUUID,
 	}
 )
 
+// Column type vars - Values written to columns of these types are validated against the type
+var (
+	ColumnTypeInteger  = "INTEGER"
+	ColumnTypeReal     = "REAL"
+	ColumnTypeNumeric  = "NUMERIC"
+	ColumnTypeBoolean  = "BOOLEAN"
+	ColumnTypeDateTime = "DATETIME"
+	ColumnTypeJSON     = "JSON"
+	ColumnTypeUUID     = "UUID"
+	// ColumnTypeDateTimeLayout is the layout DATETIME values are stored in (UTC with a fixed width so that they sort in order)
+	ColumnTypeDateTimeLayout = "2006-01-02T15:04:05.000Z"
+	// ColumnTypeDateTimeInputLayouts are the layouts which are accepted for DATETIME values
+	ColumnTypeDateTimeInputLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+)
+
+// Column constraint vars
+var (
+	// ColumnDefaultKeywords are the defaults which are evaluated by SQLite when a row is inserted
+	ColumnDefaultKeywords = []string{"CURRENT_TIMESTAMP", "CURRENT_DATE", "CURRENT_TIME"}
+	// ColumnReferenceOnDeleteActions are the actions which are taken on the referencing rows when a referenced row is deleted
+	ColumnReferenceOnDeleteActions = []string{"NO ACTION", "RESTRICT", "CASCADE", "SET NULL", "SET DEFAULT"}
+)
+
 // Built-in DB table vars
 var (
 	SystemTablePrefix     = "__"
@@ -41,18 +80,173 @@ var (
 	UsersTable            = SystemTablePrefix + "users"
 	JWTTable              = SystemTablePrefix + "jwts"
 	TransactionsTable     = SystemTablePrefix + "transactions"
+	PlaintextColumnsTable = SystemTablePrefix + "plaintext_columns"
+	IndexesTable          = SystemTablePrefix + "indexes"
+	RolesTable            = SystemTablePrefix + "roles"
+	PoliciesTable         = SystemTablePrefix + "policies"
+	APIKeysTable          = SystemTablePrefix + "apikeys"
 	RolesSystemAdmin      = SystemRolePrefix + "admin"
 	RolesSystemUser       = SystemRolePrefix + "user"
 	RolesSystemReadOnly   = SystemRolePrefix + "readonly"
+	RolesSuperAdmin       = "super-admin"
+	RolesCustom           = "custom"
+	RolesCustomPrefix     = "role:"
+	SuperAdminUserID      = "__server:superadmin"
 	DefaultRoles          = []string{RolesSystemAdmin}
 )
 
+// Password vars
+var (
+	PasswordHashArgon2idPrefix        = "$argon2id$"
+	PasswordHashMemory         uint32 = 19 * 1024
+	PasswordHashIterations     uint32 = 2
+	PasswordHashParallelism    uint8  = 1
+	PasswordHashSaltLength            = 16
+	PasswordHashKeyLength      uint32 = 32
+	// PasswordHashConcurrency caps how many passwords are hashed or verified at once as each one holds PasswordHashMemory KiB
+	PasswordHashConcurrency = 4
+)
+
 // JWT vars
 var (
 	JWTTimeZone         = "Local"
-	JWTTimeoutPeriod    string
 	JWTIssuer           = ApplicationName + " Server (" + SimplQLIdPlaceholder + ")"
 	JWTRandomDataLength = 32
+	// JWTSigningKeyContext is mixed into the key which JWTs are signed with so that it differs from the encryption key
+	JWTSigningKeyContext = "simplql-jwt-signing"
+)
+
+// Session vars
+var (
+	// SessionIDLength is the length of the session ID which is carried in the data of the JWT of the session
+	SessionIDLength = 32
+	// SessionRefreshTokenSeparator separates the session ID from the secret of a refresh token (I.e. <session>.<secret>)
+	SessionRefreshTokenSeparator   = "."
+	SessionRefreshSecretLength     = 32
+	SessionCreateTransactionAction = "CREATE_SESSION"
+	SessionRevokeTransactionAction = "REVOKE_SESSION"
+	SessionPurgeReasonExpired      = "expired"
+	SessionPurgeReasonMaxLifetime  = "max_lifetime"
+	SessionPurgeInterval           time.Duration
+	// SessionRoles are the roles which may start and refresh sessions (The roles of the login action of the request schema)
+	SessionRoles = []string{"read-only", "user", "admin", RolesCustom}
+)
+
+// Migration vars
+var (
+	MigrationActionAddColumn     = "addColumn"
+	MigrationActionDropColumn    = "dropColumn"
+	MigrationActionRenameColumn  = "renameColumn"
+	MigrationActionAddTable      = "addTable"
+	MigrationActionAddIndex      = "addIndex"
+	MigrationActionBackfill      = "backfill"
+	MigrationActionAddBlindIndex = "addBlindIndex"
+	MigrationTransactionAction   = "MIGRATE"
+)
+
+// Filter vars
+var (
+	FilterOperatorEqual            = "eq"
+	FilterOperatorNotEqual         = "ne"
+	FilterOperatorLessThan         = "lt"
+	FilterOperatorLessThanEqual    = "lte"
+	FilterOperatorGreaterThan      = "gt"
+	FilterOperatorGreaterThanEqual = "gte"
+	FilterOperatorIn               = "in"
+	FilterOperatorNotIn            = "not_in"
+	FilterOperatorLike             = "like"
+	FilterOperatorIsNull           = "is_null"
+	FilterOperatorBetween          = "between"
+	FilterMaxDepth                 = 16
+)
+
+// Operation vars
+var (
+	OperationActionCreate = "create"
+	OperationActionUpdate = "update"
+	OperationActionDelete = "delete"
+)
+
+// Role vars
+var (
+	RoleActionRead              = "read"
+	RoleActionCreate            = OperationActionCreate
+	RoleActionUpdate            = OperationActionUpdate
+	RoleActionDelete            = OperationActionDelete
+	RoleActions                 = []string{RoleActionRead, RoleActionCreate, RoleActionUpdate, RoleActionDelete}
+	RoleCreateTransactionAction = "CREATE_ROLE"
+	RoleGrantTransactionAction  = "GRANT_ROLE"
+	RoleRevokeTransactionAction = "REVOKE_ROLE"
+)
+
+// Policy vars
+var (
+	PolicyAttributeID   = "id"
+	PolicyAttributeName = "name"
+	PolicyAttributes    = []string{PolicyAttributeID, PolicyAttributeName}
+)
+
+// API key vars
+var (
+	// APIKeySeparator separates the ID of the key from its secret (I.e. <id>.<secret>)
+	APIKeySeparator               = "."
+	APIKeyIDLength                = 16
+	APIKeySecretLength            = 32
+	APIKeyCreateTransactionAction = "CREATE_API_KEY"
+	APIKeyRevokeTransactionAction = "REVOKE_API_KEY"
+)
+
+// Server vars
+var (
+	ServerReadTimeout     time.Duration
+	ServerWriteTimeout    time.Duration
+	ServerIdleTimeout     time.Duration
+	ServerShutdownTimeout time.Duration
+)
+
+// Connection vars
+var (
+	DatabaseReaderConnections int
+	DatabaseBusyTimeout       time.Duration
+)
+
+// Transaction vars
+var (
+	TransactionIDLength       = 32
+	TransactionIDPrefix       = "tx::"
+	TransactionActionCommit   = "COMMIT"
+	TransactionActionRollback = "ROLLBACK"
+	TransactionIdleTimeout    time.Duration
+	// The idle timeout is capped to the busy timeout divided by this
+	TransactionIdleTimeoutRatio = 4
+)
+
+// Backup vars
+var (
+	BackupFileExtension      = ".db"
+	BackupTimestampFormat    = "20060102T150405Z"
+	BackupPreRestoreSuffix   = "-prerestore"
+	BackupRestoreFileSuffix  = ".restore"
+	BackupTransactionAction  = "BACKUP"
+	RestoreTransactionAction = "RESTORE"
+	BackupContentType        = "application/vnd.sqlite3"
+	BackupCompressionNone    = "none"
+	BackupCompressionGzip    = "gzip"
+	BackupGzipExtension      = ".gz"
+	BackupStatusOK           = "ok"
+	BackupStatusFailed       = "failed"
+	BackupInterval           time.Duration
+	BackupRetentionMaxAge    time.Duration
+)
+
+// Metrics vars
+var (
+	MetricsNamespace        = "simplql"
+	MetricsLabelUnknown     = "unknown"
+	MetricsOperationExecute = "execute"
+	MetricsOperationQuery   = "query"
+	MetricsReloadApplied    = "applied"
+	MetricsReloadRejected   = "rejected"
 )
 
 // Request vars
@@ -85,19 +279,29 @@ var (
 var (
 
 	// Environment vars
-	EncryptionKeyEnvironmentVariable          = "SIMPLQL_ENCRYPTION_KEY"
-	SessionDefaultUsernameEnvironmentVariable = "SIMPLQL_DEFAULT_NAME"
-	SessionDefaultPasswordEnvironmentVariable = "SIMPLQL_DEFAULT_PASSWORD"
-	GlobalDevelopmentBuildEnvironmentVariable = "SIMPLQL_DEV_BUILD"
+	EncryptionKeyEnvironmentVariable             = "SIMPLQL_ENCRYPTION_KEY"
+	EncryptionPassphraseEnvironmentVariable      = "SIMPLQL_ENCRYPTION_PASSPHRASE"
+	SessionDefaultUsernameEnvironmentVariable    = "SIMPLQL_DEFAULT_NAME"
+	SessionDefaultPasswordEnvironmentVariable    = "SIMPLQL_DEFAULT_PASSWORD"
+	SessionSuperAdminNameEnvironmentVariable     = "SIMPLQL_SUPERADMIN_NAME"
+	SessionSuperAdminPasswordEnvironmentVariable = "SIMPLQL_SUPERADMIN_PASSWORD"
+	GlobalDevelopmentBuildEnvironmentVariable    = "SIMPLQL_DEV_BUILD"
 
 	// Headers
 	NetworkingHeaderHealthZ                       = "X-Healthz"
 	NetworkingHeaderCorrelationID                 = "X-Correlation-ID"
 	AuthenticationHeaderJWTSessionToken           = "X-JWT-Token"
 	AuthenticationHeaderSessionTimeout            = "X-Session-Timeout"
+	TransactionHeaderID                           = "X-Transaction-ID"
 	AuthenticationAuthorizationHeader             = "Authorization"
 	AuthenticationAuthorizationHeaderBasicPrefix  = "Basic "
 	AuthenticationAuthorizationHeaderBearerPrefix = "Bearer "
+	AuthenticationAuthorizationHeaderAPIKeyPrefix = "ApiKey "
+	NetworkingHeaderOrigin                        = "Origin"
+	// NetworkingCORSAllowedMethods are the methods which browsers may use in cross-origin requests
+	NetworkingCORSAllowedMethods = "GET, POST, PUT, DELETE"
+	// NetworkingCORSExposedHeaders are the response headers which browsers expose to cross-origin callers
+	NetworkingCORSExposedHeaders = NetworkingHeaderCorrelationID + ", " + AuthenticationHeaderJWTSessionToken + ", " + AuthenticationHeaderSessionTimeout + ", " + TransactionHeaderID
 )
 
 // Encryption vars
@@ -106,6 +310,40 @@ var (
 	EncryptionOriginalFormatHeaderEnd   = "::ORF__"
 	EncryptionOriginalFormatVar         = "$ORGINAL_FORMAT"
 	EncryptionOriginalFormatHeader      = EncryptionOriginalFormatHeaderStart + EncryptionOriginalFormatVar + EncryptionOriginalFormatHeaderEnd
+	// The ID of the key is recorded after the original format (I.e. __ORF::string#1a2b3c4d::ORF__)
+	EncryptionKeyIDSeparator = "#"
+	EncryptionKeyIDLength    = 4
+	// EncryptionRotationBatchSize is the number of rows which are re-encrypted per write transaction during key rotation
+	EncryptionRotationBatchSize = 500
+)
+
+// Blind index vars
+var (
+	// BlindIndexColumnPrefix prefixes the shadow column which holds the blind index of a searchable column (I.e. sys_bidx_email)
+	BlindIndexColumnPrefix = SystemColumnPrefix + "bidx_"
+	// BlindIndexKeyContext is mixed into the key which blind indexes are computed with so that it differs from the encryption key
+	BlindIndexKeyContext = "simplql-blind-index"
+	// BlindIndexReindexBatchSize is the number of blind indexes which are built per write transaction on startup
+	BlindIndexReindexBatchSize = 500
+)
+
+// Key provider vars
+var (
+	KeyProviderFile       = "file"
+	KeyProviderPassphrase = "passphrase"
+	KeyProviderExec       = "exec"
+	// KeyProviderSaltFile is the file (within the encryption path) which holds the salt the passphrase is derived with
+	KeyProviderSaltFile                   = "salt"
+	KeyProviderKDFArgon2id                = "argon2id"
+	KeyProviderKDFScrypt                  = "scrypt"
+	KeyProviderSaltLength                 = 16
+	KeyProviderArgon2idMemory      uint32 = 64 * 1024
+	KeyProviderArgon2idIterations  uint32 = 3
+	KeyProviderArgon2idParallelism uint8  = 2
+	KeyProviderScryptN                    = 1 << 15
+	KeyProviderScryptR                    = 8
+	KeyProviderScryptP                    = 1
+	KeyProviderExecTimeout                = 10 * time.Second
 )
 
 // Error messages
@@ -113,17 +351,55 @@ var (
 	ErrorDataProcessing                     = "PROCESSING_ERROR"
 	ErrorValidatingOriginalFormatHeader     = "VALIDATION_ERROR"
 	ErrorInvalidColumnType                  = "INVALID_COLUMN_TYPE"
+	ErrorInvalidDataType                    = "INVALID_DATA_TYPE"
+	ErrorInvalidConstraint                  = "INVALID_CONSTRAINT"
+	ErrorInvalidIndex                       = "INVALID_INDEX"
+	ErrorInvalidPolicy                      = "INVALID_POLICY"
+	ErrorConstraintUnique                   = "UNIQUE_CONSTRAINT_VIOLATION"
+	ErrorConstraintNotNull                  = "NOT_NULL_CONSTRAINT_VIOLATION"
+	ErrorConstraintCheck                    = "CHECK_CONSTRAINT_VIOLATION"
+	ErrorConstraintForeignKey               = "FOREIGN_KEY_CONSTRAINT_VIOLATION"
 	ErrorInvalidTableName                   = "INVALID_TABLE_NAME"
+	ErrorInvalidColumnName                  = "INVALID_COLUMN_NAME"
+	ErrorTableExists                        = "TABLE_EXISTS"
+	ErrorDatabaseExists                     = "DATABASE_EXISTS"
+	ErrorDatabaseConfigured                 = "DATABASE_CONFIGURED"
+	ErrorInvalidDatabaseName                = "INVALID_DATABASE_NAME"
 	ErrorDatabaseInitialization             = "DATABASE_INITIALIZATION_ERROR"
+	ErrorMigration                          = "MIGRATION_ERROR"
+	ErrorInvalidMigrationStep               = "INVALID_MIGRATION_STEP"
+	ErrorInvalidFilter                      = "INVALID_FILTER"
+	ErrorInvalidOperation                   = "INVALID_OPERATION"
 	ErrorTransaction                        = "TRANSACTION_ERROR"
 	ErrorTransactionTableNameExtraction     = "TRANSACTION_TABLE_NAME_EXTRACTION"
 	ErrorTransactionRecordIDExtraction      = "TRANSACTION_RECORD_ID_EXTRACTION"
 	ErrorTransactionNoEntry                 = "TRANSACTION_NO_ENTRY"
+	ErrorTransactionNotFound                = "TRANSACTION_NOT_FOUND"
+	ErrorTransactionTimedOut                = "TRANSACTION_TIMED_OUT"
+	ErrorShuttingDown                       = "SHUTTING_DOWN"
+	ErrorSnapshotInvalid                    = "SNAPSHOT_INVALID"
+	ErrorSnapshotVersion                    = "SNAPSHOT_VERSION_UNSUPPORTED"
+	ErrorSnapshotEncryption                 = "SNAPSHOT_ENCRYPTION_MISMATCH"
+	ErrorDatabaseBusy                       = "DATABASE_BUSY"
+	ErrorEncryptionDisabled                 = "ENCRYPTION_DISABLED"
+	ErrorEncryptionKeyExists                = "ENCRYPTION_KEY_EXISTS"
+	ErrorEncryptionKeyUnknown               = "ENCRYPTION_KEY_UNKNOWN"
+	ErrorKeyRotationInProgress              = "KEY_ROTATION_IN_PROGRESS"
 	ErrorJWTDisabled                        = "JWT_DISABLED"
+	ErrorInvalidRole                        = "INVALID_ROLE"
+	ErrorRoleExists                         = "ROLE_EXISTS"
+	ErrorRoleNotFound                       = "ROLE_NOT_FOUND"
+	ErrorPermissionDenied                   = "PERMISSION_DENIED"
+	ErrorInvalidAPIKey                      = "INVALID_API_KEY"
+	ErrorAPIKeyNotFound                     = "API_KEY_NOT_FOUND"
+	ErrorAPIKeysDisabled                    = "API_KEYS_DISABLED"
+	ErrorSessionNotFound                    = "SESSION_NOT_FOUND"
+	ErrorInvalidRefreshToken                = "INVALID_REFRESH_TOKEN"
 	ErrorNotExist                           = "DOES_NOT_EXIST"
 	ErrorAuthenticationNoRoles              = "AUTH_NO_ROLES"
 	ErrorAuthenticationInvalid              = "AUTH_INVALID"
 	ErrorAuthenticationUserNotFound         = "AUTH_USER_NOT_FOUND"
 	ErrorAuthenticationJWTExpired           = "AUTH_JWT_EXPIRED"
+	ErrorAuthenticationSuperAdminDisabled   = "AUTH_SUPERADMIN_DISABLED"
 	ErrorAuthenticationJWTExpiredFromJWTLib = "token invalid: expired - Login required to refresh token" // This is the specific error from mitchs-dev/library-go/jwt
 )
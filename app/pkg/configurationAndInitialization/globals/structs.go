@@ -1,5 +1,7 @@
 package globals
 
+import "encoding/json"
+
 type Response struct {
 	Status  string      `json:"status" yaml:"status"`
 	Message string      `json:"message" yaml:"message"`
@@ -12,8 +14,9 @@ type EntryRequest struct {
 }
 
 type EntryRequestEntry struct {
-	Table string                 `json:"table"`
-	Data  map[string]interface{} `json:"data"`
+	Table  string                 `json:"table"`
+	Data   map[string]interface{} `json:"data"`
+	Filter json.RawMessage        `json:"filter,omitempty"`
 }
 
 type EntryCreationResponse struct {
@@ -26,8 +29,32 @@ type EntryCreationResponseEntryReceipt struct {
 	RequestIndex int    `json:"requestIndex"`
 }
 
+type TransactionRequest struct {
+	Database   string                        `json:"database"`
+	Operations []TransactionRequestOperation `json:"operations"`
+}
+
+type TransactionRequestOperation struct {
+	Action string                 `json:"action"`
+	Table  string                 `json:"table"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+	Filter json.RawMessage        `json:"filter,omitempty"`
+}
+
+type TransactionResponse struct {
+	Receipts []TransactionResponseReceipt `json:"receipts"`
+}
+
+type TransactionResponseReceipt struct {
+	RequestIndex int      `json:"requestIndex"`
+	Action       string   `json:"action"`
+	Table        string   `json:"table"`
+	EntryIDs     []string `json:"entryIDs"`
+}
+
 type AuthRequestBody struct {
-	Database string `json:"database,omitempty" yaml:"database,omitempty"`
+	Database string          `json:"database,omitempty" yaml:"database,omitempty"`
+	Filter   json.RawMessage `json:"filter,omitempty" yaml:"filter,omitempty"`
 	Data     struct {
 		ID       string                 `json:"id,omitempty" yaml:"id,omitempty"`
 		Name     string                 `json:"name,omitempty" yaml:"name,omitempty"`
@@ -1,14 +1,18 @@
 package initalization
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/backups"
 	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/keyProvider"
+	"github.com/mitchs-dev/simplQL/pkg/database/rotation"
+	"github.com/mitchs-dev/simplQL/pkg/database/sessions"
 	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
 
 	log "github.com/sirupsen/logrus"
@@ -21,10 +25,9 @@ import (
 	"github.com/mitchs-dev/simplQL/pkg/api/requests"
 )
 
-var c configuration.Configuration
-
 func Run() {
 	var generateConfig bool
+	var rotateKey bool
 	// Configure logging
 	log.SetFormatter(&loggingFormatter.JSONFormatter{
 		Prefix:   "ssql-",
@@ -34,6 +37,7 @@ func Run() {
 	// Parse command line flags
 	flag.StringVarP(&globals.ConfigFile, "config", "c", "", "Path to the configuration file")
 	flag.BoolVarP(&generateConfig, "generate-config", "g", false, "Generate a default configuration file")
+	flag.BoolVar(&rotateKey, "rotate-key", false, "Rotate the encryption key on startup - Every database is re-encrypted in the background while the server is running")
 	flag.Parse()
 	if generateConfig {
 		configuration.GenerateDefaultConfig()
@@ -46,28 +50,24 @@ func Run() {
 	if !processor.DirectoryOrFileExists(globals.ConfigFile) {
 		log.Fatal("Configuration file does not exist: " + globals.ConfigFile)
 	}
-	c.GetConfig()
-	if c.Logging.Debug {
-		log.SetLevel(log.DebugLevel)
-		log.Debug("Debug logging enabled - Use with caution")
-		log.Warn("Debug logging contains information that may be sensitive - It's highly recommended to disable debug logging in production")
-		log.Warn("Debug logging contains more than normal logging that could potentially be performance heavy")
-	} else {
-		log.SetLevel(log.InfoLevel)
-	}
+	c := configuration.Current()
+	runLoggingConfigInit(c)
 
 	if c.Storage.Encryption.Enabled {
 		runEncryptionInit()
-	} else {
-		// We still need to create the storage directory
-		if !processor.DirectoryOrFileExists(c.Storage.Path) {
-			if !processor.CreateDirectory(c.Storage.Path) {
-				log.Fatal("Failed to create storage directory: " + c.Storage.Path)
-			}
+	}
+	// The storage directory is created regardless of where the encryption key comes from
+	if !processor.DirectoryOrFileExists(c.Storage.Path) {
+		if !processor.CreateDirectory(c.Storage.Path) {
+			log.Fatal("Failed to create storage directory: " + c.Storage.Path)
 		}
 	}
 
 	runSessionConfigInit()
+	runServerConfigInit()
+	runConnectionConfigInit()
+	runTransactionConfigInit()
+	runBackupConfigInit()
 
 	// Create databases
 	err := sqlWrapper.CreateDatabases()
@@ -75,95 +75,194 @@ func Run() {
 		log.Fatal("Error when creating databases: " + err.Error())
 	}
 
+	// Rotate the encryption key if requested, otherwise finish a rotation which was interrupted
+	if rotateKey {
+		keyID, err := rotation.Rotate()
+		if err != nil {
+			log.Fatal("Failed to rotate the encryption key: " + err.Error())
+		}
+		log.Info("Rotating the encryption key to: " + keyID)
+	} else {
+		rotation.Resume()
+	}
+
+	// Start scheduled backups
+	backups.Start()
+
+	// Start purging sessions which can no longer be used
+	sessions.Start()
+
 	// Init requests
 	requests.Startup()
+
+	// Reload the configuration on SIGHUP or when the configuration file changes
+	runConfigWatch()
+}
+
+func runLoggingConfigInit(c *configuration.Configuration) {
+	if c.Logging.Debug {
+		log.SetLevel(log.DebugLevel)
+		log.Debug("Debug logging enabled - Use with caution")
+		log.Warn("Debug logging contains information that may be sensitive - It's highly recommended to disable debug logging in production")
+		log.Warn("Debug logging contains more than normal logging that could potentially be performance heavy")
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
 }
 
 func runSessionConfigInit() {
-	c.GetConfig()
+	c := configuration.Current()
 	globals.UseJWT = c.Session.JWT.Enabled
 	log.Debug("JWT enabled: " + fmt.Sprint(globals.UseJWT))
-	globals.JWTTimeoutPeriod = c.Session.JWT.Timeout
-	log.Debug("JWT timeout period: " + globals.JWTTimeoutPeriod)
+	err := validateJWTTimeout(c)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Debug("JWT timeout period: " + c.Session.JWT.Timeout)
+	if c.Session.JWT.PurgeInterval != "" {
+		interval, err := time.ParseDuration(c.Session.JWT.PurgeInterval)
+		if err != nil || interval <= 0 {
+			log.Fatal("Session purge interval (" + c.Session.JWT.PurgeInterval + ") is invalid - Ensure that it is a positive duration (Ex: 15m, 1h) or empty to disable the purge")
+		}
+		globals.SessionPurgeInterval = interval
+	}
+	log.Debug("Session purge interval: " + globals.SessionPurgeInterval.String() + " | Max lifetime: " + c.Session.JWT.MaxLifetime)
+	err = validateAPIKeyMaxLifetime(c)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Debug("API keys enabled: " + fmt.Sprint(c.Session.APIKeys.Enabled))
+}
+
+// validateJWTTimeout ensures that the JWT and refresh token timeouts and the max session lifetime can be used to generate tokens
+func validateJWTTimeout(c *configuration.Configuration) error {
+	timeout, err := time.ParseDuration(c.Session.JWT.Timeout)
+	if err != nil || timeout <= 0 {
+		return errors.New("JWT timeout (" + c.Session.JWT.Timeout + ") is invalid - Ensure that it is a positive duration (Ex: 15m, 1h)")
+	}
+	refreshTimeout, err := time.ParseDuration(c.Session.JWT.RefreshTimeout)
+	if err != nil || refreshTimeout <= 0 {
+		return errors.New("JWT refresh timeout (" + c.Session.JWT.RefreshTimeout + ") is invalid - Ensure that it is a positive duration (Ex: 24h, 168h)")
+	}
+	if c.Session.JWT.MaxLifetime != "" {
+		maxLifetime, err := time.ParseDuration(c.Session.JWT.MaxLifetime)
+		if err != nil || maxLifetime <= 0 {
+			return errors.New("Session max lifetime (" + c.Session.JWT.MaxLifetime + ") is invalid - Ensure that it is a positive duration (Ex: 24h, 720h) or empty")
+		}
+	}
+	return nil
+}
+
+// validateAPIKeyMaxLifetime ensures that the longest expiry of API keys is a positive duration when it is set
+func validateAPIKeyMaxLifetime(c *configuration.Configuration) error {
+	if c.Session.APIKeys.MaxLifetime == "" {
+		return nil
+	}
+	maxLifetime, err := time.ParseDuration(c.Session.APIKeys.MaxLifetime)
+	if err != nil || maxLifetime <= 0 {
+		return errors.New("API key max lifetime (" + c.Session.APIKeys.MaxLifetime + ") is invalid - Ensure that it is a positive duration (Ex: 720h) or empty")
+	}
+	return nil
+}
 
+func runServerConfigInit() {
+	c := configuration.Current()
+	globals.ServerReadTimeout = parseServerTimeout("Read", c.Network.Timeouts.Read)
+	globals.ServerWriteTimeout = parseServerTimeout("Write", c.Network.Timeouts.Write)
+	globals.ServerIdleTimeout = parseServerTimeout("Idle", c.Network.Timeouts.Idle)
+	shutdownTimeout, err := time.ParseDuration(c.Network.Timeouts.Shutdown)
+	if err != nil || shutdownTimeout <= 0 {
+		log.Fatal("Shutdown timeout (" + c.Network.Timeouts.Shutdown + ") is invalid - Ensure that it is a positive duration (Ex: 10s, 30s)")
+	}
+	globals.ServerShutdownTimeout = shutdownTimeout
+	log.Debug("Server timeouts: read " + globals.ServerReadTimeout.String() + " | write " + globals.ServerWriteTimeout.String() + " | idle " + globals.ServerIdleTimeout.String() + " | shutdown " + shutdownTimeout.String())
 }
 
-func runEncryptionInit() {
-	log.Info("Encryption is enabled")
-	encryptionEnvironmentVariable := os.Getenv(globals.EncryptionKeyEnvironmentVariable)
-	if encryptionEnvironmentVariable == "" {
-		log.Debug("Encryption key environment variable not set - checking configuration file")
-		if c.Storage.Encryption.Key == "" {
-			log.Info("Encryption key was not set in the configuration file - Checking for existing key")
-			encryptionKeyDir := c.Storage.Encryption.Path + "/" + globals.EncryptionKeyFile
-			if !processor.DirectoryOrFileExists(encryptionKeyDir) {
-				if !processor.CreateDirectory(c.Storage.Encryption.Path) {
-					log.Fatal("Failed to create encryption key directory: " + c.Storage.Encryption.Path)
-				}
-				// Check if there are any database files in the storage directory
-				files, err := os.ReadDir(c.Storage.Encryption.Path)
-				if err != nil {
-					log.Debug("Storage directory does not exist - Creating")
-					if !processor.CreateDirectory(c.Storage.Path) {
-						log.Fatal("Failed to create storage directory: " + c.Storage.Path)
-					}
-				}
-				for _, file := range files {
-					if !file.IsDir() {
-						if strings.HasSuffix(file.Name(), ".db") {
-							log.Fatal("Database files found in storage directory, encryption is enabled and no key file exists - You should either remove the database files, set the encryption key, or set the encryption to false in the configuration file")
-						}
-					}
-				}
-
-				log.Info("Encryption key file does not exist - Generating new key")
-				globals.EncryptionKey = encryption.GenerateKey()
-				globals.EncryptionIV, err = encryption.GenerateIV()
-				if err != nil {
-					log.Fatal("Failed to generate IV: " + err.Error())
-				}
-				globals.EncryptionIVString = string(globals.EncryptionIV)
-				encryptionKeyString := globals.EncryptionKey + ":" + string(globals.EncryptionIV)
-				encryptedKey, err := encryption.Encrypt(encryptionKeyString, getIDCmdString(), globals.EncryptionIV)
-				if err != nil {
-					log.Fatal("Failed to encrypt the encryption key: " + err.Error())
-				}
-				if !processor.DirectoryOrFileExists(c.Storage.Path) {
-					if !processor.CreateDirectory(c.Storage.Path) {
-						log.Fatal("Failed to create encryption key directory: " + c.Storage.Path)
-					}
-				}
-				if !processor.CreateFile(encryptionKeyDir, encryptedKey) {
-					log.Fatal("Failed to create encryption key file: " + encryptionKeyDir)
-				}
-				log.Info("Successfully generated and stored in key file")
-			} else {
-				log.Info("Encryption key file exists - Reading key")
-				rawEncryptionKey := string(processor.ReadFile(encryptionKeyDir))
-				decryptedKey, err := encryption.Decrypt(rawEncryptionKey, getIDCmdString())
-				if err != nil {
-					log.Fatal("Failed to decrypt the encryption key: " + err.Error())
-				}
-				globals.EncryptionKey = string(strings.Split(decryptedKey, ":")[0])
-				globals.EncryptionIV = []byte(strings.Split(decryptedKey, ":")[1])
-				log.Debug("Encryption key set from key file")
-			}
-
-		} else {
-			log.Info("Encryption key set from configuration file")
-			if !strings.Contains(c.Storage.Encryption.Key, ":") {
-				log.Fatal("Encryption key in configuration file is not in the correct format - Ensure that the key is in the format: key:iv (IV should be 16 bytes and a string)")
-			}
-			globals.EncryptionKey = strings.Split(c.Storage.Encryption.Key, ":")[0]
-			globals.EncryptionIV = []byte(strings.Split(c.Storage.Encryption.Key, ":")[1])
+// parseServerTimeout parses a timeout of the server where 0 disables the timeout
+func parseServerTimeout(name, value string) time.Duration {
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout < 0 {
+		log.Fatal(name + " timeout (" + value + ") is invalid - Ensure that it is a positive duration (Ex: 30s, 5m) or 0 to disable it")
+	}
+	return timeout
+}
+
+func runConnectionConfigInit() {
+	c := configuration.Current()
+	if c.Storage.Connections.Readers <= 0 {
+		log.Fatal("Reader connections (" + fmt.Sprint(c.Storage.Connections.Readers) + ") are invalid - Ensure that it is 1 or higher")
+	}
+	globals.DatabaseReaderConnections = c.Storage.Connections.Readers
+	busyTimeout, err := time.ParseDuration(c.Storage.Connections.BusyTimeout)
+	if err != nil || busyTimeout <= 0 {
+		log.Fatal("Busy timeout (" + c.Storage.Connections.BusyTimeout + ") is invalid - Ensure that it is a positive duration (Ex: 1s, 5s)")
+	}
+	globals.DatabaseBusyTimeout = busyTimeout
+	log.Debug("Reader connections: " + fmt.Sprint(globals.DatabaseReaderConnections) + " | Busy timeout: " + busyTimeout.String())
+}
+
+func runTransactionConfigInit() {
+	c := configuration.Current()
+	idleTimeout, err := time.ParseDuration(c.Storage.Transactions.IdleTimeout)
+	if err != nil || idleTimeout <= 0 {
+		log.Fatal("Transaction idle timeout (" + c.Storage.Transactions.IdleTimeout + ") is invalid - Ensure that it is a positive duration (Ex: 30s, 5m)")
+	}
+	// An open transaction blocks every write to its database so it is rolled back well before the writes waiting for it time out
+	maxIdleTimeout := globals.DatabaseBusyTimeout / time.Duration(globals.TransactionIdleTimeoutRatio)
+	if idleTimeout > maxIdleTimeout {
+		log.Warn("Transaction idle timeout (" + idleTimeout.String() + ") is not well below the busy timeout (" + globals.DatabaseBusyTimeout.String() + ") - Using " + maxIdleTimeout.String())
+		idleTimeout = maxIdleTimeout
+	}
+	globals.TransactionIdleTimeout = idleTimeout
+	log.Debug("Transaction idle timeout: " + idleTimeout.String())
+}
+
+func runBackupConfigInit() {
+	c := configuration.Current()
+	if c.Storage.Backups.Interval != "" {
+		interval, err := time.ParseDuration(c.Storage.Backups.Interval)
+		if err != nil || interval <= 0 {
+			log.Fatal("Backup interval (" + c.Storage.Backups.Interval + ") is invalid - Ensure that it is a positive duration (Ex: 1h, 24h) or empty to disable scheduled backups")
 		}
-	} else {
-		log.Info("Encryption key set from environment variable: " + globals.EncryptionKeyEnvironmentVariable)
-		if !strings.Contains(globals.EncryptionKey, ":") {
-			log.Fatal("Encryption key in environment variable is not in the correct format - Ensure that the key is in the format: key:iv (IV should be 16 bytes and a string)")
+		globals.BackupInterval = interval
+	}
+	if c.Storage.Backups.Retention.MaxAge != "" {
+		maxAge, err := time.ParseDuration(c.Storage.Backups.Retention.MaxAge)
+		if err != nil || maxAge <= 0 {
+			log.Fatal("Backup retention max age (" + c.Storage.Backups.Retention.MaxAge + ") is invalid - Ensure that it is a positive duration (Ex: 24h, 168h) or empty to keep snapshots regardless of age")
 		}
-		globals.EncryptionKey = strings.Split(encryptionEnvironmentVariable, ":")[0]
-		globals.EncryptionIV = []byte(strings.Split(encryptionEnvironmentVariable, ":")[1])
+		globals.BackupRetentionMaxAge = maxAge
+	}
+	if c.Storage.Backups.Retention.Count < 0 {
+		log.Fatal("Backup retention count (" + fmt.Sprint(c.Storage.Backups.Retention.Count) + ") is invalid - Ensure that it is 0 or higher")
+	}
+	switch c.Storage.Backups.Compression {
+	case "", globals.BackupCompressionNone, globals.BackupCompressionGzip:
+	default:
+		log.Fatal("Backup compression (" + c.Storage.Backups.Compression + ") is invalid - Valid options are: " + globals.BackupCompressionNone + ", " + globals.BackupCompressionGzip)
+	}
+	log.Debug("Backup interval: " + globals.BackupInterval.String() + " | Retention: " + fmt.Sprint(c.Storage.Backups.Retention.Count) + " snapshot(s), " + globals.BackupRetentionMaxAge.String() + " | Compression: " + c.Storage.Backups.Compression)
+}
+
+func runEncryptionInit() {
+	log.Info("Encryption is enabled")
+	provider, err := keyProvider.New()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	log.Info("Encryption key provider: " + provider.Name())
+	key, err := provider.Key()
+	if err != nil {
+		log.Fatal("Failed to get the encryption key from provider (" + provider.Name() + "): " + err.Error())
+	}
+	globals.EncryptionKey = key.Secret
+	globals.EncryptionIV = key.IV
+	globals.EncryptionIVString = string(key.IV)
+
+	// Keys added by key rotation are kept in the keyring alongside the provided key
+	err = data.LoadKeyring(key)
+	if err != nil {
+		log.Fatal("Failed to load the encryption keyring: " + err.Error())
 	}
 
 	// Encryption test
@@ -203,11 +302,3 @@ func runEncryptionInit() {
 	}
 	log.Debug("Data processing test passed")
 }
-
-// This function runs the ID command to get the ID of the user running the application
-// This is used as a validation measure for the encryption key
-func getIDCmdString() string {
-	log.Debug("Getting user ID for encryption key")
-	uid := os.Getuid()
-	return strconv.Itoa(uid)
-}
@@ -0,0 +1,113 @@
+package initalization
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runConfigWatch reloads the configuration when SIGHUP is received or when the configuration file changes
+func runConfigWatch() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		lastModified := configFileModified()
+		ticker := time.NewTicker(globals.ConfigWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-signals:
+				lastModified = configFileModified()
+				reloadConfig("SIGHUP")
+			case <-ticker.C:
+				modified := configFileModified()
+				if modified.Equal(lastModified) {
+					continue
+				}
+				lastModified = modified
+				reloadConfig("configuration file changed")
+			}
+		}
+	}()
+	log.Debug("Watching configuration file for changes: " + globals.ConfigFile)
+}
+
+// configFileModified returns when the configuration file was last modified
+// The zero time is returned while the file cannot be read (Ex: While it is being replaced)
+func configFileModified() time.Time {
+	info, err := os.Stat(globals.ConfigFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadConfig parses the configuration file and puts it in use once it is validated
+// The configuration in use is kept if the file cannot be parsed or has a change which requires a restart
+func reloadConfig(reason string) {
+	log.Info("Reloading configuration (" + reason + ")")
+	_, next, err := configuration.Reload(validateReload)
+	if err != nil {
+		metrics.ConfigReload(globals.MetricsReloadRejected)
+		log.Error("Configuration reload rejected - Keeping the configuration in use: " + err.Error())
+		return
+	}
+	runLoggingConfigInit(next)
+	metrics.ConfigReload(globals.MetricsReloadApplied)
+	log.Info("Configuration reloaded")
+}
+
+// validateReload rejects a new configuration which has an unsafe change (Ex: storage path, encryption)
+// Settings which are only read on startup keep their current value until a restart - A warning is logged for each one that changed
+func validateReload(previous, next *configuration.Configuration) error {
+	if next.Storage.Path != previous.Storage.Path {
+		return errors.New("storage.path cannot be changed without a restart")
+	}
+	if !reflect.DeepEqual(next.Storage.Encryption, previous.Storage.Encryption) {
+		return errors.New("storage.encryption cannot be changed without a restart - Use --rotate-key to rotate the encryption key")
+	}
+	err := validateJWTTimeout(next)
+	if err != nil {
+		return err
+	}
+	err = validateAPIKeyMaxLifetime(next)
+	if err != nil {
+		return err
+	}
+
+	restartOnly := func(setting string, changed bool) {
+		if changed {
+			log.Warn(setting + " changed - It is only applied on restart")
+		}
+	}
+	restartOnly("network.port", next.Network.Port != previous.Network.Port)
+	next.Network.Port = previous.Network.Port
+	restartOnly("network.listenAddress", next.Network.ListenAddress != previous.Network.ListenAddress)
+	next.Network.ListenAddress = previous.Network.ListenAddress
+	restartOnly("network.tls", next.Network.TLS != previous.Network.TLS)
+	next.Network.TLS = previous.Network.TLS
+	restartOnly("network.timeouts", next.Network.Timeouts != previous.Network.Timeouts)
+	next.Network.Timeouts = previous.Network.Timeouts
+	restartOnly("session.jwt.enabled", next.Session.JWT.Enabled != previous.Session.JWT.Enabled)
+	next.Session.JWT.Enabled = previous.Session.JWT.Enabled
+	restartOnly("session.jwt.purgeInterval", next.Session.JWT.PurgeInterval != previous.Session.JWT.PurgeInterval)
+	next.Session.JWT.PurgeInterval = previous.Session.JWT.PurgeInterval
+	restartOnly("storage.connections", next.Storage.Connections != previous.Storage.Connections)
+	next.Storage.Connections = previous.Storage.Connections
+	restartOnly("storage.transactions", next.Storage.Transactions != previous.Storage.Transactions)
+	next.Storage.Transactions = previous.Storage.Transactions
+	restartOnly("storage.backups", next.Storage.Backups != previous.Storage.Backups)
+	next.Storage.Backups = previous.Storage.Backups
+	restartOnly("databases", !reflect.DeepEqual(next.Databases, previous.Databases))
+	next.Databases = previous.Databases
+	return nil
+}
@@ -0,0 +1,155 @@
+// backups runs the scheduled backups of every database and keeps the result of the latest run of each
+package backups
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// Result is the result of the latest scheduled backup of a database
+type Result struct {
+	Status      string `json:"status"`
+	LastRun     string `json:"lastRun"`
+	LastSuccess string `json:"lastSuccess,omitempty"`
+	Snapshot    string `json:"snapshot,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Status is the state of the scheduled backups which is surfaced via healthz
+type Status struct {
+	Enabled   bool              `json:"enabled"`
+	Interval  string            `json:"interval,omitempty"`
+	NextRun   string            `json:"nextRun,omitempty"`
+	Healthy   bool              `json:"healthy"`
+	Databases map[string]Result `json:"databases,omitempty"`
+}
+
+var (
+	results     = map[string]Result{}
+	nextRun     time.Time
+	resultsLock sync.Mutex
+)
+
+// Start runs the scheduled backups in the background if a backup interval is configured
+func Start() {
+	if globals.BackupInterval <= 0 {
+		log.Debug("Scheduled backups are disabled")
+		return
+	}
+	log.Info("Scheduled backups are enabled (Every " + globals.BackupInterval.String() + ")")
+	resultsLock.Lock()
+	nextRun = time.Now().Add(globals.BackupInterval)
+	resultsLock.Unlock()
+	go scheduler()
+}
+
+// GetStatus returns the state of the scheduled backups
+// Backups are healthy unless the latest run of a database failed
+func GetStatus() Status {
+	resultsLock.Lock()
+	defer resultsLock.Unlock()
+	status := Status{
+		Enabled: globals.BackupInterval > 0,
+		Healthy: true,
+	}
+	if !status.Enabled {
+		return status
+	}
+	status.Interval = globals.BackupInterval.String()
+	status.NextRun = nextRun.Format(time.RFC3339)
+	status.Databases = make(map[string]Result, len(results))
+	for database, result := range results {
+		status.Databases[database] = result
+		if result.Status != globals.BackupStatusOK {
+			status.Healthy = false
+		}
+	}
+	return status
+}
+
+// scheduler backs up every database once per interval
+func scheduler() {
+	ticker := time.NewTicker(globals.BackupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		resultsLock.Lock()
+		nextRun = time.Now().Add(globals.BackupInterval)
+		resultsLock.Unlock()
+		run()
+	}
+}
+
+// run backs up every configured and provisioned database and prunes their old snapshots
+func run() {
+	databases, err := sqlWrapper.DatabaseNames()
+	if err != nil {
+		log.Error("Failed to list databases for scheduled backups: " + err.Error())
+		return
+	}
+	for _, database := range databases {
+		backup(database)
+	}
+	// Forget the results of databases which have been deprovisioned
+	resultsLock.Lock()
+	for database := range results {
+		if !contains(databases, database) {
+			delete(results, database)
+		}
+	}
+	resultsLock.Unlock()
+}
+
+// contains returns true if the value is in the list of values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// backup backs up a single database and records the result
+func backup(database string) {
+	c := configuration.Current()
+	resultsLock.Lock()
+	result := results[database]
+	resultsLock.Unlock()
+	result.LastRun = time.Now().Format(time.RFC3339)
+	result.Snapshot = ""
+	result.Error = ""
+
+	backupPath, err := sqlWrapper.BackupPath()
+	if err == nil {
+		snapshot := sqlWrapper.SnapshotName(database, c.Storage.Backups.Compression == globals.BackupCompressionGzip)
+		err = sqlWrapper.BackupDatabase(database, filepath.Join(backupPath, snapshot), globals.SystemUserID)
+		if err == nil {
+			result.Snapshot = snapshot
+		}
+	}
+	if err != nil {
+		log.Error("Scheduled backup of database (" + database + ") failed: " + err.Error())
+		result.Status = globals.BackupStatusFailed
+		result.Error = err.Error()
+	} else {
+		result.Status = globals.BackupStatusOK
+		result.LastSuccess = result.LastRun
+		pruned, err := sqlWrapper.PruneSnapshots(database)
+		if err != nil {
+			log.Error("Failed to prune snapshots of database (" + database + "): " + err.Error())
+		} else if len(pruned) > 0 {
+			log.Info("Pruned " + fmt.Sprint(len(pruned)) + " snapshot(s) of database: " + database)
+		}
+	}
+
+	resultsLock.Lock()
+	results[database] = result
+	resultsLock.Unlock()
+}
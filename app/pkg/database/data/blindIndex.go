@@ -0,0 +1,32 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// BlindIndexValue is the blind index of a value - It is stored as is by Process
+type BlindIndexValue string
+
+// blindIndexKey is the key blind indexes are computed with
+var blindIndexKey []byte
+
+// setBlindIndexKey derives the key blind indexes are computed with from the key which was provided at startup
+// It does not change when the encryption key is rotated so that blind indexes stay valid
+func setBlindIndexKey(secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(globals.BlindIndexKeyContext))
+	blindIndexKey = mac.Sum(nil)
+}
+
+// BlindIndex returns the blind index of a plaintext value (A keyed HMAC which can be compared for equality without decrypting)
+// Values are compared by their string representation so that 5 and "5" have the same blind index, as with the stored value
+func BlindIndex(value interface{}) BlindIndexValue {
+	mac := hmac.New(sha256.New, blindIndexKey)
+	mac.Write([]byte(fmt.Sprint(value)))
+	return BlindIndexValue(hex.EncodeToString(mac.Sum(nil)))
+}
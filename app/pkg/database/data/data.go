@@ -3,6 +3,7 @@ package data
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -14,14 +15,27 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-var c configuration.Configuration
-
 var isAWildCard bool
 
 // Process processes the data prior to storage or retrieval
 func Process(data interface{}) interface{} {
 
-	c.GetConfig()
+	c := configuration.Current()
+
+	// Null values are stored as NULL so that they match is_null filters and NOT NULL constraints
+	if data == nil {
+		return nil
+	}
+
+	// Blind indexes are keyed hashes which are compared as is
+	if blindIndex, ok := data.(BlindIndexValue); ok {
+		return string(blindIndex)
+	}
+
+	// Values of unencrypted columns are stored as is
+	if plaintext, ok := data.(PlaintextValue); ok {
+		return plaintext.stored()
+	}
 
 	if globals.IsTransactionExecution {
 		log.Debug("Transaction execution - skipping data processing")
@@ -181,11 +195,18 @@ func Process(data interface{}) interface{} {
 		log.Debug("Encryption enabled - running through encryption process")
 		// Try to decrypt the data first
 		formattedData, formattedDataOriginalFormat := splitDataFromOriginalFormatHeader(dataAsString)
-		decryptedData, dErr := Decrypt(formattedData)
+		formattedDataOriginalFormat, formattedDataKeyID := splitKeyID(formattedDataOriginalFormat)
+		if IsEncrypted(dataAsString) {
+			if _, exists := keyFor(formattedDataKeyID); !exists {
+				log.Error("Data was encrypted with an unknown encryption key (" + formattedDataKeyID + ") - Ensure that the key has not been retired")
+				return globals.ErrorDataProcessing
+			}
+		}
+		decryptedData, dErr := decryptWithKey(formattedData, formattedDataKeyID)
 		if dErr != nil {
 			log.Debug("Could not decrypt data - trying to encrypt")
 			// If decryption fails, try to encrypt the data
-			encryptedData, eErr := Encrypt(dataAsString)
+			encryptedData, encryptedDataKeyID, eErr := encryptWithActiveKey(dataAsString)
 			if eErr != nil {
 				log.Error("Could not de/encrypt data - Ensure that you are not trying to encrypt data when your database is not configured to use encryption")
 				log.Error("Decryption error: " + dErr.Error())
@@ -193,7 +214,7 @@ func Process(data interface{}) interface{} {
 				return globals.ErrorDataProcessing
 			} else {
 				log.Debug("Successfully encrypted data")
-				encryptedDataFinal := setOriginalFormatHeader(encryptedData, originalFormat)
+				encryptedDataFinal := setOriginalFormatHeader(encryptedData, originalFormat+globals.EncryptionKeyIDSeparator+encryptedDataKeyID)
 				if encryptedDataFinal == globals.ErrorDataProcessing {
 					log.Error("Failed to set original format header")
 					return globals.ErrorDataProcessing
@@ -314,12 +335,101 @@ func formatDataToOriginalFormat(data, originalFormat string) (interface{}, error
 	return convertedData, nil
 }
 
-// Encrypt encrypts the data
+// IsEncrypted returns true if the stored value has the original format header of encrypted data
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, globals.EncryptionOriginalFormatHeaderStart) && strings.Contains(stored, globals.EncryptionOriginalFormatHeaderEnd)
+}
+
+// DecryptStored decrypts a stored value without converting it to its original format
+func DecryptStored(stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return "", fmt.Errorf(globals.ErrorValidatingOriginalFormatHeader)
+	}
+	encryptedData, originalFormat := splitDataFromOriginalFormatHeader(stored)
+	_, keyID := splitKeyID(originalFormat)
+	return decryptWithKey(encryptedData, keyID)
+}
+
+// Reencrypt re-encrypts a stored value with the active key
+// The second value is false if the value is not encrypted or is already encrypted with the active key
+func Reencrypt(stored string) (string, bool, error) {
+	if !IsEncrypted(stored) {
+		return stored, false, nil
+	}
+	encryptedData, originalFormat := splitDataFromOriginalFormatHeader(stored)
+	format, keyID := splitKeyID(originalFormat)
+	if keyID != "" && keyID == ActiveKeyID() {
+		return stored, false, nil
+	}
+	if _, exists := keyFor(keyID); !exists {
+		return "", false, errors.New(globals.ErrorEncryptionKeyUnknown + ": " + keyID)
+	}
+	decryptedData, err := decryptWithKey(encryptedData, keyID)
+	if err != nil {
+		return "", false, err
+	}
+	reencryptedData, reencryptedDataKeyID, err := encryptWithActiveKey(decryptedData)
+	if err != nil {
+		return "", false, err
+	}
+	return setOriginalFormatHeader(reencryptedData, format+globals.EncryptionKeyIDSeparator+reencryptedDataKeyID), true, nil
+}
+
+// StoredForms returns every form which the value may be stored in with the keys of the keyring
+// Values are encrypted deterministically per key so that a lookup which matches every form finds rows which were not re-encrypted by a key rotation yet
+func StoredForms(value interface{}) []string {
+	stored := fmt.Sprint(Process(value))
+	if !IsEncrypted(stored) {
+		return []string{stored}
+	}
+	encryptedData, originalFormat := splitDataFromOriginalFormatHeader(stored)
+	format, keyID := splitKeyID(originalFormat)
+	decryptedData, err := decryptWithKey(encryptedData, keyID)
+	if err != nil {
+		return []string{stored}
+	}
+	forms := []string{stored}
+	keys, legacy := keys()
+	for _, key := range keys {
+		encryptedData, err := encryption.Encrypt(decryptedData, key.Secret, key.IV)
+		if err != nil {
+			log.Error("Failed to encrypt value with key (" + key.ID + "): " + err.Error())
+			continue
+		}
+		if key.ID != keyID {
+			forms = append(forms, setOriginalFormatHeader(encryptedData, format+globals.EncryptionKeyIDSeparator+key.ID))
+		}
+		// Values written before key rotation have no key ID in their header
+		if key.ID == legacy {
+			forms = append(forms, setOriginalFormatHeader(encryptedData, format))
+		}
+	}
+	return forms
+}
+
+// Encrypt encrypts the data with the active key
 func Encrypt(data string) (string, error) {
-	return encryption.Encrypt(data, globals.EncryptionKey, globals.EncryptionIV)
+	encryptedData, _, err := encryptWithActiveKey(data)
+	return encryptedData, err
 }
 
-// Decrypt decrypts the data
+// Decrypt decrypts data which was encrypted with the active key
 func Decrypt(data string) (string, error) {
-	return encryption.Decrypt(data, globals.EncryptionKey)
+	return decryptWithKey(data, ActiveKeyID())
+}
+
+// encryptWithActiveKey encrypts the data with the active key and returns the ID of the key
+func encryptWithActiveKey(data string) (string, string, error) {
+	key := activeKey()
+	encryptedData, err := encryption.Encrypt(data, key.Secret, key.IV)
+	return encryptedData, key.ID, err
+}
+
+// decryptWithKey decrypts the data with the key which has the ID - An empty ID uses the key of values written before key rotation
+func decryptWithKey(data, keyID string) (string, error) {
+	key, exists := keyFor(keyID)
+	if !exists {
+		return "", errors.New(globals.ErrorEncryptionKeyUnknown + ": " + keyID)
+	}
+	return encryption.Decrypt(data, key.Secret)
 }
@@ -0,0 +1,27 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// jwtSigningKey is the key the signing keys of the databases are derived from
+var jwtSigningKey []byte
+
+// setJWTSigningKey derives the key JWTs are signed with from the key which was provided at startup
+// It does not change when the encryption key is rotated so that issued JWTs stay valid
+func setJWTSigningKey(secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(globals.JWTSigningKeyContext))
+	jwtSigningKey = mac.Sum(nil)
+}
+
+// JWTSigningKey returns the key the JWTs of the database are signed with - Each database has its own key
+func JWTSigningKey(database string) string {
+	mac := hmac.New(sha256.New, jwtSigningKey)
+	mac.Write([]byte(database))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,236 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mitchs-dev/library-go/encryption"
+	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	log "github.com/sirupsen/logrus"
+)
+
+// Key is an encryption key along with the IV it encrypts with
+// The ID is recorded in the original format header of every value encrypted with the key
+type Key struct {
+	ID     string `json:"id"`
+	Secret string `json:"key"`
+	IV     []byte `json:"iv"`
+}
+
+// keyringFile is the persisted keyring
+type keyringFile struct {
+	// Active is the ID of the key which new values are encrypted with
+	Active string `json:"active"`
+	// Legacy is the ID of the key which values without a key ID in their header were encrypted with
+	Legacy string `json:"legacy"`
+	Keys   []Key  `json:"keys"`
+}
+
+var (
+	keyring     = keyringFile{}
+	keyringKeys = map[string]Key{}
+	// keyringPassword is the secret of the key which was provided at startup - The keyring is encrypted with it
+	keyringPassword string
+	keyringLock     sync.RWMutex
+)
+
+// NewKey returns a key for the secret and IV - The ID is derived from the secret
+func NewKey(secret string, iv []byte) Key {
+	fingerprint := sha256.Sum256([]byte(secret))
+	return Key{
+		ID:     hex.EncodeToString(fingerprint[:globals.EncryptionKeyIDLength]),
+		Secret: secret,
+		IV:     iv,
+	}
+}
+
+// GenerateKey returns a new random key
+func GenerateKey() (Key, error) {
+	iv, err := encryption.GenerateIV()
+	if err != nil {
+		return Key{}, err
+	}
+	return NewKey(encryption.GenerateKey(), iv), nil
+}
+
+// LoadKeyring sets the key which was configured at startup as the active key and merges the persisted keyring into it
+// If the keyring was persisted by a key rotation, its active and legacy keys take precedence
+func LoadKeyring(configured Key) error {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	c := configuration.Current()
+
+	keyring = keyringFile{Active: configured.ID, Legacy: configured.ID}
+	keyringKeys = map[string]Key{configured.ID: configured}
+	keyringPassword = configured.Secret
+	setBlindIndexKey(configured.Secret)
+	setJWTSigningKey(configured.Secret)
+
+	keyringPath := c.Storage.Encryption.Path + "/" + globals.EncryptionKeyringFile
+	if processor.DirectoryOrFileExists(keyringPath) {
+		log.Info("Encryption keyring exists - Reading keys")
+		decryptedKeyring, err := encryption.Decrypt(string(processor.ReadFile(keyringPath)), keyringPassword)
+		if err != nil {
+			return errors.New("failed to decrypt the encryption keyring: " + err.Error())
+		}
+		var persisted keyringFile
+		err = json.Unmarshal([]byte(decryptedKeyring), &persisted)
+		if err != nil {
+			return errors.New("failed to read the encryption keyring (Ensure that the key which was provided when it was written is provided): " + err.Error())
+		}
+		for _, key := range persisted.Keys {
+			keyringKeys[key.ID] = key
+		}
+		if _, exists := keyringKeys[persisted.Active]; exists {
+			keyring.Active = persisted.Active
+		}
+		if _, exists := keyringKeys[persisted.Legacy]; exists {
+			keyring.Legacy = persisted.Legacy
+		}
+	}
+	if keyring.Active != configured.ID {
+		log.Warn("The configured encryption key (" + configured.ID + ") is not the active key (" + keyring.Active + ") - It is only used to read data which has not been rotated yet")
+	}
+	log.Info("Active encryption key: " + keyring.Active + " (" + strconv.Itoa(len(keyringKeys)) + " key(s) loaded)")
+	return nil
+}
+
+// ActivateKey adds the key to the keyring as the active key and persists the keyring
+// Values encrypted with the previous keys remain readable until the keys are retired
+func ActivateKey(key Key) error {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	if _, exists := keyringKeys[key.ID]; exists {
+		return errors.New(globals.ErrorEncryptionKeyExists)
+	}
+	previous := keyring.Active
+	keyringKeys[key.ID] = key
+	keyring.Active = key.ID
+	err := saveKeyring()
+	if err != nil {
+		delete(keyringKeys, key.ID)
+		keyring.Active = previous
+		return err
+	}
+	log.Info("Activated encryption key: " + key.ID)
+	return nil
+}
+
+// RetireKeys removes every key except the active key from the keyring and persists the keyring
+// This must only be called once every value has been re-encrypted with the active key
+func RetireKeys() error {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+	active := keyringKeys[keyring.Active]
+	keyringKeys = map[string]Key{active.ID: active}
+	keyring.Legacy = active.ID
+	err := saveKeyring()
+	if err != nil {
+		return err
+	}
+	log.Info("Retired every encryption key except: " + active.ID)
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key which new values are encrypted with
+func ActiveKeyID() string {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	return keyring.Active
+}
+
+// KeyIDs returns the IDs of the keys in the keyring
+func KeyIDs() []string {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	ids := make([]string, 0, len(keyringKeys))
+	for id := range keyringKeys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// keys returns the keys of the keyring ordered by ID along with the ID of the key which values without a key ID were encrypted with
+func keys() ([]Key, string) {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	keys := make([]Key, 0, len(keyringKeys))
+	for _, key := range keyringKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, keyring.Legacy
+}
+
+// activeKey returns the key which new values are encrypted with
+func activeKey() Key {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	return keyringKeys[keyring.Active]
+}
+
+// keyFor returns the key with the ID - An empty ID returns the key which values without a key ID were encrypted with
+func keyFor(id string) (Key, bool) {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	if id == "" {
+		id = keyring.Legacy
+	}
+	key, exists := keyringKeys[id]
+	return key, exists
+}
+
+// saveKeyring persists the keyring encrypted with the key which was provided at startup
+func saveKeyring() error {
+	c := configuration.Current()
+	persisted := keyringFile{Active: keyring.Active, Legacy: keyring.Legacy}
+	for _, key := range keyringKeys {
+		persisted.Keys = append(persisted.Keys, key)
+	}
+	sort.Slice(persisted.Keys, func(i, j int) bool {
+		return persisted.Keys[i].ID < persisted.Keys[j].ID
+	})
+	keyringData, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+	iv, err := encryption.GenerateIV()
+	if err != nil {
+		return err
+	}
+	encryptedKeyring, err := encryption.Encrypt(string(keyringData), keyringPassword, iv)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(c.Storage.Encryption.Path, 0700)
+	if err != nil {
+		return err
+	}
+	keyringPath := c.Storage.Encryption.Path + "/" + globals.EncryptionKeyringFile
+	err = os.WriteFile(keyringPath+".tmp", []byte(encryptedKeyring), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(keyringPath+".tmp", keyringPath)
+}
+
+// splitKeyID splits the key ID from the original format of a header - Headers written before key rotation have no key ID
+func splitKeyID(originalFormat string) (string, string) {
+	format, keyID, found := strings.Cut(originalFormat, globals.EncryptionKeyIDSeparator)
+	if !found {
+		return originalFormat, ""
+	}
+	return format, keyID
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PlaintextValue is a value of a column which is stored unencrypted - It is stored as is by Process
+type PlaintextValue struct {
+	Value interface{}
+}
+
+// Plaintext marks a value of an unencrypted column so that Process stores it as is
+// Numbers and timestamps which are stored as is can be compared and ordered by SQLite
+func Plaintext(value interface{}) PlaintextValue {
+	return PlaintextValue{Value: value}
+}
+
+// stored returns the value as it is stored - Lists and objects cannot be bound by SQLite so they are stored as JSON
+func (plaintext PlaintextValue) stored() interface{} {
+	switch value := plaintext.Value.(type) {
+	case []interface{}, []string, map[string]interface{}:
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(valueJSON)
+	default:
+		return value
+	}
+}
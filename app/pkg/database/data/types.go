@@ -0,0 +1,85 @@
+package data
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// TypeError lists the fields whose values do not match the type of their column
+type TypeError struct {
+	// Fields maps each offending field to the type of its column
+	Fields map[string]string
+}
+
+func (typeError *TypeError) Error() string {
+	fields := make([]string, 0, len(typeError.Fields))
+	for field := range typeError.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for i, field := range fields {
+		fields[i] = field + " (expected " + typeError.Fields[field] + ")"
+	}
+	return globals.ErrorInvalidDataType + ": " + strings.Join(fields, ", ")
+}
+
+// ValidateType validates a value against the declared type of its column and returns the value as it is stored
+// Null values and columns of other types (I.e. text and blob types) are not validated
+// INTEGER values are stored as int64, DATETIME values in UTC (See: globals.ColumnTypeDateTimeLayout), JSON values as JSON text and UUIDs in lowercase
+func ValidateType(columnType string, value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+	switch strings.ToUpper(strings.TrimSpace(columnType)) {
+	case globals.ColumnTypeInteger:
+		number, ok := value.(float64)
+		if !ok || number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+			return nil, false
+		}
+		return int64(number), true
+	case globals.ColumnTypeReal, globals.ColumnTypeNumeric:
+		number, ok := value.(float64)
+		return number, ok
+	case globals.ColumnTypeBoolean:
+		boolean, ok := value.(bool)
+		return boolean, ok
+	case globals.ColumnTypeDateTime:
+		text, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		for _, layout := range globals.ColumnTypeDateTimeInputLayouts {
+			timestamp, err := time.Parse(layout, text)
+			if err == nil {
+				return timestamp.UTC().Format(globals.ColumnTypeDateTimeLayout), true
+			}
+		}
+		return nil, false
+	case globals.ColumnTypeJSON:
+		// Strings are expected to be JSON text - Other values are encoded as JSON
+		if text, ok := value.(string); ok {
+			return text, json.Valid([]byte(text))
+		}
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+		return string(valueJSON), true
+	case globals.ColumnTypeUUID:
+		text, ok := value.(string)
+		if !ok || !uuidPattern.MatchString(text) {
+			return nil, false
+		}
+		return strings.ToLower(text), true
+	default:
+		return value, true
+	}
+}
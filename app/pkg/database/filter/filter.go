@@ -0,0 +1,413 @@
+// filter compiles structured request filters into parameterized SQL
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// Node is a single node of a filter tree
+// A node is either a group (and/or/not) or a condition (column/op/value)
+type Node struct {
+	And    []Node      `json:"and,omitempty" yaml:"and,omitempty"`
+	Or     []Node      `json:"or,omitempty" yaml:"or,omitempty"`
+	Not    *Node       `json:"not,omitempty" yaml:"not,omitempty"`
+	Column string      `json:"column,omitempty" yaml:"column,omitempty"`
+	Op     string      `json:"op,omitempty" yaml:"op,omitempty"`
+	Value  interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// Storage describes how the columns of a table are stored so that conditions compare against the stored values
+// Only equality operators (eq, ne, in, not_in) can be answered by a blind index - Other operators compare the stored value as is
+type Storage struct {
+	// BlindIndexed are the searchable columns which have a blind index column
+	BlindIndexed map[string]bool
+	// BlindIndex returns the blind index of a value
+	BlindIndex func(value interface{}) interface{}
+	// Plaintext are the columns which are stored unencrypted
+	Plaintext map[string]bool
+	// PlaintextValue marks a value so that it is bound unencrypted
+	PlaintextValue func(value interface{}) interface{}
+}
+
+// comparisonOperators maps the scalar operators to their SQL equivalent
+var comparisonOperators = map[string]string{
+	globals.FilterOperatorEqual:            "=",
+	globals.FilterOperatorNotEqual:         "!=",
+	globals.FilterOperatorLessThan:         "<",
+	globals.FilterOperatorLessThanEqual:    "<=",
+	globals.FilterOperatorGreaterThan:      ">",
+	globals.FilterOperatorGreaterThanEqual: ">=",
+	globals.FilterOperatorLike:             "LIKE",
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidIdentifier returns true if the name can safely be used as a table or column name
+func ValidIdentifier(name string) bool {
+	return identifierPattern.MatchString(name)
+}
+
+// ValidColumn returns true if the name can be used as a column in a filter or select
+// System columns are hidden with the exception of the entry ID
+func ValidColumn(name string) bool {
+	if !ValidIdentifier(name) {
+		return false
+	}
+	if strings.HasPrefix(name, globals.SystemColumnPrefix) && name != globals.TableEntryIDColumnName {
+		return false
+	}
+	return true
+}
+
+// Parse decodes a JSON filter tree
+// An empty or null filter returns a nil node
+func Parse(raw json.RawMessage) (*Node, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil, nil
+	}
+	var node Node
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&node)
+	if err != nil {
+		return nil, errors.New(globals.ErrorInvalidFilter + ": " + err.Error())
+	}
+	return &node, nil
+}
+
+// Equal returns a condition node which matches the column against the value
+func Equal(column string, value interface{}) *Node {
+	return &Node{Column: column, Op: globals.FilterOperatorEqual, Value: value}
+}
+
+// And combines the nodes into a single node, ignoring nil nodes
+func And(nodes ...*Node) *Node {
+	var group []Node
+	for _, node := range nodes {
+		if node != nil {
+			group = append(group, *node)
+		}
+	}
+	switch len(group) {
+	case 0:
+		return nil
+	case 1:
+		return &group[0]
+	default:
+		return &Node{And: group}
+	}
+}
+
+// Columns returns the columns referenced by the conditions of the filter tree
+func Columns(node *Node) []string {
+	if node == nil {
+		return nil
+	}
+	var columns []string
+	if node.Column != "" {
+		columns = append(columns, node.Column)
+	}
+	for i := range node.And {
+		columns = append(columns, Columns(&node.And[i])...)
+	}
+	for i := range node.Or {
+		columns = append(columns, Columns(&node.Or[i])...)
+	}
+	return append(columns, Columns(node.Not)...)
+}
+
+// FromLegacy converts the legacy "column=value" filters into a filter tree
+// A value containing * is matched with LIKE, where * is a wildcard
+func FromLegacy(filters []string) (*Node, error) {
+	var nodes []*Node
+	for _, rawFilter := range filters {
+		rawFilter = strings.TrimSpace(strings.ReplaceAll(rawFilter, "\"", ""))
+		if rawFilter == "" {
+			continue
+		}
+		parts := strings.SplitN(rawFilter, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(globals.ErrorInvalidFilter + ": expected column=value but got (" + rawFilter + ")")
+		}
+		column := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if strings.Contains(value, "*") {
+			nodes = append(nodes, &Node{Column: column, Op: globals.FilterOperatorLike, Value: strings.ReplaceAll(value, "*", "%")})
+		} else {
+			nodes = append(nodes, Equal(column, value))
+		}
+	}
+	return And(nodes...), nil
+}
+
+// Build compiles the filter tree into a SQL condition and its arguments
+// Values are never spliced into the condition - every value is bound as an argument
+func Build(node *Node) (string, []interface{}, error) {
+	return BuildStored(node, nil)
+}
+
+// BuildStored compiles the filter tree like Build, comparing the conditions against the stored values of the columns
+// Equality conditions on searchable columns compare against their blind index and values of unencrypted columns are bound as is
+func BuildStored(node *Node, storage *Storage) (string, []interface{}, error) {
+	if node == nil {
+		return "", nil, nil
+	}
+	var args []interface{}
+	condition, err := build(node, &args, 1, storage)
+	if err != nil {
+		return "", nil, err
+	}
+	return condition, args, nil
+}
+
+// Where returns the WHERE clause (with a leading space) for the filter tree
+func Where(node *Node) (string, []interface{}, error) {
+	return WhereStored(node, nil)
+}
+
+// WhereStored returns the WHERE clause (with a leading space) for the filter tree compiled with BuildStored
+func WhereStored(node *Node, storage *Storage) (string, []interface{}, error) {
+	condition, args, err := BuildStored(node, storage)
+	if err != nil || condition == "" {
+		return "", args, err
+	}
+	return " WHERE " + condition, args, nil
+}
+
+func build(node *Node, args *[]interface{}, depth int, storage *Storage) (string, error) {
+	if depth > globals.FilterMaxDepth {
+		return "", errors.New(globals.ErrorInvalidFilter + ": filter is nested deeper than " + fmt.Sprint(globals.FilterMaxDepth) + " levels")
+	}
+
+	var kinds int
+	if node.And != nil {
+		kinds++
+	}
+	if node.Or != nil {
+		kinds++
+	}
+	if node.Not != nil {
+		kinds++
+	}
+	if node.Column != "" || node.Op != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return "", errors.New(globals.ErrorInvalidFilter + ": each filter node must have exactly one of and, or, not or column/op")
+	}
+
+	switch {
+	case node.And != nil:
+		return buildGroup(node.And, "AND", args, depth, storage)
+	case node.Or != nil:
+		return buildGroup(node.Or, "OR", args, depth, storage)
+	case node.Not != nil:
+		condition, err := build(node.Not, args, depth+1, storage)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + condition + ")", nil
+	}
+
+	if !ValidColumn(node.Column) {
+		return "", errors.New(globals.ErrorInvalidFilter + ": invalid column (" + node.Column + ")")
+	}
+	column := node.Column
+	blindIndexed := storage != nil && storage.BlindIndexed[column]
+	plaintext := storage != nil && storage.Plaintext[column]
+	switch node.Op {
+	case globals.FilterOperatorEqual, globals.FilterOperatorNotEqual, globals.FilterOperatorIn, globals.FilterOperatorNotIn:
+		if blindIndexed {
+			column = globals.BlindIndexColumnPrefix + column
+		}
+	default:
+		blindIndexed = false
+	}
+
+	if sqlOperator, ok := comparisonOperators[node.Op]; ok {
+		value, err := scalar(node.Op, node.Value)
+		if err != nil {
+			return "", err
+		}
+		if node.Op == globals.FilterOperatorLike {
+			if _, ok := value.(string); !ok {
+				return "", errors.New(globals.ErrorInvalidFilter + ": " + node.Op + " requires a string value")
+			}
+		}
+		if blindIndexed {
+			value = storage.BlindIndex(value)
+		} else if plaintext {
+			value = storage.PlaintextValue(value)
+		}
+		*args = append(*args, value)
+		return column + " " + sqlOperator + " ?", nil
+	}
+
+	switch node.Op {
+	case globals.FilterOperatorIn, globals.FilterOperatorNotIn:
+		values, err := list(node.Op, node.Value)
+		if err != nil {
+			return "", err
+		}
+		if len(values) == 0 {
+			return "", errors.New(globals.ErrorInvalidFilter + ": " + node.Op + " requires at least one value")
+		}
+		for i, value := range values {
+			if blindIndexed {
+				values[i] = storage.BlindIndex(value)
+			} else if plaintext {
+				values[i] = storage.PlaintextValue(value)
+			}
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		*args = append(*args, values...)
+		if node.Op == globals.FilterOperatorNotIn {
+			return column + " NOT IN (" + placeholders + ")", nil
+		}
+		return column + " IN (" + placeholders + ")", nil
+	case globals.FilterOperatorBetween:
+		values, err := list(node.Op, node.Value)
+		if err != nil {
+			return "", err
+		}
+		if len(values) != 2 {
+			return "", errors.New(globals.ErrorInvalidFilter + ": " + node.Op + " requires exactly two values")
+		}
+		if plaintext {
+			for i, value := range values {
+				values[i] = storage.PlaintextValue(value)
+			}
+		}
+		*args = append(*args, values...)
+		return column + " BETWEEN ? AND ?", nil
+	case globals.FilterOperatorIsNull:
+		isNull := true
+		if node.Value != nil {
+			value, ok := node.Value.(bool)
+			if !ok {
+				return "", errors.New(globals.ErrorInvalidFilter + ": " + node.Op + " requires a boolean value")
+			}
+			isNull = value
+		}
+		if isNull {
+			return column + " IS NULL", nil
+		}
+		return column + " IS NOT NULL", nil
+	default:
+		return "", errors.New(globals.ErrorInvalidFilter + ": unknown operator (" + node.Op + ") - Valid operators are: " + strings.Join(Operators(), ", "))
+	}
+}
+
+func buildGroup(nodes []Node, operator string, args *[]interface{}, depth int, storage *Storage) (string, error) {
+	if len(nodes) == 0 {
+		return "", errors.New(globals.ErrorInvalidFilter + ": " + strings.ToLower(operator) + " requires at least one filter")
+	}
+	conditions := make([]string, 0, len(nodes))
+	for i := range nodes {
+		condition, err := build(&nodes[i], args, depth+1, storage)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return "(" + strings.Join(conditions, " "+operator+" ") + ")", nil
+}
+
+// scalar validates that the value can be bound as a single argument
+func scalar(op string, value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case string, float64, int, int64, bool:
+		return value, nil
+	case nil:
+		return nil, errors.New(globals.ErrorInvalidFilter + ": " + op + " requires a value - Use " + globals.FilterOperatorIsNull + " to match empty values")
+	default:
+		return nil, errors.New(globals.ErrorInvalidFilter + ": " + op + " requires a single value")
+	}
+}
+
+// list validates that the value is a list of values which can be bound as arguments
+func list(op string, value interface{}) ([]interface{}, error) {
+	var values []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		values = v
+	case []string:
+		for _, s := range v {
+			values = append(values, s)
+		}
+	default:
+		return nil, errors.New(globals.ErrorInvalidFilter + ": " + op + " requires a list of values")
+	}
+	for i, item := range values {
+		checked, err := scalar(op, item)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = checked
+	}
+	return values, nil
+}
+
+// Operators returns the supported condition operators
+func Operators() []string {
+	return []string{
+		globals.FilterOperatorEqual,
+		globals.FilterOperatorNotEqual,
+		globals.FilterOperatorLessThan,
+		globals.FilterOperatorLessThanEqual,
+		globals.FilterOperatorGreaterThan,
+		globals.FilterOperatorGreaterThanEqual,
+		globals.FilterOperatorIn,
+		globals.FilterOperatorNotIn,
+		globals.FilterOperatorLike,
+		globals.FilterOperatorIsNull,
+		globals.FilterOperatorBetween,
+	}
+}
+
+// SortColumns returns the columns referenced by a sort parameter so that they can be checked like the columns of a filter
+func SortColumns(sort string) []string {
+	if sort == "" {
+		return nil
+	}
+	var columns []string
+	for _, term := range strings.Split(sort, ",") {
+		fields := strings.Fields(term)
+		if len(fields) > 0 {
+			columns = append(columns, fields[0])
+		}
+	}
+	return columns
+}
+
+// OrderBy returns the ORDER BY clause (with a leading space) for a sort parameter
+// The sort parameter is a comma separated list of columns, each optionally followed by ASC or DESC
+func OrderBy(sort string) (string, error) {
+	var terms []string
+	for _, term := range strings.Split(sort, ",") {
+		fields := strings.Fields(term)
+		if len(fields) == 0 || len(fields) > 2 || !ValidColumn(fields[0]) {
+			return "", errors.New(globals.ErrorInvalidFilter + ": invalid sort (" + strings.TrimSpace(term) + ")")
+		}
+		if len(fields) == 2 {
+			direction := strings.ToUpper(fields[1])
+			if direction != "ASC" && direction != "DESC" {
+				return "", errors.New(globals.ErrorInvalidFilter + ": invalid sort direction (" + fields[1] + ") - Valid directions are: ASC, DESC")
+			}
+			fields[1] = direction
+		}
+		terms = append(terms, strings.Join(fields, " "))
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
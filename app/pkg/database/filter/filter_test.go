@@ -0,0 +1,354 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    string
+		where     string
+		args      []interface{}
+		errPrefix string
+	}{
+		{
+			name:   "empty filter",
+			filter: ``,
+		},
+		{
+			name:   "null filter",
+			filter: `null`,
+		},
+		{
+			name:   "equal",
+			filter: `{"column":"name","op":"eq","value":"rex"}`,
+			where:  " WHERE name = ?",
+			args:   []interface{}{"rex"},
+		},
+		{
+			name:   "comparison operators",
+			filter: `{"and":[{"column":"legs","op":"gte","value":2},{"column":"legs","op":"lt","value":8},{"column":"name","op":"ne","value":"rex"}]}`,
+			where:  " WHERE (legs >= ? AND legs < ? AND name != ?)",
+			args:   []interface{}{float64(2), float64(8), "rex"},
+		},
+		{
+			name:   "nested groups",
+			filter: `{"or":[{"column":"status","op":"eq","value":"new"},{"and":[{"column":"status","op":"eq","value":"sold"},{"not":{"column":"owner","op":"is_null"}}]}]}`,
+			where:  " WHERE (status = ? OR (status = ? AND NOT (owner IS NULL)))",
+			args:   []interface{}{"new", "sold"},
+		},
+		{
+			name:   "single node group",
+			filter: `{"and":[{"column":"name","op":"like","value":"r%"}]}`,
+			where:  " WHERE name LIKE ?",
+			args:   []interface{}{"r%"},
+		},
+		{
+			name:   "in and not_in",
+			filter: `{"and":[{"column":"status","op":"in","value":["new","sold"]},{"column":"legs","op":"not_in","value":[3]}]}`,
+			where:  " WHERE (status IN (?, ?) AND legs NOT IN (?))",
+			args:   []interface{}{"new", "sold", float64(3)},
+		},
+		{
+			name:   "between",
+			filter: `{"column":"legs","op":"between","value":[2,4]}`,
+			where:  " WHERE legs BETWEEN ? AND ?",
+			args:   []interface{}{float64(2), float64(4)},
+		},
+		{
+			name:   "is_null false",
+			filter: `{"column":"owner","op":"is_null","value":false}`,
+			where:  " WHERE owner IS NOT NULL",
+		},
+		{
+			name:   "entry ID column",
+			filter: `{"column":"` + globals.TableEntryIDColumnName + `","op":"eq","value":"eid"}`,
+			where:  " WHERE " + globals.TableEntryIDColumnName + " = ?",
+			args:   []interface{}{"eid"},
+		},
+		{
+			name:      "unknown field",
+			filter:    `{"column":"name","op":"eq","value":"rex","extra":true}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "injected column",
+			filter:    `{"column":"name; DROP TABLE pets","op":"eq","value":"rex"}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "system column",
+			filter:    `{"column":"` + globals.SystemColumnPrefix + `secret","op":"eq","value":"rex"}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "unknown operator",
+			filter:    `{"column":"name","op":"regex","value":"rex"}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "condition and group in one node",
+			filter:    `{"column":"name","op":"eq","value":"rex","and":[{"column":"legs","op":"eq","value":4}]}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "empty group",
+			filter:    `{"or":[]}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "missing value",
+			filter:    `{"column":"name","op":"eq"}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "list for scalar operator",
+			filter:    `{"column":"name","op":"eq","value":["rex"]}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "like without string",
+			filter:    `{"column":"legs","op":"like","value":4}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "empty in",
+			filter:    `{"column":"status","op":"in","value":[]}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "between with one value",
+			filter:    `{"column":"legs","op":"between","value":[2]}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "is_null without boolean",
+			filter:    `{"column":"owner","op":"is_null","value":"yes"}`,
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "too deep",
+			filter:    strings.Repeat(`{"not":`, globals.FilterMaxDepth) + `{"column":"name","op":"eq","value":"rex"}` + strings.Repeat(`}`, globals.FilterMaxDepth),
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := Parse(json.RawMessage(test.filter))
+			var where string
+			var args []interface{}
+			if err == nil {
+				where, args, err = Where(node)
+			}
+			if test.errPrefix != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.errPrefix) {
+					t.Fatalf("expected an error starting with %s but got: %v", test.errPrefix, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if where != test.where {
+				t.Errorf("where = %q, want %q", where, test.where)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("args = %v, want %v", args, test.args)
+			}
+		})
+	}
+}
+
+func TestWhereStored(t *testing.T) {
+	storage := &Storage{
+		BlindIndexed: map[string]bool{"email": true},
+		BlindIndex: func(value interface{}) interface{} {
+			return "bidx(" + fmt.Sprint(value) + ")"
+		},
+		Plaintext: map[string]bool{"legs": true},
+		PlaintextValue: func(value interface{}) interface{} {
+			return "plain(" + fmt.Sprint(value) + ")"
+		},
+	}
+	tests := []struct {
+		name  string
+		node  *Node
+		where string
+		args  []interface{}
+	}{
+		{
+			name:  "equal on blind indexed column",
+			node:  Equal("email", "a@b.c"),
+			where: " WHERE " + globals.BlindIndexColumnPrefix + "email = ?",
+			args:  []interface{}{"bidx(a@b.c)"},
+		},
+		{
+			name:  "not_in on blind indexed column",
+			node:  &Node{Column: "email", Op: globals.FilterOperatorNotIn, Value: []interface{}{"a", "b"}},
+			where: " WHERE " + globals.BlindIndexColumnPrefix + "email NOT IN (?, ?)",
+			args:  []interface{}{"bidx(a)", "bidx(b)"},
+		},
+		{
+			name:  "like on blind indexed column compares the stored value",
+			node:  &Node{Column: "email", Op: globals.FilterOperatorLike, Value: "a%"},
+			where: " WHERE email LIKE ?",
+			args:  []interface{}{"a%"},
+		},
+		{
+			name:  "range on plaintext column",
+			node:  &Node{Column: "legs", Op: globals.FilterOperatorBetween, Value: []interface{}{float64(2), float64(4)}},
+			where: " WHERE legs BETWEEN ? AND ?",
+			args:  []interface{}{"plain(2)", "plain(4)"},
+		},
+		{
+			name:  "encrypted column",
+			node:  Equal("name", "rex"),
+			where: " WHERE name = ?",
+			args:  []interface{}{"rex"},
+		},
+		{
+			name:  "combined",
+			node:  And(Equal("email", "a@b.c"), nil, &Node{Column: "legs", Op: globals.FilterOperatorGreaterThan, Value: 2}),
+			where: " WHERE (" + globals.BlindIndexColumnPrefix + "email = ? AND legs > ?)",
+			args:  []interface{}{"bidx(a@b.c)", "plain(2)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			where, args, err := WhereStored(test.node, storage)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if where != test.where {
+				t.Errorf("where = %q, want %q", where, test.where)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("args = %v, want %v", args, test.args)
+			}
+		})
+	}
+}
+
+func TestFromLegacy(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   []string
+		where     string
+		args      []interface{}
+		errPrefix string
+	}{
+		{
+			name: "no filters",
+		},
+		{
+			name:    "equal",
+			filters: []string{`name="rex"`},
+			where:   " WHERE name = ?",
+			args:    []interface{}{"rex"},
+		},
+		{
+			name:    "wildcard",
+			filters: []string{"name=r*x", " status = new "},
+			where:   " WHERE (name LIKE ? AND status = ?)",
+			args:    []interface{}{"r%x", "new"},
+		},
+		{
+			name:      "missing value",
+			filters:   []string{"name"},
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node, err := FromLegacy(test.filters)
+			var where string
+			var args []interface{}
+			if err == nil {
+				where, args, err = Where(node)
+			}
+			if test.errPrefix != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.errPrefix) {
+					t.Fatalf("expected an error starting with %s but got: %v", test.errPrefix, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if where != test.where {
+				t.Errorf("where = %q, want %q", where, test.where)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("args = %v, want %v", args, test.args)
+			}
+		})
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		name      string
+		sort      string
+		orderBy   string
+		columns   []string
+		errPrefix string
+	}{
+		{
+			name:    "single column",
+			sort:    "name",
+			orderBy: " ORDER BY name",
+			columns: []string{"name"},
+		},
+		{
+			name:    "directions",
+			sort:    "legs desc, name ASC",
+			orderBy: " ORDER BY legs DESC, name ASC",
+			columns: []string{"legs", "name"},
+		},
+		{
+			name:      "invalid direction",
+			sort:      "name sideways",
+			columns:   []string{"name"},
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "injected column",
+			sort:      "name; DROP TABLE pets",
+			columns:   []string{"name;"},
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+		{
+			name:      "empty term",
+			sort:      "name,",
+			columns:   []string{"name"},
+			errPrefix: globals.ErrorInvalidFilter,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if columns := SortColumns(test.sort); !reflect.DeepEqual(columns, test.columns) {
+				t.Errorf("columns = %v, want %v", columns, test.columns)
+			}
+			orderBy, err := OrderBy(test.sort)
+			if test.errPrefix != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.errPrefix) {
+					t.Fatalf("expected an error starting with %s but got: %v", test.errPrefix, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if orderBy != test.orderBy {
+				t.Errorf("orderBy = %q, want %q", orderBy, test.orderBy)
+			}
+		})
+	}
+}
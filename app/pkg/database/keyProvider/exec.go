@@ -0,0 +1,56 @@
+package keyProvider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// execProvider reads the key from the output of a local helper binary (Ex: A secret manager client)
+// The helper binary must print the key in the format: key:iv
+type execProvider struct{}
+
+func (execProvider) Name() string {
+	return globals.KeyProviderExec
+}
+
+func (execProvider) Key() (data.Key, error) {
+	c := configuration.Current()
+	command := c.Storage.Encryption.Exec.Command
+	if command == "" {
+		return data.Key{}, errors.New("encryption key helper command is not set - Set storage.encryption.exec.command in the configuration file")
+	}
+	timeout := globals.KeyProviderExecTimeout
+	if c.Storage.Encryption.Exec.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(c.Storage.Encryption.Exec.Timeout)
+		if err != nil || timeout <= 0 {
+			return data.Key{}, errors.New("encryption key helper timeout (" + c.Storage.Encryption.Exec.Timeout + ") is invalid - Ensure that it is a positive duration (Ex: 10s, 1m)")
+		}
+	}
+
+	log.Info("Reading encryption key from helper command: " + command)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	helper := exec.CommandContext(ctx, command, c.Storage.Encryption.Exec.Args...)
+	helper.Stdout = &stdout
+	helper.Stderr = &stderr
+	err := helper.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return data.Key{}, errors.New("encryption key helper command timed out after " + timeout.String())
+	}
+	if err != nil {
+		// The output of the helper is not logged as it may contain the key
+		return data.Key{}, errors.New("encryption key helper command failed: " + err.Error() + " - " + strings.TrimSpace(stderr.String()))
+	}
+	return parseKey(strings.TrimRight(stdout.String(), "\r\n"), "helper command output")
+}
@@ -0,0 +1,76 @@
+package keyProvider
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/mitchs-dev/library-go/encryption"
+	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// fileProvider reads the key from the environment variable or the configuration file
+// If neither is set, the key is read from the key file (Which is generated on first start)
+type fileProvider struct{}
+
+func (fileProvider) Name() string {
+	return globals.KeyProviderFile
+}
+
+func (fileProvider) Key() (data.Key, error) {
+	c := configuration.Current()
+	encryptionEnvironmentVariable := os.Getenv(globals.EncryptionKeyEnvironmentVariable)
+	if encryptionEnvironmentVariable != "" {
+		log.Info("Encryption key set from environment variable: " + globals.EncryptionKeyEnvironmentVariable)
+		return parseKey(encryptionEnvironmentVariable, "environment variable")
+	}
+	log.Debug("Encryption key environment variable not set - checking configuration file")
+	if c.Storage.Encryption.Key != "" {
+		log.Info("Encryption key set from configuration file")
+		return parseKey(c.Storage.Encryption.Key, "configuration file")
+	}
+
+	log.Info("Encryption key was not set in the configuration file - Checking for existing key")
+	encryptionKeyFile := c.Storage.Encryption.Path + "/" + globals.EncryptionKeyFile
+	if processor.DirectoryOrFileExists(encryptionKeyFile) {
+		log.Info("Encryption key file exists - Reading key")
+		decryptedKey, err := encryption.Decrypt(string(processor.ReadFile(encryptionKeyFile)), keyFilePassword())
+		if err != nil {
+			return data.Key{}, errors.New("failed to decrypt the encryption key: " + err.Error())
+		}
+		log.Debug("Encryption key set from key file")
+		return parseKey(decryptedKey, "key file")
+	}
+
+	if !processor.CreateDirectory(c.Storage.Encryption.Path) {
+		return data.Key{}, errors.New("failed to create encryption key directory: " + c.Storage.Encryption.Path)
+	}
+	err := checkForExistingDatabases()
+	if err != nil {
+		return data.Key{}, err
+	}
+	log.Info("Encryption key file does not exist - Generating new key")
+	key, err := data.GenerateKey()
+	if err != nil {
+		return data.Key{}, errors.New("failed to generate key: " + err.Error())
+	}
+	encryptedKey, err := encryption.Encrypt(key.Secret+":"+string(key.IV), keyFilePassword(), key.IV)
+	if err != nil {
+		return data.Key{}, errors.New("failed to encrypt the encryption key: " + err.Error())
+	}
+	if !processor.CreateFile(encryptionKeyFile, encryptedKey) {
+		return data.Key{}, errors.New("failed to create encryption key file: " + encryptionKeyFile)
+	}
+	log.Info("Successfully generated and stored in key file")
+	return key, nil
+}
+
+// keyFilePassword returns the password the key file is encrypted with (The ID of the user running the application)
+func keyFilePassword() string {
+	log.Debug("Getting user ID for encryption key")
+	return strconv.Itoa(os.Getuid())
+}
@@ -0,0 +1,70 @@
+// keyProvider provides the key which the storage is encrypted with
+package keyProvider
+
+import (
+	"crypto/aes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyProvider provides the storage encryption key
+type KeyProvider interface {
+	// Name returns the name of the provider as set in storage.encryption.provider
+	Name() string
+	// Key returns the encryption key along with the IV it encrypts with
+	Key() (data.Key, error)
+}
+
+// New returns the key provider which is set in the configuration
+func New() (KeyProvider, error) {
+	c := configuration.Current()
+	switch c.Storage.Encryption.Provider {
+	case "", globals.KeyProviderFile:
+		return fileProvider{}, nil
+	case globals.KeyProviderPassphrase:
+		return passphraseProvider{}, nil
+	case globals.KeyProviderExec:
+		return execProvider{}, nil
+	}
+	return nil, errors.New("encryption key provider (" + c.Storage.Encryption.Provider + ") is invalid - Valid options are: " + globals.KeyProviderFile + ", " + globals.KeyProviderPassphrase + ", " + globals.KeyProviderExec)
+}
+
+// parseKey parses a key in the format: key:iv (IV should be 16 bytes and a string)
+func parseKey(value, source string) (data.Key, error) {
+	secret, iv, found := strings.Cut(value, ":")
+	if !found || secret == "" {
+		return data.Key{}, errors.New("encryption key in " + source + " is not in the correct format - Ensure that the key is in the format: key:iv (IV should be 16 bytes and a string)")
+	}
+	if len(iv) != aes.BlockSize {
+		return data.Key{}, errors.New("encryption IV in " + source + " is " + strconv.Itoa(len(iv)) + " bytes - Ensure that the IV is " + strconv.Itoa(aes.BlockSize) + " bytes")
+	}
+	return data.NewKey(secret, []byte(iv)), nil
+}
+
+// checkForExistingDatabases returns an error if there are database files in the storage path
+// A new key must not be generated once data exists as it would not be able to decrypt the data
+func checkForExistingDatabases() error {
+	c := configuration.Current()
+	files, err := os.ReadDir(c.Storage.Path)
+	if err != nil {
+		log.Debug("Storage directory does not exist - Creating")
+		err = os.MkdirAll(c.Storage.Path, 0755)
+		if err != nil {
+			return errors.New("failed to create storage directory: " + c.Storage.Path)
+		}
+		return nil
+	}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".db") {
+			return errors.New("database files found in storage directory, encryption is enabled and no key exists - You should either remove the database files, set the encryption key, or set the encryption to false in the configuration file")
+		}
+	}
+	return nil
+}
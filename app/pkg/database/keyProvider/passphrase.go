@@ -0,0 +1,129 @@
+package keyProvider
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// passphraseProvider derives the key from a passphrase with a key derivation function
+// The salt is generated on first start and stored in the salt file along with the ID of the derived key
+type passphraseProvider struct{}
+
+// saltFile is the persisted salt file
+type saltFile struct {
+	KDF  string `json:"kdf"`
+	Salt string `json:"salt"`
+	// KeyID is the ID of the derived key - It is used to detect an incorrect passphrase
+	KeyID string `json:"keyID"`
+}
+
+// derivedKeyLength is the length of the key and the IV which are derived from the passphrase
+const derivedKeyLength = 32
+
+func (passphraseProvider) Name() string {
+	return globals.KeyProviderPassphrase
+}
+
+func (passphraseProvider) Key() (data.Key, error) {
+	c := configuration.Current()
+	passphrase := os.Getenv(globals.EncryptionPassphraseEnvironmentVariable)
+	if passphrase != "" {
+		log.Info("Encryption passphrase set from environment variable: " + globals.EncryptionPassphraseEnvironmentVariable)
+	} else {
+		passphrase = c.Storage.Encryption.Passphrase.Value
+		if passphrase == "" {
+			return data.Key{}, errors.New("encryption passphrase is not set - Set it in the configuration file or the environment variable: " + globals.EncryptionPassphraseEnvironmentVariable)
+		}
+		log.Info("Encryption passphrase set from configuration file")
+	}
+	kdf := c.Storage.Encryption.Passphrase.KDF
+	if kdf == "" {
+		kdf = globals.KeyProviderKDFArgon2id
+	}
+
+	saltFilePath := c.Storage.Encryption.Path + "/" + globals.KeyProviderSaltFile
+	if processor.DirectoryOrFileExists(saltFilePath) {
+		log.Info("Salt file exists - Deriving key")
+		var persisted saltFile
+		err := json.Unmarshal(processor.ReadFile(saltFilePath), &persisted)
+		if err != nil {
+			return data.Key{}, errors.New("failed to read the salt file: " + err.Error())
+		}
+		if persisted.KDF != kdf {
+			return data.Key{}, errors.New("key derivation function (" + kdf + ") does not match the salt file (" + persisted.KDF + ") - Changing the key derivation function would change the key")
+		}
+		salt, err := hex.DecodeString(persisted.Salt)
+		if err != nil {
+			return data.Key{}, errors.New("failed to read the salt file: " + err.Error())
+		}
+		key, err := deriveKey(passphrase, salt, kdf)
+		if err != nil {
+			return data.Key{}, err
+		}
+		if key.ID != persisted.KeyID {
+			return data.Key{}, errors.New("encryption passphrase is incorrect - The derived key (" + key.ID + ") does not match the salt file (" + persisted.KeyID + ")")
+		}
+		return key, nil
+	}
+
+	if !processor.CreateDirectory(c.Storage.Encryption.Path) {
+		return data.Key{}, errors.New("failed to create encryption key directory: " + c.Storage.Encryption.Path)
+	}
+	err := checkForExistingDatabases()
+	if err != nil {
+		return data.Key{}, err
+	}
+	log.Info("Salt file does not exist - Generating new salt")
+	salt := make([]byte, globals.KeyProviderSaltLength)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return data.Key{}, errors.New("failed to generate salt: " + err.Error())
+	}
+	key, err := deriveKey(passphrase, salt, kdf)
+	if err != nil {
+		return data.Key{}, err
+	}
+	saltFileData, err := json.Marshal(saltFile{KDF: kdf, Salt: hex.EncodeToString(salt), KeyID: key.ID})
+	if err != nil {
+		return data.Key{}, err
+	}
+	err = os.WriteFile(saltFilePath, saltFileData, 0600)
+	if err != nil {
+		return data.Key{}, errors.New("failed to create salt file: " + err.Error())
+	}
+	log.Info("Successfully derived key and stored salt in salt file")
+	return key, nil
+}
+
+// deriveKey derives the key and the IV from the passphrase
+func deriveKey(passphrase string, salt []byte, kdf string) (data.Key, error) {
+	var derived []byte
+	var err error
+	switch kdf {
+	case globals.KeyProviderKDFArgon2id:
+		derived = argon2.IDKey([]byte(passphrase), salt, globals.KeyProviderArgon2idIterations, globals.KeyProviderArgon2idMemory, globals.KeyProviderArgon2idParallelism, derivedKeyLength+aes.BlockSize)
+	case globals.KeyProviderKDFScrypt:
+		derived, err = scrypt.Key([]byte(passphrase), salt, globals.KeyProviderScryptN, globals.KeyProviderScryptR, globals.KeyProviderScryptP, derivedKeyLength+aes.BlockSize)
+		if err != nil {
+			return data.Key{}, errors.New("failed to derive key: " + err.Error())
+		}
+	default:
+		return data.Key{}, errors.New("key derivation function (" + kdf + ") is invalid - Valid options are: " + globals.KeyProviderKDFArgon2id + ", " + globals.KeyProviderKDFScrypt)
+	}
+	secret := strings.ReplaceAll(base64.URLEncoding.EncodeToString(derived[:derivedKeyLength]), "=", "")
+	return data.NewKey(secret, derived[derivedKeyLength:]), nil
+}
@@ -0,0 +1,123 @@
+// rotation rotates the encryption key and re-encrypts every database with the new key in the background
+package rotation
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// Status is the state of the latest key rotation
+type Status struct {
+	Running     bool     `json:"running"`
+	ActiveKeyID string   `json:"activeKeyID"`
+	KeyIDs      []string `json:"keyIDs"`
+	StartedAt   string   `json:"startedAt,omitempty"`
+	FinishedAt  string   `json:"finishedAt,omitempty"`
+	Reencrypted int      `json:"reencrypted"`
+	Error       string   `json:"error,omitempty"`
+}
+
+var (
+	status     Status
+	statusLock sync.Mutex
+)
+
+// Rotate activates a new randomly generated key and re-encrypts every database with it in the background
+// The ID of the new key is returned
+func Rotate() (string, error) {
+	c := configuration.Current()
+	if !c.Storage.Encryption.Enabled {
+		return "", errors.New(globals.ErrorEncryptionDisabled)
+	}
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	if status.Running {
+		return "", errors.New(globals.ErrorKeyRotationInProgress)
+	}
+	key, err := data.GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	err = data.ActivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	start()
+	return key.ID, nil
+}
+
+// Resume re-encrypts every database in the background if a previous rotation did not complete (I.e. the server was stopped)
+func Resume() {
+	c := configuration.Current()
+	if !c.Storage.Encryption.Enabled || len(data.KeyIDs()) < 2 {
+		return
+	}
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	if status.Running {
+		return
+	}
+	log.Warn("A previous key rotation did not complete - Resuming re-encryption with key: " + data.ActiveKeyID())
+	start()
+}
+
+// GetStatus returns the state of the latest key rotation
+func GetStatus() Status {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	current := status
+	current.ActiveKeyID = data.ActiveKeyID()
+	current.KeyIDs = data.KeyIDs()
+	return current
+}
+
+// start marks the rotation as running and re-encrypts in the background - The status lock must be held
+func start() {
+	status = Status{
+		Running:   true,
+		StartedAt: time.Now().Format(time.RFC3339),
+	}
+	go reencrypt()
+}
+
+// reencrypt re-encrypts every database and retires the previous keys once every value uses the active key
+func reencrypt() {
+	activeKeyID := data.ActiveKeyID()
+	log.Info("Re-encrypting every database with key: " + activeKeyID)
+	var reencrypted int
+	err := func() error {
+		databases, err := sqlWrapper.DatabaseNames()
+		if err != nil {
+			return err
+		}
+		for _, database := range databases {
+			count, err := sqlWrapper.ReencryptDatabase(database, globals.EncryptionRotationBatchSize)
+			reencrypted += count
+			if err != nil {
+				return errors.New("database (" + database + "): " + err.Error())
+			}
+			log.Info("Re-encrypted " + fmt.Sprint(count) + " value(s) of database: " + database)
+		}
+		return data.RetireKeys()
+	}()
+
+	statusLock.Lock()
+	defer statusLock.Unlock()
+	status.Running = false
+	status.FinishedAt = time.Now().Format(time.RFC3339)
+	status.Reencrypted = reencrypted
+	if err != nil {
+		status.Error = err.Error()
+		log.Error("Key rotation to key (" + activeKeyID + ") failed - Previous keys are kept so that existing data stays readable: " + err.Error())
+		return
+	}
+	log.Info("Key rotation to key (" + activeKeyID + ") completed - Re-encrypted " + fmt.Sprint(reencrypted) + " value(s)")
+}
@@ -0,0 +1,78 @@
+// sessions periodically purges the sessions of every database which can no longer be used
+package sessions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	"github.com/mitchs-dev/simplQL/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Start purges the sessions in the background if a purge interval is configured
+// A purge runs right away as sessions may have expired while the server was stopped
+func Start() {
+	if globals.SessionPurgeInterval <= 0 {
+		log.Debug("Session purge is disabled")
+		return
+	}
+	log.Info("Session purge is enabled (Every " + globals.SessionPurgeInterval.String() + ")")
+	go scheduler()
+}
+
+// scheduler purges the sessions of every database once per interval
+func scheduler() {
+	run()
+	ticker := time.NewTicker(globals.SessionPurgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		run()
+	}
+}
+
+// run deletes the sessions whose refresh token expired and the sessions which exceeded the max lifetime from every database
+func run() {
+	c := configuration.Current()
+	now := time.Now()
+	var maxLifetimeStart int64
+	if c.Session.JWT.MaxLifetime != "" {
+		maxLifetime, err := time.ParseDuration(c.Session.JWT.MaxLifetime)
+		if err != nil {
+			log.Error("Failed to parse session max lifetime (" + c.Session.JWT.MaxLifetime + "): " + err.Error())
+			return
+		}
+		maxLifetimeStart = now.Add(-maxLifetime).Unix()
+	}
+	databases, err := sqlWrapper.DatabaseNames()
+	if err != nil {
+		log.Error("Failed to list databases for session purge: " + err.Error())
+		return
+	}
+	var totalExpired, totalExceeded int64
+	for _, database := range databases {
+		wrapper, err := sqlWrapper.Open(database)
+		if err != nil {
+			log.Error("Failed to open database (" + database + ") for session purge: " + err.Error())
+			continue
+		}
+		exceeded, expired, err := wrapper.PurgeSessions(now.Unix(), maxLifetimeStart)
+		wrapper.Release()
+		if err != nil {
+			log.Error("Failed to purge sessions of database (" + database + "): " + err.Error())
+			continue
+		}
+		metrics.SessionsPurged(database, globals.SessionPurgeReasonExpired, expired)
+		metrics.SessionsPurged(database, globals.SessionPurgeReasonMaxLifetime, exceeded)
+		if expired+exceeded > 0 {
+			log.Debug("Purged " + fmt.Sprint(expired) + " expired session(s) and " + fmt.Sprint(exceeded) + " session(s) which exceeded the max lifetime of database: " + database)
+		}
+		totalExpired += expired
+		totalExceeded += exceeded
+	}
+	if totalExpired+totalExceeded > 0 {
+		log.Info("Purged " + fmt.Sprint(totalExpired) + " expired session(s) and " + fmt.Sprint(totalExceeded) + " session(s) which exceeded the max lifetime from " + fmt.Sprint(len(databases)) + " database(s)")
+	}
+}
@@ -0,0 +1,153 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// apiKeysTableQuery creates the API keys table which holds the API keys of the users with the hashes of their secrets
+var apiKeysTableQuery = `CREATE TABLE IF NOT EXISTS ` + globals.APIKeysTable + ` (id TEXT PRIMARY KEY, userID TEXT NOT NULL, name TEXT NOT NULL, secret TEXT NOT NULL, roles TEXT NOT NULL, allowedIPs TEXT NOT NULL, expiresAt TEXT NOT NULL, lastUsedAt TEXT NOT NULL, createdAt TEXT NOT NULL)`
+
+// APIKey is a struct that holds an API key of a user without its secret
+// Requests authenticated with the key are limited to its roles (Every role of the user when none are listed) and allowed IP addresses
+type APIKey struct {
+	ID         string   `json:"id" yaml:"id"`
+	UserID     string   `json:"userID" yaml:"userID"`
+	Name       string   `json:"name" yaml:"name"`
+	Roles      []string `json:"roles" yaml:"roles"`
+	AllowedIPs []string `json:"allowedIPs" yaml:"allowedIPs"`
+	ExpiresAt  string   `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	LastUsedAt string   `json:"lastUsedAt,omitempty" yaml:"lastUsedAt,omitempty"`
+	CreatedAt  string   `json:"createdAt" yaml:"createdAt"`
+}
+
+// APIKeys returns the API keys of the user ordered by creation - Every key of the database is returned when the user is empty
+func (wrapper *SQLiteWrapper) APIKeys(userID string) ([]APIKey, error) {
+	keys := []APIKey{}
+	query := `SELECT id, userID, name, roles, allowedIPs, expiresAt, lastUsedAt, createdAt, secret FROM ` + globals.APIKeysTable
+	var args []interface{}
+	if userID != "" {
+		query += ` WHERE userID = ?`
+		args = append(args, userID)
+	}
+	rows, err := wrapper.reader.Query(query+` ORDER BY createdAt, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		key, _, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// APIKey returns the API key with the ID along with the hash of its secret
+func (wrapper *SQLiteWrapper) APIKey(id string) (APIKey, string, bool, error) {
+	return readAPIKey(wrapper.reader, id)
+}
+
+// CreateAPIKey stores the API key with the hash of its secret
+func (wrapper *SQLiteWrapper) CreateAPIKey(key APIKey, secretHash, userID string) error {
+	roles, err := json.Marshal(key.Roles)
+	if err != nil {
+		return err
+	}
+	allowedIPs, err := json.Marshal(key.AllowedIPs)
+	if err != nil {
+		return err
+	}
+	_, err = wrapper.exec(`INSERT INTO `+globals.APIKeysTable+` (id, userID, name, secret, roles, allowedIPs, expiresAt, lastUsedAt, createdAt) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, key.ID, key.UserID, key.Name, secretHash, string(roles), string(allowedIPs), key.ExpiresAt, key.LastUsedAt, key.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return auditAPIKey(wrapper.name, userID, globals.APIKeyCreateTransactionAction, key)
+}
+
+// RevokeAPIKey deletes the API key with the ID - Keys of other users are not found unless the owner is empty
+func (wrapper *SQLiteWrapper) RevokeAPIKey(id, ownerID, userID string) (APIKey, error) {
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		return APIKey{}, err
+	}
+	tx := transaction.tx
+	key, _, exists, err := readAPIKey(tx, id)
+	if err != nil {
+		transaction.Rollback(err)
+		return APIKey{}, err
+	}
+	if !exists || (ownerID != "" && key.UserID != ownerID) {
+		transaction.Rollback(nil)
+		return APIKey{}, errors.New(globals.ErrorAPIKeyNotFound + ": " + id)
+	}
+	_, err = tx.Exec(`DELETE FROM `+globals.APIKeysTable+` WHERE id = ?`, id)
+	if err != nil {
+		transaction.Rollback(err)
+		return APIKey{}, err
+	}
+	err = transaction.Commit()
+	if err != nil {
+		return APIKey{}, err
+	}
+	return key, auditAPIKey(wrapper.name, userID, globals.APIKeyRevokeTransactionAction, key)
+}
+
+// TouchAPIKey records when the API key was last used - It is not recorded in the transaction log as it changes on every request
+func (wrapper *SQLiteWrapper) TouchAPIKey(id, usedAt string) error {
+	_, err := wrapper.exec(`UPDATE `+globals.APIKeysTable+` SET lastUsedAt = ? WHERE id = ?`, usedAt, id)
+	return err
+}
+
+// readAPIKey reads an API key by ID along with the hash of its secret
+func readAPIKey(q queryer, id string) (APIKey, string, bool, error) {
+	rows, err := q.Query(`SELECT id, userID, name, roles, allowedIPs, expiresAt, lastUsedAt, createdAt, secret FROM `+globals.APIKeysTable+` WHERE id = ?`, id)
+	if err != nil {
+		return APIKey{}, "", false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return APIKey{}, "", false, rows.Err()
+	}
+	key, secretHash, err := scanAPIKey(rows)
+	if err != nil {
+		return APIKey{}, "", false, err
+	}
+	return key, secretHash, true, nil
+}
+
+// scanAPIKey scans an API key and the hash of its secret from the current row
+func scanAPIKey(rows *sql.Rows) (APIKey, string, error) {
+	var key APIKey
+	var roles, allowedIPs, secretHash string
+	err := rows.Scan(&key.ID, &key.UserID, &key.Name, &roles, &allowedIPs, &key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt, &secretHash)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	err = json.Unmarshal([]byte(roles), &key.Roles)
+	if err == nil {
+		err = json.Unmarshal([]byte(allowedIPs), &key.AllowedIPs)
+	}
+	if err != nil {
+		return APIKey{}, "", errors.New("failed to parse API key (" + key.ID + "): " + err.Error())
+	}
+	return key, secretHash, nil
+}
+
+// auditAPIKey records the creation or revocation of an API key in the transaction log - The secret is never recorded
+func auditAPIKey(database, userID, action string, key APIKey) error {
+	encoded, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	oldValues, newValues := "", string(encoded)
+	if action == globals.APIKeyRevokeTransactionAction {
+		oldValues, newValues = newValues, ""
+	}
+	return createTransaction(database, userID, action, globals.APIKeysTable, key.ID, oldValues, newValues, "", "SUCCESS", nil)
+}
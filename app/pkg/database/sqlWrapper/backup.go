@@ -0,0 +1,409 @@
+package sqlWrapper
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BackupPath returns the directory which snapshots are written to and restored from - It is created if it does not exist
+func BackupPath() (string, error) {
+	c := configuration.Current()
+	err := os.MkdirAll(c.Storage.Backups.Path, 0700)
+	if err != nil {
+		return "", err
+	}
+	return c.Storage.Backups.Path, nil
+}
+
+// SnapshotName returns a timestamped snapshot file name for the database - The gzip extension is added if the snapshot is compressed
+func SnapshotName(database string, compressed bool) string {
+	name := database + "-" + time.Now().UTC().Format(globals.BackupTimestampFormat) + globals.BackupFileExtension
+	if compressed {
+		name += globals.BackupGzipExtension
+	}
+	return name
+}
+
+// snapshotTime returns the time a snapshot of the database was taken at based on its name
+// Snapshots which were not named by SnapshotName (I.e. pre-restore snapshots) are ignored
+func snapshotTime(database, name string) (time.Time, bool) {
+	timestamp := strings.TrimPrefix(name, database+"-")
+	if timestamp == name {
+		return time.Time{}, false
+	}
+	timestamp = strings.TrimSuffix(timestamp, globals.BackupGzipExtension)
+	timestamp = strings.TrimSuffix(timestamp, globals.BackupFileExtension)
+	takenAt, err := time.Parse(globals.BackupTimestampFormat, timestamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return takenAt, true
+}
+
+// BackupDatabase writes a consistent snapshot of the database to the destination using VACUUM INTO
+// The destination must not exist or must be an empty file - If it has the gzip extension, the snapshot is compressed
+func BackupDatabase(database, destination, userID string) error {
+	if !validIdentifier(database) {
+		return errors.New(globals.ErrorInvalidDatabaseName)
+	}
+	if !DatabaseExists(database) {
+		return errors.New(globals.ErrorNotExist)
+	}
+	wrapper, err := Open(database)
+	if err != nil {
+		return err
+	}
+	defer wrapper.Release()
+
+	log.Info("Backing up database (" + database + ") to: " + destination)
+	err = snapshotDatabase(wrapper, destination)
+	if err != nil {
+		log.Error("Failed to back up database (" + database + "): " + err.Error())
+		txErr := createTransaction(database, userID, globals.BackupTransactionAction, globals.MetadataTable, "", "", filepath.Base(destination), "", "ERROR", err)
+		if txErr != nil {
+			log.Error("Error when logging failed backup: " + txErr.Error())
+		}
+		return err
+	}
+	err = createTransaction(database, userID, globals.BackupTransactionAction, globals.MetadataTable, "", "", filepath.Base(destination), "", "SUCCESS", nil)
+	if err != nil {
+		log.Error("Error when logging backup: " + err.Error())
+	}
+	log.Info("Backed up database (" + database + ") to: " + destination)
+	return nil
+}
+
+// snapshotDatabase runs VACUUM INTO the destination and compresses the snapshot if the destination has the gzip extension
+func snapshotDatabase(wrapper *SQLiteWrapper, destination string) error {
+	// VACUUM INTO reads the database in a single read transaction so writers are not blocked and the snapshot is consistent
+	if !strings.HasSuffix(destination, globals.BackupGzipExtension) {
+		_, err := wrapper.reader.Exec("VACUUM INTO ?", destination)
+		return err
+	}
+	uncompressed := strings.TrimSuffix(destination, globals.BackupGzipExtension)
+	defer os.Remove(uncompressed)
+	_, err := wrapper.reader.Exec("VACUUM INTO ?", uncompressed)
+	if err != nil {
+		return err
+	}
+	return compressFile(uncompressed, destination)
+}
+
+// PruneSnapshots removes the snapshots of the database which are beyond the retention count or older than the retention max age
+// The names of the removed snapshots are returned
+func PruneSnapshots(database string) ([]string, error) {
+	c := configuration.Current()
+	backupPath, err := BackupPath()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	type snapshotEntry struct {
+		name    string
+		takenAt time.Time
+	}
+	var snapshots []snapshotEntry
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		takenAt, ok := snapshotTime(database, entry.Name())
+		if !ok {
+			continue
+		}
+		snapshots = append(snapshots, snapshotEntry{name: entry.Name(), takenAt: takenAt})
+	}
+	// Newest first so that the retention count keeps the most recent snapshots
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].takenAt.After(snapshots[j].takenAt)
+	})
+
+	var pruned []string
+	for i, snapshot := range snapshots {
+		expired := globals.BackupRetentionMaxAge > 0 && time.Since(snapshot.takenAt) > globals.BackupRetentionMaxAge
+		beyondCount := c.Storage.Backups.Retention.Count > 0 && i >= c.Storage.Backups.Retention.Count
+		if !expired && !beyondCount {
+			continue
+		}
+		err = os.Remove(filepath.Join(backupPath, snapshot.name))
+		if err != nil {
+			log.Error("Failed to prune snapshot (" + snapshot.name + "): " + err.Error())
+			continue
+		}
+		log.Debug("Pruned snapshot: " + snapshot.name)
+		pruned = append(pruned, snapshot.name)
+	}
+	return pruned, nil
+}
+
+// RestoreDatabase replaces the database with a snapshot from the backups directory
+// The snapshot must pass an integrity check, must not be newer than the version of the database definition, and must have been written with the same encryption setting and key
+// Snapshots of an older version are migrated once restored - The replaced database is kept in the backups directory as a pre-restore snapshot
+func RestoreDatabase(database, snapshot, userID string) error {
+	provisioningMutex.Lock()
+	defer provisioningMutex.Unlock()
+	c := configuration.Current()
+
+	if !validIdentifier(database) {
+		return errors.New(globals.ErrorInvalidDatabaseName)
+	}
+	if snapshot == "" || filepath.Base(snapshot) != snapshot || strings.HasPrefix(snapshot, ".") {
+		return errors.New(globals.ErrorSnapshotInvalid + ": snapshot must be the name of a file in the backups directory")
+	}
+	definition, err := databaseDefinition(database)
+	if err != nil {
+		return err
+	}
+	backupPath, err := BackupPath()
+	if err != nil {
+		return err
+	}
+	snapshotPath := filepath.Join(backupPath, snapshot)
+	if _, err := os.Stat(snapshotPath); err != nil {
+		return errors.New(globals.ErrorNotExist + ": snapshot (" + snapshot + ")")
+	}
+
+	// The snapshot is validated on a copy within the storage path so that the rename into place is atomic
+	dbFilePath := c.Storage.Path + "/" + database + ".db"
+	restoreFilePath := dbFilePath + globals.BackupRestoreFileSuffix
+	defer os.Remove(restoreFilePath)
+	if strings.HasSuffix(snapshot, globals.BackupGzipExtension) {
+		err = decompressFile(snapshotPath, restoreFilePath)
+	} else {
+		err = copyFile(snapshotPath, restoreFilePath)
+	}
+	if err != nil {
+		return err
+	}
+	snapshotVersion, err := validateSnapshot(restoreFilePath, definition)
+	if err != nil {
+		log.Warn("Snapshot (" + snapshot + ") cannot be restored to database (" + database + "): " + err.Error())
+		return err
+	}
+
+	preRestorePath := filepath.Join(backupPath, database+globals.BackupPreRestoreSuffix+"-"+time.Now().UTC().Format(globals.BackupTimestampFormat)+globals.BackupFileExtension)
+	err = BackupDatabase(database, preRestorePath, userID)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Restoring database (" + database + ") from snapshot: " + snapshot)
+	err = replaceDatabaseFile(dbFilePath, restoreFilePath)
+	if err != nil {
+		return err
+	}
+
+	if snapshotVersion < definition.Version {
+		log.Info("Restored database " + database + "@v" + fmt.Sprint(snapshotVersion) + " is behind the configured version (v" + fmt.Sprint(definition.Version) + ") - Running migrations")
+	}
+	err = migrateRestoredDatabase(definition, snapshotVersion)
+	if err != nil {
+		log.Error("Failed to migrate restored database (" + database + ") - Putting back the pre-restore snapshot: " + err.Error())
+		rollbackErr := copyFile(preRestorePath, restoreFilePath)
+		if rollbackErr == nil {
+			rollbackErr = replaceDatabaseFile(dbFilePath, restoreFilePath)
+		}
+		if rollbackErr != nil {
+			log.Error("Failed to put back the pre-restore snapshot (" + preRestorePath + ") of database (" + database + "): " + rollbackErr.Error())
+		}
+		return err
+	}
+
+	err = createTransaction(database, userID, globals.RestoreTransactionAction, globals.MetadataTable, "", filepath.Base(preRestorePath), snapshot, "", "SUCCESS", nil)
+	if err != nil {
+		log.Error("Error when logging restore: " + err.Error())
+	}
+	log.Info("Restored database (" + database + ") from snapshot: " + snapshot)
+	return nil
+}
+
+// DatabaseNames returns the names of the configured and provisioned databases
+func DatabaseNames() ([]string, error) {
+	c := configuration.Current()
+	provisioned, err := provisionedDatabases()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, definition := range append(append([]configuration.ConfigurationDatabaseEntry{}, c.Databases...), provisioned...) {
+		names = append(names, definition.Name)
+	}
+	return names, nil
+}
+
+// databaseDefinition returns the definition of a configured or provisioned database
+func databaseDefinition(database string) (configuration.ConfigurationDatabaseEntry, error) {
+	c := configuration.Current()
+	provisioned, err := provisionedDatabases()
+	if err != nil {
+		return configuration.ConfigurationDatabaseEntry{}, err
+	}
+	for _, definition := range append(append([]configuration.ConfigurationDatabaseEntry{}, c.Databases...), provisioned...) {
+		if definition.Name == database {
+			return definition, nil
+		}
+	}
+	return configuration.ConfigurationDatabaseEntry{}, errors.New(globals.ErrorNotExist)
+}
+
+// validateSnapshot checks the integrity, version, and encryption of a snapshot and returns its version
+func validateSnapshot(filePath string, definition configuration.ConfigurationDatabaseEntry) (int, error) {
+	c := configuration.Current()
+	wrapper, err := NewSQLiteWrapper(filePath)
+	if err != nil {
+		return 0, errors.New(globals.ErrorSnapshotInvalid + ": " + err.Error())
+	}
+	defer wrapper.Close()
+
+	var integrity string
+	err = wrapper.db.QueryRow("PRAGMA quick_check").Scan(&integrity)
+	if err != nil {
+		return 0, errors.New(globals.ErrorSnapshotInvalid + ": " + err.Error())
+	}
+	if integrity != "ok" {
+		return 0, errors.New(globals.ErrorSnapshotInvalid + ": integrity check failed (" + integrity + ")")
+	}
+
+	// The version is read without processing so that the encryption of the snapshot can be compared with the server
+	var storedVersion string
+	err = wrapper.db.QueryRow("SELECT version FROM " + globals.MetadataTable).Scan(&storedVersion)
+	if err != nil {
+		return 0, errors.New(globals.ErrorSnapshotInvalid + ": failed to read the database version: " + err.Error())
+	}
+	encrypted := data.IsEncrypted(storedVersion)
+	if encrypted != c.Storage.Encryption.Enabled {
+		return 0, errors.New(globals.ErrorSnapshotEncryption + ": snapshot encryption (" + fmt.Sprint(encrypted) + ") does not match the server (" + fmt.Sprint(c.Storage.Encryption.Enabled) + ")")
+	}
+	if encrypted {
+		storedVersion, err = data.DecryptStored(storedVersion)
+		if err != nil {
+			return 0, errors.New(globals.ErrorSnapshotEncryption + ": " + err.Error())
+		}
+	}
+	version, err := strconv.Atoi(storedVersion)
+	if err != nil {
+		if encrypted {
+			// Decrypting with a different key does not fail but produces an unreadable version
+			return 0, errors.New(globals.ErrorSnapshotEncryption + ": snapshot was not encrypted with the current encryption key")
+		}
+		return 0, errors.New(globals.ErrorSnapshotInvalid + ": invalid database version (" + storedVersion + ")")
+	}
+	if version > definition.Version {
+		return 0, errors.New(globals.ErrorSnapshotVersion + ": snapshot v" + fmt.Sprint(version) + " is newer than the configured version (v" + fmt.Sprint(definition.Version) + ")")
+	}
+	return version, nil
+}
+
+// migrateRestoredDatabase upgrades the system tables of a restored database and applies the migrations of the definition if it is behind
+// Snapshots written by an older version of the server have their system tables upgraded first (See: upgradeSystemTables)
+func migrateRestoredDatabase(definition configuration.ConfigurationDatabaseEntry, currentVersion int) error {
+	wrapper, err := sharedWrapper(definition.Name)
+	if err != nil {
+		return err
+	}
+	err = upgradeSystemTables(wrapper)
+	if err != nil || currentVersion >= definition.Version {
+		return err
+	}
+	return runMigrations(wrapper, definition, currentVersion)
+}
+
+// replaceDatabaseFile moves the file into place as the database file after removing the WAL and shared memory files of the replaced database
+// The shared wrapper of the replaced database is closed first so that the database is opened again from the new file
+func replaceDatabaseFile(dbFilePath, filePath string) error {
+	err := CloseDatabase(strings.TrimSuffix(filepath.Base(dbFilePath), filepath.Ext(dbFilePath)))
+	if err != nil {
+		return err
+	}
+	for _, staleFilePath := range []string{dbFilePath + "-wal", dbFilePath + "-shm"} {
+		err := os.Remove(staleFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(filePath, dbFilePath)
+}
+
+// compressFile writes a gzip compressed copy of the source file to the destination
+func compressFile(source, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	gzipWriter := gzip.NewWriter(destinationFile)
+	_, err = io.Copy(gzipWriter, sourceFile)
+	if err == nil {
+		err = gzipWriter.Close()
+	}
+	if err != nil {
+		destinationFile.Close()
+		os.Remove(destination)
+		return err
+	}
+	return destinationFile.Close()
+}
+
+// decompressFile writes a decompressed copy of the gzip compressed source file to the destination
+func decompressFile(source, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	gzipReader, err := gzip.NewReader(sourceFile)
+	if err != nil {
+		return errors.New(globals.ErrorSnapshotInvalid + ": " + err.Error())
+	}
+	defer gzipReader.Close()
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destinationFile, gzipReader)
+	if err != nil {
+		destinationFile.Close()
+		return errors.New(globals.ErrorSnapshotInvalid + ": " + err.Error())
+	}
+	return destinationFile.Close()
+}
+
+// copyFile copies the source file to the destination, replacing the destination if it exists
+func copyFile(source, destination string) error {
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	destinationFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(destinationFile, sourceFile)
+	if err != nil {
+		destinationFile.Close()
+		return err
+	}
+	return destinationFile.Close()
+}
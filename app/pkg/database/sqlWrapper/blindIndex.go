@@ -0,0 +1,199 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// queryer is implemented by both database connections and SQL transactions
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// BlindIndexedColumns returns the searchable columns of the table (The columns which have a blind index column)
+func (wrapper *SQLiteWrapper) BlindIndexedColumns(table string) (map[string]bool, error) {
+	return blindIndexedColumns(wrapper.reader, table)
+}
+
+// BlindIndexedColumns returns the searchable columns of the table as seen by the transaction
+func (transaction *Transaction) BlindIndexedColumns(table string) (map[string]bool, error) {
+	if transaction.done {
+		return nil, sql.ErrTxDone
+	}
+	return blindIndexedColumns(transaction.tx, table)
+}
+
+// tableColumns returns the names of every column of the table, including system columns
+func tableColumns(q queryer, table string) ([]string, error) {
+	if !validIdentifier(table) {
+		return nil, errors.New(globals.ErrorInvalidTableName)
+	}
+	rows, err := q.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		err = rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// blindIndexedColumns returns the columns of the table which have a blind index column
+func blindIndexedColumns(q queryer, table string) (map[string]bool, error) {
+	columns, err := tableColumns(q, table)
+	if err != nil {
+		return nil, err
+	}
+	indexed := make(map[string]bool)
+	for _, column := range columns {
+		if strings.HasPrefix(column, globals.BlindIndexColumnPrefix) {
+			indexed[strings.TrimPrefix(column, globals.BlindIndexColumnPrefix)] = true
+		}
+	}
+	return indexed, nil
+}
+
+// blindIndexStepQueries returns the queries which keep the blind index columns in step with a migration step once it is applied
+// Blind indexes which are cleared (Ex: by a backfill - See: migrationStepQuery) are rebuilt on startup
+func blindIndexStepQueries(q queryer, step configuration.ConfigurationDatabaseEntryMigrationStep) ([]string, error) {
+	var after []string
+	switch step.Action {
+	case globals.MigrationActionAddColumn:
+		if step.Column.Searchable {
+			after = append(after, `ALTER TABLE `+step.Table+` ADD COLUMN `+globals.BlindIndexColumnPrefix+step.Column.Name+` TEXT`)
+		}
+	case globals.MigrationActionAddBlindIndex:
+		columns, err := tableColumns(q, step.Table)
+		if err != nil {
+			return nil, err
+		}
+		var exists bool
+		for _, column := range columns {
+			if column == step.Column.Name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			return nil, errors.New(globals.ErrorInvalidColumnName + ": " + step.Column.Name + " does not exist in table (" + step.Table + ")")
+		}
+	case globals.MigrationActionDropColumn, globals.MigrationActionRenameColumn:
+		indexed, err := blindIndexedColumns(q, step.Table)
+		if err != nil {
+			return nil, err
+		}
+		switch step.Action {
+		case globals.MigrationActionDropColumn:
+			if indexed[step.Column.Name] {
+				after = append(after, `ALTER TABLE `+step.Table+` DROP COLUMN `+globals.BlindIndexColumnPrefix+step.Column.Name)
+			}
+		case globals.MigrationActionRenameColumn:
+			if indexed[step.Column.Name] {
+				after = append(after, `ALTER TABLE `+step.Table+` RENAME COLUMN `+globals.BlindIndexColumnPrefix+step.Column.Name+` TO `+globals.BlindIndexColumnPrefix+step.NewName)
+			}
+		}
+	}
+	return after, nil
+}
+
+// reindexBlindIndexes builds the missing blind indexes of every user table in the database
+func reindexBlindIndexes(wrapper *SQLiteWrapper) error {
+	tables, err := wrapper.ListTables()
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		err = reindexTableBlindIndexes(wrapper, table)
+		if err != nil {
+			return errors.New("failed to build blind indexes of table (" + table + "): " + err.Error())
+		}
+	}
+	return nil
+}
+
+// reindexTableBlindIndexes builds the blind indexes of the rows which have a value but no blind index
+// Blind indexes are only kept while encryption is enabled
+func reindexTableBlindIndexes(wrapper *SQLiteWrapper, table string) error {
+	c := configuration.Current()
+	if !c.Storage.Encryption.Enabled {
+		return nil
+	}
+	indexed, err := blindIndexedColumns(wrapper.reader, table)
+	if err != nil {
+		return err
+	}
+	for column := range indexed {
+		blindIndexColumn := globals.BlindIndexColumnPrefix + column
+		var reindexed int
+		for {
+			rows, err := wrapper.reader.Query(`SELECT rowid, `+column+` FROM `+table+` WHERE `+blindIndexColumn+` IS NULL AND `+column+` IS NOT NULL LIMIT ?`, globals.BlindIndexReindexBatchSize)
+			if err != nil {
+				return err
+			}
+			blindIndexes := make(map[int64]string)
+			for rows.Next() {
+				var rowID int64
+				var stored interface{}
+				err = rows.Scan(&rowID, &stored)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				if storedBytes, ok := stored.([]byte); ok {
+					stored = string(storedBytes)
+				}
+				value := data.Process(stored)
+				if value == globals.ErrorDataProcessing {
+					rows.Close()
+					return errors.New(globals.ErrorDataProcessing + ": failed to decrypt column (" + column + ")")
+				}
+				blindIndexes[rowID] = string(data.BlindIndex(value))
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return err
+			}
+			if len(blindIndexes) == 0 {
+				break
+			}
+
+			transaction, err := wrapper.Begin(globals.SystemUserID)
+			if err != nil {
+				return err
+			}
+			tx := transaction.tx
+			for rowID, blindIndex := range blindIndexes {
+				_, err = tx.Exec(`UPDATE `+table+` SET `+blindIndexColumn+` = ? WHERE rowid = ?`, blindIndex, rowID)
+				if err != nil {
+					transaction.Rollback(err)
+					return err
+				}
+			}
+			err = transaction.Commit()
+			if err != nil {
+				return err
+			}
+			reindexed += len(blindIndexes)
+		}
+		if reindexed > 0 {
+			log.Info("Built " + fmt.Sprint(reindexed) + " blind index(es) of column: " + wrapper.name + "." + table + "." + column)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// ColumnTypes returns the declared type of each column of the table
+func (wrapper *SQLiteWrapper) ColumnTypes(table string) (map[string]string, error) {
+	return columnTypes(wrapper.reader, table)
+}
+
+// ColumnTypes returns the declared type of each column of the table as seen by the transaction
+func (transaction *Transaction) ColumnTypes(table string) (map[string]string, error) {
+	if transaction.done {
+		return nil, sql.ErrTxDone
+	}
+	return columnTypes(transaction.tx, table)
+}
+
+// columnTypes returns the declared type of each column of the table, including system columns
+func columnTypes(q queryer, table string) (map[string]string, error) {
+	if !validIdentifier(table) {
+		return nil, errors.New(globals.ErrorInvalidTableName)
+	}
+	rows, err := q.Query(`SELECT name, type FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types := make(map[string]string)
+	for rows.Next() {
+		var column, columnType string
+		err = rows.Scan(&column, &columnType)
+		if err != nil {
+			return nil, err
+		}
+		types[column] = columnType
+	}
+	return types, rows.Err()
+}
@@ -0,0 +1,200 @@
+package sqlWrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+)
+
+// columnConstraints validates the constraints of a column and returns them for use in its definition
+// While encryption is enabled, values of encrypted columns are ciphertext which cannot be compared by SQLite
+// so defaults, checks, and references require an unencrypted column and uniqueness is enforced on the blind index of a searchable column
+func columnConstraints(table string, column configuration.ConfigurationDatabaseEntryColumnsEntry) (string, error) {
+	c := configuration.Current()
+	encrypted := column.IsEncrypted() && c.Storage.Encryption.Enabled
+	var constraints string
+	if !column.IsNullable() {
+		constraints += " NOT NULL"
+	}
+	if column.Unique {
+		if encrypted && !column.Searchable {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": " + column.Name + " must be searchable or unencrypted to be unique")
+		}
+		if !encrypted {
+			constraints += " UNIQUE"
+		}
+	}
+	if column.Default != nil {
+		if encrypted {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": " + column.Name + " must be unencrypted to have a default")
+		}
+		literal, err := defaultLiteral(column)
+		if err != nil {
+			return "", err
+		}
+		constraints += " DEFAULT " + literal
+	}
+	if column.Check != "" {
+		if encrypted {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": " + column.Name + " must be unencrypted to have a check")
+		}
+		if !validExpression(column.Check) {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": check of " + column.Name + " must be a single expression without semicolons or comments")
+		}
+		constraints += " CHECK (" + column.Check + ")"
+	}
+	if column.References != nil {
+		if encrypted {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": " + column.Name + " must be unencrypted to reference another column")
+		}
+		reference := column.References
+		if !validIdentifier(reference.Table) || strings.HasPrefix(reference.Table, globals.SystemTablePrefix) || !validIdentifier(reference.Column) {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": " + column.Name + " references an invalid column (" + reference.Table + "." + reference.Column + ")")
+		}
+		constraints += " REFERENCES " + reference.Table + " (" + reference.Column + ")"
+		if reference.OnDelete != "" {
+			onDelete := strings.ToUpper(strings.TrimSpace(reference.OnDelete))
+			var valid bool
+			for _, action := range globals.ColumnReferenceOnDeleteActions {
+				if onDelete == action {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return "", errors.New(globals.ErrorInvalidConstraint + ": onDelete (" + reference.OnDelete + ") of " + column.Name + " is invalid - Valid actions are: " + strings.Join(globals.ColumnReferenceOnDeleteActions, ", "))
+			}
+			constraints += " ON DELETE " + onDelete
+		}
+	}
+	return constraints, nil
+}
+
+// defaultLiteral returns the SQL literal of the default of a column
+// The default is validated against the type of the column like the values which are written to it
+func defaultLiteral(column configuration.ConfigurationDatabaseEntryColumnsEntry) (string, error) {
+	value := column.Default
+	if keyword, ok := value.(string); ok {
+		for _, defaultKeyword := range globals.ColumnDefaultKeywords {
+			if strings.ToUpper(keyword) == defaultKeyword {
+				return defaultKeyword, nil
+			}
+		}
+	}
+	// Numbers read from the configuration file are integers while request bodies only have float64
+	switch number := value.(type) {
+	case int:
+		value = float64(number)
+	case int64:
+		value = float64(number)
+	}
+	value, ok := data.ValidateType(column.Type, value)
+	if !ok {
+		return "", errors.New(globals.ErrorInvalidConstraint + ": default of " + column.Name + " does not match its type (" + column.Type + ")")
+	}
+	switch v := value.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		valueJSON, err := json.Marshal(v)
+		if err != nil {
+			return "", errors.New(globals.ErrorInvalidConstraint + ": default of " + column.Name + " is invalid: " + err.Error())
+		}
+		return "'" + strings.ReplaceAll(string(valueJSON), "'", "''") + "'", nil
+	}
+}
+
+// validExpression returns true if the expression is a single expression which cannot close the clause it is placed in (Ex: CHECK, WHERE)
+func validExpression(expression string) bool {
+	if strings.Contains(expression, ";") || strings.Contains(expression, "--") || strings.Contains(expression, "/*") {
+		return false
+	}
+	var depth int
+	var inString bool
+	for _, character := range expression {
+		switch {
+		case character == '\'':
+			inString = !inString
+		case inString:
+		case character == '(':
+			depth++
+		case character == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0 && !inString
+}
+
+// checkReferences ensures that the columns which are referenced by the columns of the table are unencrypted while encryption is enabled
+// A reference to an encrypted column would compare ciphertext which changes when the encryption key is rotated
+func checkReferences(q queryer, table string, columns []configuration.ConfigurationDatabaseEntryColumnsEntry) error {
+	c := configuration.Current()
+	if !c.Storage.Encryption.Enabled {
+		return nil
+	}
+	for _, column := range columns {
+		if column.References == nil {
+			continue
+		}
+		reference := column.References
+		var plaintext map[string]bool
+		if reference.Table == table {
+			plaintext = make(map[string]bool)
+			for _, tableColumn := range columns {
+				plaintext[tableColumn.Name] = !tableColumn.IsEncrypted()
+			}
+		} else {
+			var err error
+			plaintext, err = plaintextColumns(q, reference.Table)
+			if err != nil {
+				return err
+			}
+		}
+		if !plaintext[reference.Column] {
+			return errors.New(globals.ErrorInvalidConstraint + ": " + column.Name + " references an encrypted column (" + reference.Table + "." + reference.Column + ") - Referenced columns must be unencrypted")
+		}
+	}
+	return nil
+}
+
+// constraintError prefixes the error of a statement which violated a constraint with the matching error message
+// Blind index columns are reported as the column they index
+func constraintError(err error) error {
+	var sqliteErr sqlite3.Error
+	if err == nil || !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	var message string
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		message = globals.ErrorConstraintUnique
+	case sqlite3.ErrConstraintNotNull:
+		message = globals.ErrorConstraintNotNull
+	case sqlite3.ErrConstraintCheck:
+		message = globals.ErrorConstraintCheck
+	case sqlite3.ErrConstraintForeignKey:
+		message = globals.ErrorConstraintForeignKey
+	default:
+		return err
+	}
+	return fmt.Errorf("%s: %s", message, strings.ReplaceAll(err.Error(), globals.BlindIndexColumnPrefix, ""))
+}
@@ -1,9 +1,11 @@
 package sqlWrapper
 
 import (
+	"errors"
 	"regexp"
 	"strings"
 
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	"github.com/mitchs-dev/simplQL/pkg/database/data"
 
@@ -46,6 +48,22 @@ func extractTableName(query string) string {
 			log.Debug("Returning table name: ", words[2])
 			return words[2]
 		}
+	case "ALTER":
+		// For ALTER TABLE, the table name is the third word
+		if len(words) > 2 && strings.ToUpper(words[1]) == "TABLE" {
+			log.Debug("Returning table name: ", words[2])
+			return words[2]
+		}
+	case "DROP":
+		// For DROP TABLE, the table name is the third word
+		if len(words) > 2 && strings.ToUpper(words[1]) == "TABLE" {
+			if len(words) > 4 && strings.ToUpper(words[2]) == "IF" && strings.ToUpper(words[3]) == "EXISTS" {
+				log.Debug("Returning table name: ", words[4])
+				return words[4] // Adjust index to account for "IF EXISTS"
+			}
+			log.Debug("Returning table name: ", words[2])
+			return words[2]
+		}
 	default:
 		// Return an empty string if the table name could not be determined
 		log.Error("Could not determine table name from query: ", query)
@@ -59,7 +77,7 @@ func extractRecordID(query string, args []interface{}) string {
 	log.Debug("Raw query: ", query)
 	log.Debug("Raw args: ", args)
 	// Define the regular expression pattern to search for sys_eid
-	pattern := `(?i)` + globals.TableEntryIDColumnName + `\s*=\s*['"]?([^'"\s]+)['"]?`
+	pattern := `(?i)` + globals.TableEntryIDColumnName + `\s*=\s*['"]?([^'"\s?]+)['"]?`
 	re := regexp.MustCompile(pattern)
 
 	// Find the first match
@@ -72,7 +90,8 @@ func extractRecordID(query string, args []interface{}) string {
 			if argStr, ok := arg.(string); ok {
 				if strings.HasPrefix(argStr, globals.EncryptionOriginalFormatHeaderStart) && strings.Contains(argStr, globals.EncryptionOriginalFormatHeaderEnd) {
 					log.Debug("Found encrypted arg: ", argStr)
-					if !strings.Contains(argStr, globals.EncryptionOriginalFormatHeaderStart+"string"+globals.EncryptionOriginalFormatHeaderEnd) {
+					// The original format may be followed by the ID of the key which encrypted it
+					if !strings.HasPrefix(argStr, globals.EncryptionOriginalFormatHeaderStart+"string"+globals.EncryptionOriginalFormatHeaderEnd) && !strings.HasPrefix(argStr, globals.EncryptionOriginalFormatHeaderStart+"string"+globals.EncryptionKeyIDSeparator) {
 						log.Error("Encrypted arg is not of type string")
 						return ""
 					}
@@ -126,3 +145,85 @@ func fetchSetColumns(query string) string {
 	// Join the old value columns with commas
 	return strings.Join(oldValueColumns, ",")
 }
+
+// identifierPattern matches the table, column, and index names which are accepted by the schema helpers
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validIdentifier returns true if the name can safely be used as a table, column, or index name
+func validIdentifier(name string) bool {
+	return identifierPattern.MatchString(name)
+}
+
+// columnDefinition validates a column and returns its definition for use in CREATE/ALTER TABLE queries
+func columnDefinition(table string, column configuration.ConfigurationDatabaseEntryColumnsEntry) (string, error) {
+	if !validIdentifier(column.Name) {
+		log.Error("Invalid column name: " + column.Name + " - Column names may only contain letters, numbers, and underscores")
+		return "", errors.New(globals.ErrorInvalidColumnName)
+	}
+	if strings.HasPrefix(column.Name, globals.SystemColumnPrefix) {
+		log.Error("Invalid column name: " + column.Name + " - Column names cannot be prefixed with '" + globals.SystemColumnPrefix + "' as this is reserved for system columns")
+		return "", errors.New(globals.ErrorInvalidColumnName)
+	}
+	var columnTypeIsValid bool
+	for _, columnType := range globals.ColumnTypes {
+		if column.Type == columnType {
+			columnTypeIsValid = true
+			break
+		}
+	}
+	if !columnTypeIsValid {
+		log.Error("Invalid column type: " + column.Type + " for column: " + column.Name + " in table: " + table)
+		log.Info("Valid column types are: " + strings.Join(globals.ColumnTypes, ", "))
+		return "", errors.New(globals.ErrorInvalidColumnType)
+	}
+	if column.Searchable && !column.IsEncrypted() {
+		log.Error("Invalid column: " + column.Name + " in table: " + table + " - Only encrypted columns can be searchable as unencrypted columns are compared as is")
+		return "", errors.New(globals.ErrorInvalidColumnName + ": " + column.Name + " cannot be searchable as it is not encrypted")
+	}
+	definition := column.Name + " " + column.Type
+	if column.PrimaryKey {
+		definition += " PRIMARY KEY"
+	}
+	constraints, err := columnConstraints(table, column)
+	if err != nil {
+		log.Error("Invalid constraint of column: " + column.Name + " in table: " + table + " - " + err.Error())
+		return "", err
+	}
+	return definition + constraints, nil
+}
+
+// createTableQuery validates a table and returns the query used to create it
+func createTableQuery(table configuration.ConfigurationDatabaseEntryTablesEntry) (string, error) {
+	c := configuration.Current()
+	if !validIdentifier(table.Name) {
+		log.Error("Invalid table name: " + table.Name + " - Table names may only contain letters, numbers, and underscores")
+		return "", errors.New(globals.ErrorInvalidTableName)
+	}
+	if len(table.Columns) == 0 {
+		log.Error("Invalid table: " + table.Name + " - At least one column is required")
+		return "", errors.New(globals.ErrorInvalidColumnName)
+	}
+	if strings.HasPrefix(table.Name, globals.SystemTablePrefix) {
+		log.Error("Invalid table name: " + table.Name + " - Table names cannot be prefixed with '" + globals.SystemTablePrefix + "' as this is reserved for system tables")
+		return "", errors.New(globals.ErrorInvalidTableName)
+	}
+	columns := make([]string, 0)
+	for _, column := range table.Columns {
+		definition, err := columnDefinition(table.Name, column)
+		if err != nil {
+			return "", err
+		}
+		columns = append(columns, definition)
+		if column.Searchable {
+			blindIndexColumn := globals.BlindIndexColumnPrefix + column.Name + " TEXT"
+			// Uniqueness of an encrypted column is enforced on its blind index
+			if column.Unique && column.IsEncrypted() && c.Storage.Encryption.Enabled {
+				blindIndexColumn += " UNIQUE"
+			}
+			columns = append(columns, blindIndexColumn)
+		}
+	}
+	// Insert entry ID column
+	columns = append(columns, globals.TableEntryIDColumnName+" TEXT")
+	return `CREATE TABLE IF NOT EXISTS ` + table.Name + ` (` + strings.Join(columns, ", ") + `)`, nil
+}
@@ -0,0 +1,270 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TableIndex is a struct that holds the description of a table index
+type TableIndex struct {
+	Name    string   `json:"name" yaml:"name"`
+	Columns []string `json:"columns" yaml:"columns"`
+	Unique  bool     `json:"unique" yaml:"unique"`
+	Where   string   `json:"where,omitempty" yaml:"where,omitempty"`
+}
+
+// indexQuery validates an index and returns the query which creates it
+// Searchable columns are indexed by their blind index since it is what equality filters compare while encryption is enabled
+func indexQuery(table string, index configuration.ConfigurationDatabaseEntryIndexesEntry, blindIndexed map[string]bool) (string, error) {
+	if !validIdentifier(index.Name) || strings.HasPrefix(index.Name, globals.SystemTablePrefix) || strings.HasPrefix(strings.ToLower(index.Name), "sqlite_") {
+		return "", errors.New(globals.ErrorInvalidIndex + ": index name (" + index.Name + ") is invalid")
+	}
+	if len(index.Columns) == 0 {
+		return "", errors.New(globals.ErrorInvalidIndex + ": " + index.Name + " must have at least one column")
+	}
+	columns := make([]string, 0, len(index.Columns))
+	for _, column := range index.Columns {
+		if !validIdentifier(column) || strings.HasPrefix(column, globals.SystemColumnPrefix) {
+			return "", errors.New(globals.ErrorInvalidIndex + ": " + index.Name + " has an invalid column (" + column + ")")
+		}
+		if blindIndexed[column] {
+			column = globals.BlindIndexColumnPrefix + column
+		}
+		columns = append(columns, column)
+	}
+	query := `CREATE INDEX IF NOT EXISTS `
+	if index.Unique {
+		query = `CREATE UNIQUE INDEX IF NOT EXISTS `
+	}
+	query += index.Name + ` ON ` + table + ` (` + strings.Join(columns, ", ") + `)`
+	if index.Where != "" {
+		if !validExpression(index.Where) {
+			return "", errors.New(globals.ErrorInvalidIndex + ": where of " + index.Name + " must be a single expression without semicolons or comments")
+		}
+		query += ` WHERE ` + index.Where
+	}
+	return query, nil
+}
+
+// validateIndexes validates the indexes of a table which is yet to be created
+func validateIndexes(table configuration.ConfigurationDatabaseEntryTablesEntry) error {
+	columns := make(map[string]bool)
+	for _, column := range table.Columns {
+		columns[column.Name] = true
+	}
+	names := make(map[string]bool)
+	for _, index := range table.Indexes {
+		_, err := indexQuery(table.Name, index, nil)
+		if err != nil {
+			return err
+		}
+		if names[index.Name] {
+			return errors.New(globals.ErrorInvalidIndex + ": " + index.Name + " is defined more than once")
+		}
+		names[index.Name] = true
+		for _, column := range index.Columns {
+			if !columns[column] {
+				return errors.New(globals.ErrorInvalidIndex + ": " + index.Name + " indexes a column which does not exist (" + column + ")")
+			}
+		}
+	}
+	return nil
+}
+
+// recordedIndexes returns the definitions of the indexes of the table which were created from its configuration
+// Databases without configured indexes do not have the table
+func recordedIndexes(q queryer, table string) (map[string]string, error) {
+	indexes := make(map[string]string)
+	rows, err := q.Query(`SELECT indexName, definition FROM `+globals.IndexesTable+` WHERE tableName = ?`, table)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: "+globals.IndexesTable) {
+			return indexes, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, definition string
+		err = rows.Scan(&name, &definition)
+		if err != nil {
+			return nil, err
+		}
+		indexes[name] = definition
+	}
+	return indexes, rows.Err()
+}
+
+// reconcileIndexes brings the indexes of the tables in line with their configuration in a single transaction
+// Indexes which are missing or whose definition changed are (re)created and indexes which were removed from the configuration are dropped
+// Indexes which were not created from the configuration (Ex: by the addIndex migration step) are left as is
+func reconcileIndexes(wrapper *SQLiteWrapper, tables []configuration.ConfigurationDatabaseEntryTablesEntry) error {
+	transaction, err := wrapper.Begin(globals.SystemUserID)
+	if err != nil {
+		return err
+	}
+	tx := transaction.tx
+	for _, table := range tables {
+		err = reconcileTableIndexes(tx, wrapper.name, table)
+		if err != nil {
+			transaction.Rollback(err)
+			return errors.New("failed to reconcile indexes of table (" + table.Name + "): " + err.Error())
+		}
+	}
+	return transaction.Commit()
+}
+
+// reconcileTableIndexes brings the indexes of a single table in line with its configuration
+func reconcileTableIndexes(tx statements, database string, table configuration.ConfigurationDatabaseEntryTablesEntry) error {
+	c := configuration.Current()
+	recorded, err := recordedIndexes(tx, table.Name)
+	if err != nil {
+		return err
+	}
+	if len(recorded) == 0 && len(table.Indexes) == 0 {
+		return nil
+	}
+	columns, err := tableColumns(tx, table.Name)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		// The table was renamed or dropped by a migration - Its indexes are dropped along with it
+		log.Warn("Skipping indexes of table which does not exist: " + database + "." + table.Name)
+		return nil
+	}
+	blindIndexed := make(map[string]bool)
+	if c.Storage.Encryption.Enabled {
+		blindIndexed, err = blindIndexedColumns(tx, table.Name)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS ` + globals.IndexesTable + ` (indexName TEXT PRIMARY KEY, tableName TEXT NOT NULL, definition TEXT NOT NULL)`)
+	if err != nil {
+		return err
+	}
+
+	configured := make(map[string]bool)
+	for _, index := range table.Indexes {
+		if configured[index.Name] {
+			return errors.New(globals.ErrorInvalidIndex + ": " + index.Name + " is defined more than once")
+		}
+		configured[index.Name] = true
+		query, err := indexQuery(table.Name, index, blindIndexed)
+		if err != nil {
+			return err
+		}
+		var indexTable string
+		err = tx.QueryRow(`SELECT tbl_name FROM sqlite_master WHERE type = 'index' AND name = ?`, index.Name).Scan(&indexTable)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if indexTable != "" && indexTable != table.Name {
+			return errors.New(globals.ErrorInvalidIndex + ": " + index.Name + " is already an index of table (" + indexTable + ")")
+		}
+		if indexTable != "" && recorded[index.Name] == query {
+			continue
+		}
+		_, err = tx.Exec(`DROP INDEX IF EXISTS ` + index.Name)
+		if err != nil {
+			return err
+		}
+		log.Debug("Create index query: " + query)
+		_, err = tx.Exec(query)
+		if err != nil {
+			return constraintError(err)
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO `+globals.IndexesTable+` (indexName, tableName, definition) VALUES (?, ?, ?)`, index.Name, table.Name, query)
+		if err != nil {
+			return err
+		}
+		log.Info("Created index: " + database + "." + table.Name + "." + index.Name)
+	}
+	for name := range recorded {
+		if configured[name] {
+			continue
+		}
+		_, err = tx.Exec(`DROP INDEX IF EXISTS ` + name)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM `+globals.IndexesTable+` WHERE indexName = ?`, name)
+		if err != nil {
+			return err
+		}
+		log.Info("Dropped index: " + database + "." + table.Name + "." + name)
+	}
+	return nil
+}
+
+// dropRecordedIndexes removes the recorded indexes of a dropped table
+func dropRecordedIndexes(e execer, table string) error {
+	_, err := e.Exec(`DELETE FROM `+globals.IndexesTable+` WHERE tableName = ?`, table)
+	if err != nil && strings.Contains(err.Error(), "no such table: "+globals.IndexesTable) {
+		return nil
+	}
+	return err
+}
+
+// describeIndexes returns the indexes of a table which were created with CREATE INDEX
+// Indexes which SQLite creates for primary keys and unique columns are part of the column description instead
+func describeIndexes(q queryer, table string) ([]TableIndex, error) {
+	indexes := make([]TableIndex, 0)
+	rows, err := q.Query(`SELECT il.name, il."unique", il.partial, m.sql FROM pragma_index_list(?) AS il JOIN sqlite_master AS m ON m.type = 'index' AND m.name = il.name WHERE il.origin = 'c' ORDER BY il.name`, table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var (
+			index   TableIndex
+			partial bool
+			query   string
+		)
+		err = rows.Scan(&index.Name, &index.Unique, &partial, &query)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if partial {
+			// The column list of an index only holds identifiers so the first closing parenthesis ends it
+			if end := strings.Index(query, ")"); end >= 0 {
+				index.Where = strings.TrimPrefix(strings.TrimSpace(query[end+1:]), "WHERE ")
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range indexes {
+		rows, err := q.Query(`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, indexes[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i].Columns = make([]string, 0)
+		for rows.Next() {
+			var column sql.NullString
+			err = rows.Scan(&column)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			indexes[i].Columns = append(indexes[i].Columns, strings.TrimPrefix(column.String, globals.BlindIndexColumnPrefix))
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
@@ -0,0 +1,307 @@
+package sqlWrapper
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/database/filter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runMigrations applies the configured migrations which are newer than the current database version
+// Each version is applied in its own transaction so that a failed step leaves the database at the last good version
+// New databases are created at the configured version and never run migrations (Data-only steps such as backfill included)
+func runMigrations(wrapper *SQLiteWrapper, database configuration.ConfigurationDatabaseEntry, currentVersion int) error {
+	migrations := make([]configuration.ConfigurationDatabaseEntryMigrationsEntry, 0)
+	for _, migration := range database.Migrations {
+		if migration.Version > currentVersion && migration.Version <= database.Version {
+			migrations = append(migrations, migration)
+		}
+	}
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	if len(migrations) == 0 || migrations[len(migrations)-1].Version != database.Version {
+		log.Error("No migration path from v" + fmt.Sprint(currentVersion) + " to v" + fmt.Sprint(database.Version) + " for database: " + database.Name + " - Ensure a migration exists for the configured version")
+		return errors.New(globals.ErrorMigration)
+	}
+
+	for _, migration := range migrations {
+		log.Info("Migrating database " + database.Name + " from v" + fmt.Sprint(currentVersion) + " to v" + fmt.Sprint(migration.Version))
+		err := applyMigration(wrapper, migration)
+		oldValues := fmt.Sprint(currentVersion)
+		newValues := fmt.Sprint(migration.Version)
+		if err != nil {
+			log.Error("Migration of database " + database.Name + " to v" + fmt.Sprint(migration.Version) + " failed and was rolled back: " + err.Error())
+			txErr := createTransaction(database.Name, globals.SystemUserID, globals.MigrationTransactionAction+"(ROLLBACK)", globals.MetadataTable, "", oldValues, newValues, "", "ERROR", err)
+			if txErr != nil {
+				log.Error("Error when logging failed migration: " + txErr.Error())
+			}
+			return errors.New(globals.ErrorMigration)
+		}
+		err = createTransaction(database.Name, globals.SystemUserID, globals.MigrationTransactionAction, globals.MetadataTable, "", oldValues, newValues, "", "SUCCESS", nil)
+		if err != nil {
+			log.Error("Error when logging migration: " + err.Error())
+			return err
+		}
+		log.Info("Database " + database.Name + " migrated to v" + fmt.Sprint(migration.Version))
+		currentVersion = migration.Version
+	}
+	return nil
+}
+
+// applyMigration runs all steps of a migration and bumps the stored version in a single transaction
+func applyMigration(wrapper *SQLiteWrapper, migration configuration.ConfigurationDatabaseEntryMigrationsEntry) error {
+	transaction, err := wrapper.Begin(globals.SystemUserID)
+	if err != nil {
+		return err
+	}
+	tx := transaction.tx
+
+	for i, step := range migration.Steps {
+		plaintext, err := plaintextColumns(tx, step.Table)
+		if err != nil {
+			transaction.Rollback(err)
+			return fmt.Errorf("step %d (%s): %s", i+1, step.Action, err.Error())
+		}
+		blindIndexed, err := blindIndexedColumns(tx, step.Table)
+		if err != nil {
+			transaction.Rollback(err)
+			return fmt.Errorf("step %d (%s): %s", i+1, step.Action, err.Error())
+		}
+		query, args, err := migrationStepQuery(step, plaintext, blindIndexed)
+		if err != nil {
+			transaction.Rollback(err)
+			return fmt.Errorf("step %d (%s): %s", i+1, step.Action, err.Error())
+		}
+		err = execMigrationStep(tx, step, query, args)
+		if err != nil {
+			transaction.Rollback(err)
+			return fmt.Errorf("step %d (%s): %s", i+1, step.Action, err.Error())
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE `+globals.MetadataTable+` SET version = ?`, data.Process(fmt.Sprint(migration.Version)))
+	if err != nil {
+		transaction.Rollback(err)
+		return err
+	}
+	return transaction.Commit()
+}
+
+// execMigrationStep runs the query of a migration step along with the queries which keep its blind index columns, unencrypted columns and policy in step
+func execMigrationStep(tx statements, step configuration.ConfigurationDatabaseEntryMigrationStep, query string, args []interface{}) error {
+	after, err := blindIndexStepQueries(tx, step)
+	if err != nil {
+		return err
+	}
+	plaintextQueries, err := plaintextStepQueries(tx, step)
+	if err != nil {
+		return err
+	}
+	policyQueries, err := policyStepQueries(tx, step)
+	if err != nil {
+		return err
+	}
+	switch step.Action {
+	case globals.MigrationActionAddTable:
+		err = checkReferences(tx, step.Table, step.Columns)
+	case globals.MigrationActionAddColumn:
+		err = checkReferences(tx, step.Table, []configuration.ConfigurationDatabaseEntryColumnsEntry{step.Column})
+	}
+	if err != nil {
+		return err
+	}
+	after = append(after, plaintextQueries...)
+	after = append(after, policyQueries...)
+	log.Debug("Migration step query: " + query + " with args: " + fmt.Sprintf("%v", args))
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	for _, systemQuery := range after {
+		log.Debug("Migration step system query: " + systemQuery)
+		_, err = tx.Exec(systemQuery)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationStepQuery returns the query and arguments for a single migration step
+// Backfilled values of the unencrypted columns of the table are stored as is and their blind indexes are cleared to be rebuilt on startup
+func migrationStepQuery(step configuration.ConfigurationDatabaseEntryMigrationStep, plaintext, blindIndexed map[string]bool) (string, []interface{}, error) {
+	c := configuration.Current()
+	if step.Action != globals.MigrationActionAddTable && strings.HasPrefix(step.Table, globals.SystemTablePrefix) {
+		return "", nil, errors.New(globals.ErrorInvalidTableName)
+	}
+	if !validIdentifier(step.Table) {
+		return "", nil, errors.New(globals.ErrorInvalidTableName)
+	}
+	switch step.Action {
+	case globals.MigrationActionAddColumn:
+		// SQLite can only add columns which existing rows can be given a value for
+		if step.Column.Unique || step.Column.PrimaryKey {
+			return "", nil, errors.New(globals.ErrorInvalidConstraint + ": " + step.Column.Name + " cannot be added as unique - Add a unique index with " + globals.MigrationActionAddIndex + " instead")
+		}
+		if !step.Column.IsNullable() && step.Column.Default == nil {
+			return "", nil, errors.New(globals.ErrorInvalidConstraint + ": " + step.Column.Name + " cannot be added as not nullable without a default")
+		}
+		if step.Column.References != nil && step.Column.Default != nil {
+			return "", nil, errors.New(globals.ErrorInvalidConstraint + ": " + step.Column.Name + " cannot be added with both a reference and a default")
+		}
+		definition, err := columnDefinition(step.Table, step.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		return `ALTER TABLE ` + step.Table + ` ADD COLUMN ` + definition, nil, nil
+	case globals.MigrationActionDropColumn:
+		if !validIdentifier(step.Column.Name) || strings.HasPrefix(step.Column.Name, globals.SystemColumnPrefix) {
+			return "", nil, errors.New(globals.ErrorInvalidColumnName)
+		}
+		return `ALTER TABLE ` + step.Table + ` DROP COLUMN ` + step.Column.Name, nil, nil
+	case globals.MigrationActionRenameColumn:
+		if !validIdentifier(step.Column.Name) || strings.HasPrefix(step.Column.Name, globals.SystemColumnPrefix) {
+			return "", nil, errors.New(globals.ErrorInvalidColumnName)
+		}
+		if !validIdentifier(step.NewName) || strings.HasPrefix(step.NewName, globals.SystemColumnPrefix) {
+			return "", nil, errors.New(globals.ErrorInvalidColumnName)
+		}
+		return `ALTER TABLE ` + step.Table + ` RENAME COLUMN ` + step.Column.Name + ` TO ` + step.NewName, nil, nil
+	case globals.MigrationActionAddTable:
+		query, err := createTableQuery(configuration.ConfigurationDatabaseEntryTablesEntry{Name: step.Table, Columns: step.Columns})
+		if err != nil {
+			return "", nil, err
+		}
+		return query, nil, nil
+	case globals.MigrationActionAddIndex:
+		query, err := indexQuery(step.Table, step.Index, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		return query, nil, nil
+	case globals.MigrationActionAddBlindIndex:
+		if !validIdentifier(step.Column.Name) || strings.HasPrefix(step.Column.Name, globals.SystemColumnPrefix) {
+			return "", nil, errors.New(globals.ErrorInvalidColumnName)
+		}
+		return `ALTER TABLE ` + step.Table + ` ADD COLUMN ` + globals.BlindIndexColumnPrefix + step.Column.Name + ` TEXT`, nil, nil
+	case globals.MigrationActionBackfill:
+		columns, err := backfillColumns(step)
+		if err != nil {
+			return "", nil, err
+		}
+		assignments := make([]string, 0, len(columns))
+		args := make([]interface{}, 0, len(columns))
+		for _, column := range columns {
+			assignments = append(assignments, column+" = ?")
+			if plaintext[column] && c.Storage.Encryption.Enabled {
+				args = append(args, data.Process(data.Plaintext(step.Set[column])))
+				continue
+			}
+			args = append(args, data.Process(fmt.Sprint(step.Set[column])))
+		}
+		for _, column := range columns {
+			if blindIndexed[column] {
+				assignments = append(assignments, globals.BlindIndexColumnPrefix+column+" = NULL")
+			}
+		}
+		// The filter compares against the stored values of the columns as the filters of db requests do
+		var storage *filter.Storage
+		if c.Storage.Encryption.Enabled {
+			storage = &filter.Storage{
+				BlindIndexed: blindIndexed,
+				BlindIndex: func(value interface{}) interface{} {
+					return data.BlindIndex(value)
+				},
+				Plaintext: plaintext,
+				PlaintextValue: func(value interface{}) interface{} {
+					return data.Plaintext(value)
+				},
+			}
+		}
+		whereClause, whereArgs, err := filter.WhereStored(step.Where, storage)
+		if err != nil {
+			return "", nil, err
+		}
+		whereClause, whereArgs = prepareQuery(whereClause, whereArgs)
+		return `UPDATE ` + step.Table + ` SET ` + strings.Join(assignments, ", ") + whereClause, append(args, whereArgs...), nil
+	default:
+		return "", nil, errors.New(globals.ErrorInvalidMigrationStep + ": unknown action (" + step.Action + ")")
+	}
+}
+
+// backfillColumns validates the columns which a backfill step sets and returns them in order
+func backfillColumns(step configuration.ConfigurationDatabaseEntryMigrationStep) ([]string, error) {
+	if len(step.Set) == 0 {
+		return nil, errors.New(globals.ErrorInvalidMigrationStep + ": set is required")
+	}
+	columns := make([]string, 0, len(step.Set))
+	for column := range step.Set {
+		if !validIdentifier(column) || strings.HasPrefix(column, globals.SystemColumnPrefix) {
+			return nil, errors.New(globals.ErrorInvalidColumnName)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+// validateMigrations validates the backfill steps of the migrations of the database before it is created or migrated
+// New databases never run migrations so a step which is invalid would otherwise only fail once an older database is migrated
+// A backfill without a where filter gives every existing row a value which a new database only gives its rows through the column default
+// So the column must default to the backfilled value if the configured table still declares it
+func validateMigrations(database configuration.ConfigurationDatabaseEntry) error {
+	for _, migration := range database.Migrations {
+		for i, step := range migration.Steps {
+			if step.Action != globals.MigrationActionBackfill {
+				continue
+			}
+			invalid := func(err error) error {
+				return fmt.Errorf("migration v%d step %d (%s): %s", migration.Version, i+1, step.Action, err.Error())
+			}
+			columns, err := backfillColumns(step)
+			if err != nil {
+				return invalid(err)
+			}
+			_, _, err = filter.Where(step.Where)
+			if err != nil {
+				return invalid(err)
+			}
+			if step.Where != nil {
+				continue
+			}
+			for _, column := range columns {
+				declared, exists := declaredColumn(database.Tables, step.Table, column)
+				if !exists {
+					continue
+				}
+				if declared.Default == nil || fmt.Sprint(declared.Default) != fmt.Sprint(step.Set[column]) {
+					return invalid(errors.New(globals.ErrorInvalidMigrationStep + ": " + step.Table + "." + column + " is backfilled without a where filter so it must default to the backfilled value (" + fmt.Sprint(step.Set[column]) + ") for new databases to match - Declare the default on the column or limit the step with a where filter"))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// declaredColumn returns the column of the table as it is declared in the configuration
+func declaredColumn(tables []configuration.ConfigurationDatabaseEntryTablesEntry, table, column string) (configuration.ConfigurationDatabaseEntryColumnsEntry, bool) {
+	for _, declaredTable := range tables {
+		if declaredTable.Name != table {
+			continue
+		}
+		for _, declared := range declaredTable.Columns {
+			if declared.Name == column {
+				return declared, true
+			}
+		}
+	}
+	return configuration.ConfigurationDatabaseEntryColumnsEntry{}, false
+}
@@ -0,0 +1,105 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// execer is implemented by both database connections and SQL transactions
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// PlaintextColumns returns the columns of the table which are stored unencrypted
+func (wrapper *SQLiteWrapper) PlaintextColumns(table string) (map[string]bool, error) {
+	return plaintextColumns(wrapper.reader, table)
+}
+
+// PlaintextColumns returns the columns of the table which are stored unencrypted as seen by the transaction
+func (transaction *Transaction) PlaintextColumns(table string) (map[string]bool, error) {
+	if transaction.done {
+		return nil, sql.ErrTxDone
+	}
+	return plaintextColumns(transaction.tx, table)
+}
+
+// plaintextColumns returns the columns of the table which are recorded as unencrypted
+// The names are stored as is since they are part of the schema - Databases without unencrypted columns do not have the table
+func plaintextColumns(q queryer, table string) (map[string]bool, error) {
+	columns := make(map[string]bool)
+	rows, err := q.Query(`SELECT columnName FROM `+globals.PlaintextColumnsTable+` WHERE tableName = ?`, table)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: "+globals.PlaintextColumnsTable) {
+			return columns, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column string
+		err = rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
+		columns[column] = true
+	}
+	return columns, rows.Err()
+}
+
+// plaintextColumnQueries returns the queries which record the unencrypted columns of a table
+// Nil is returned when every column is encrypted
+func plaintextColumnQueries(table string, columns []configuration.ConfigurationDatabaseEntryColumnsEntry) []string {
+	var queries []string
+	for _, column := range columns {
+		if column.IsEncrypted() {
+			continue
+		}
+		if queries == nil {
+			queries = append(queries, `CREATE TABLE IF NOT EXISTS `+globals.PlaintextColumnsTable+` (tableName TEXT NOT NULL, columnName TEXT NOT NULL, PRIMARY KEY (tableName, columnName))`)
+		}
+		// Table and column names are validated identifiers
+		queries = append(queries, `INSERT OR REPLACE INTO `+globals.PlaintextColumnsTable+` (tableName, columnName) VALUES ('`+table+`', '`+column.Name+`')`)
+	}
+	return queries
+}
+
+// plaintextStepQueries returns the queries which keep the recorded unencrypted columns in step with a migration step
+// They run once the step is applied
+func plaintextStepQueries(q queryer, step configuration.ConfigurationDatabaseEntryMigrationStep) ([]string, error) {
+	switch step.Action {
+	case globals.MigrationActionAddTable:
+		return plaintextColumnQueries(step.Table, step.Columns), nil
+	case globals.MigrationActionAddColumn:
+		return plaintextColumnQueries(step.Table, []configuration.ConfigurationDatabaseEntryColumnsEntry{step.Column}), nil
+	case globals.MigrationActionDropColumn, globals.MigrationActionRenameColumn, globals.MigrationActionAddBlindIndex:
+		plaintext, err := plaintextColumns(q, step.Table)
+		if err != nil {
+			return nil, err
+		}
+		if !plaintext[step.Column.Name] {
+			return nil, nil
+		}
+		switch step.Action {
+		case globals.MigrationActionDropColumn:
+			return []string{`DELETE FROM ` + globals.PlaintextColumnsTable + ` WHERE tableName = '` + step.Table + `' AND columnName = '` + step.Column.Name + `'`}, nil
+		case globals.MigrationActionRenameColumn:
+			return []string{`UPDATE ` + globals.PlaintextColumnsTable + ` SET columnName = '` + step.NewName + `' WHERE tableName = '` + step.Table + `' AND columnName = '` + step.Column.Name + `'`}, nil
+		default:
+			return nil, errors.New(globals.ErrorInvalidColumnName + ": " + step.Column.Name + " cannot have a blind index as it is not encrypted")
+		}
+	}
+	return nil, nil
+}
+
+// dropPlaintextColumns removes the recorded unencrypted columns of a dropped table
+func dropPlaintextColumns(e execer, table string) error {
+	_, err := e.Exec(`DELETE FROM `+globals.PlaintextColumnsTable+` WHERE tableName = ?`, table)
+	if err != nil && strings.Contains(err.Error(), "no such table: "+globals.PlaintextColumnsTable) {
+		return nil
+	}
+	return err
+}
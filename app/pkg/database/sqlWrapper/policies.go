@@ -0,0 +1,189 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// policyPlaceholderPattern matches the placeholders of a policy predicate (Ex: {{user.id}})
+var policyPlaceholderPattern = regexp.MustCompile(`\{\{\s*user\.([A-Za-z_]+)\s*\}\}`)
+
+// Policy returns the row-level security policy of the table - Nil is returned when the table does not have one
+func (wrapper *SQLiteWrapper) Policy(table string) (*configuration.ConfigurationDatabaseEntryPolicy, error) {
+	return tablePolicy(wrapper.reader, table)
+}
+
+// Policy returns the row-level security policy of the table as seen by the transaction
+func (transaction *Transaction) Policy(table string) (*configuration.ConfigurationDatabaseEntryPolicy, error) {
+	if transaction.done {
+		return nil, sql.ErrTxDone
+	}
+	return tablePolicy(transaction.tx, table)
+}
+
+// PolicyPredicate replaces the placeholders of the predicate with parameters and returns the attributes of the user in their order
+func PolicyPredicate(predicate string, attributes map[string]interface{}) (string, []interface{}) {
+	var args []interface{}
+	condition := policyPlaceholderPattern.ReplaceAllStringFunc(predicate, func(placeholder string) string {
+		attribute := policyPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		args = append(args, attributes[attribute])
+		return "?"
+	})
+	return condition, args
+}
+
+// tablePolicy reads the recorded policy of the table - Databases without policies do not have the table
+func tablePolicy(q queryer, table string) (*configuration.ConfigurationDatabaseEntryPolicy, error) {
+	rows, err := q.Query(`SELECT ownerColumn, attribute, predicate FROM `+globals.PoliciesTable+` WHERE tableName = ?`, table)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table: "+globals.PoliciesTable) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var policy configuration.ConfigurationDatabaseEntryPolicy
+	err = rows.Scan(&policy.OwnerColumn, &policy.Attribute, &policy.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// validatePolicy validates the policy of a table which has the columns
+// Either the owner column (Matched against the id or name of the user) or a predicate is required
+func validatePolicy(table string, policy *configuration.ConfigurationDatabaseEntryPolicy, columns []string) error {
+	if policy == nil {
+		return nil
+	}
+	switch {
+	case policy.OwnerColumn != "" && policy.Predicate != "":
+		return errors.New(globals.ErrorInvalidPolicy + ": policy of " + table + " can not have both an owner column and a predicate")
+	case policy.OwnerColumn != "":
+		found := false
+		for _, column := range columns {
+			if column == policy.OwnerColumn {
+				found = true
+				break
+			}
+		}
+		if !found || strings.HasPrefix(policy.OwnerColumn, globals.SystemColumnPrefix) {
+			return errors.New(globals.ErrorInvalidPolicy + ": owner column (" + policy.OwnerColumn + ") of " + table + " is not a column of the table")
+		}
+		if policy.Attribute != "" && !validPolicyAttribute(policy.Attribute) {
+			return errors.New(globals.ErrorInvalidPolicy + ": attribute (" + policy.Attribute + ") of " + table + " must be one of: " + strings.Join(globals.PolicyAttributes, ", "))
+		}
+	case policy.Predicate != "":
+		if policy.Attribute != "" {
+			return errors.New(globals.ErrorInvalidPolicy + ": attribute of " + table + " is only used with an owner column")
+		}
+		if !validExpression(policy.Predicate) {
+			return errors.New(globals.ErrorInvalidPolicy + ": predicate of " + table + " must be a single expression without semicolons or comments")
+		}
+		for _, match := range policyPlaceholderPattern.FindAllStringSubmatch(policy.Predicate, -1) {
+			if !validPolicyAttribute(match[1]) {
+				return errors.New(globals.ErrorInvalidPolicy + ": predicate of " + table + " uses an unknown attribute (" + match[1] + ") - Valid attributes are: " + strings.Join(globals.PolicyAttributes, ", "))
+			}
+		}
+		if strings.Contains(policyPlaceholderPattern.ReplaceAllString(policy.Predicate, ""), "{{") {
+			return errors.New(globals.ErrorInvalidPolicy + ": predicate of " + table + " has a placeholder which is not in the {{user.<attribute>}} format")
+		}
+	default:
+		return errors.New(globals.ErrorInvalidPolicy + ": policy of " + table + " requires an owner column or a predicate")
+	}
+	return nil
+}
+
+// validPolicyAttribute checks if the attribute of the user can be used by a policy
+func validPolicyAttribute(attribute string) bool {
+	for _, valid := range globals.PolicyAttributes {
+		if attribute == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcilePolicies records the policies of the tables in a single transaction
+// The recorded policy of a table is removed once its policy is removed from the configuration
+func reconcilePolicies(wrapper *SQLiteWrapper, tables []configuration.ConfigurationDatabaseEntryTablesEntry) error {
+	transaction, err := wrapper.Begin(globals.SystemUserID)
+	if err != nil {
+		return err
+	}
+	tx := transaction.tx
+	for _, table := range tables {
+		err = reconcileTablePolicy(tx, wrapper.name, table)
+		if err != nil {
+			transaction.Rollback(err)
+			return err
+		}
+	}
+	return transaction.Commit()
+}
+
+// reconcileTablePolicy brings the recorded policy of a single table in line with its configuration
+func reconcileTablePolicy(tx statements, database string, table configuration.ConfigurationDatabaseEntryTablesEntry) error {
+	if table.Policy == nil {
+		return dropRecordedPolicy(tx, table.Name)
+	}
+	columns, err := tableColumns(tx, table.Name)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		// The table was renamed or dropped by a migration
+		log.Warn("Skipping policy of table which does not exist: " + database + "." + table.Name)
+		return nil
+	}
+	err = validatePolicy(table.Name, table.Policy, columns)
+	if err != nil {
+		return err
+	}
+	attribute := table.Policy.Attribute
+	if table.Policy.OwnerColumn != "" && attribute == "" {
+		attribute = globals.PolicyAttributeID
+	}
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS ` + globals.PoliciesTable + ` (tableName TEXT PRIMARY KEY, ownerColumn TEXT NOT NULL, attribute TEXT NOT NULL, predicate TEXT NOT NULL)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO `+globals.PoliciesTable+` (tableName, ownerColumn, attribute, predicate) VALUES (?, ?, ?, ?)`, table.Name, table.Policy.OwnerColumn, attribute, table.Policy.Predicate)
+	return err
+}
+
+// policyStepQueries returns the queries which keep the owner column of a recorded policy in step with a migration step
+// The owner column can be renamed but not dropped while the policy uses it
+func policyStepQueries(q queryer, step configuration.ConfigurationDatabaseEntryMigrationStep) ([]string, error) {
+	if step.Action != globals.MigrationActionDropColumn && step.Action != globals.MigrationActionRenameColumn {
+		return nil, nil
+	}
+	policy, err := tablePolicy(q, step.Table)
+	if err != nil || policy == nil || policy.OwnerColumn != step.Column.Name {
+		return nil, err
+	}
+	if step.Action == globals.MigrationActionDropColumn {
+		return nil, errors.New(globals.ErrorInvalidPolicy + ": " + step.Column.Name + " cannot be dropped as it is the owner column of the policy of " + step.Table)
+	}
+	// Table and column names are validated identifiers
+	return []string{`UPDATE ` + globals.PoliciesTable + ` SET ownerColumn = '` + step.NewName + `' WHERE tableName = '` + step.Table + `'`}, nil
+}
+
+// dropRecordedPolicy removes the recorded policy of a table
+func dropRecordedPolicy(e execer, table string) error {
+	_, err := e.Exec(`DELETE FROM `+globals.PoliciesTable+` WHERE tableName = ?`, table)
+	if err != nil && strings.Contains(err.Error(), "no such table: "+globals.PoliciesTable) {
+		return nil
+	}
+	return err
+}
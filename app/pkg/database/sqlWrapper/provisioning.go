@@ -0,0 +1,171 @@
+package sqlWrapper
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// provisioningMutex serializes changes to the provisioned databases file
+var provisioningMutex sync.Mutex
+
+// DatabaseExists returns true if the database file exists in the storage path
+func DatabaseExists(database string) bool {
+	c := configuration.Current()
+	return processor.DirectoryOrFileExists(c.Storage.Path + "/" + database + ".db")
+}
+
+// ProvisionDatabase creates a new database at runtime and persists its definition so it is initialized on startup
+// The name of the seeded admin is returned along with the password if it was generated
+func ProvisionDatabase(database configuration.ConfigurationDatabaseEntry, adminName, adminPassword string) (string, string, error) {
+	provisioningMutex.Lock()
+	defer provisioningMutex.Unlock()
+	c := configuration.Current()
+
+	if !validIdentifier(database.Name) || strings.HasPrefix(database.Name, globals.SystemTablePrefix) {
+		return "", "", errors.New(globals.ErrorInvalidDatabaseName)
+	}
+	if database.Version == 0 {
+		database.Version = 1
+	}
+	// Validate the tables prior to creating any files
+	for _, table := range database.Tables {
+		_, err := createTableQuery(table)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	err := validateMigrations(database)
+	if err != nil {
+		return "", "", err
+	}
+	if DatabaseExists(database.Name) {
+		return "", "", errors.New(globals.ErrorDatabaseExists)
+	}
+	provisioned, err := provisionedDatabases()
+	if err != nil {
+		return "", "", err
+	}
+	for _, existing := range append(append([]configuration.ConfigurationDatabaseEntry{}, c.Databases...), provisioned...) {
+		if existing.Name == database.Name {
+			return "", "", errors.New(globals.ErrorDatabaseExists)
+		}
+	}
+
+	log.Info("Provisioning database: " + database.Name)
+	adminName, adminPassword, err = initializeDatabase(database, adminName, adminPassword)
+	if err != nil {
+		removeDatabaseFiles(database.Name)
+		return "", "", err
+	}
+
+	provisioned = append(provisioned, database)
+	err = writeProvisionedDatabases(provisioned)
+	if err != nil {
+		log.Error("Error when persisting provisioned database (" + database.Name + "): " + err.Error())
+		removeDatabaseFiles(database.Name)
+		return "", "", err
+	}
+	log.Info("Provisioned database: " + database.Name)
+	return adminName, adminPassword, nil
+}
+
+// DeprovisionDatabase removes a database which was provisioned at runtime
+func DeprovisionDatabase(database string) error {
+	provisioningMutex.Lock()
+	defer provisioningMutex.Unlock()
+	c := configuration.Current()
+
+	if !validIdentifier(database) {
+		return errors.New(globals.ErrorInvalidDatabaseName)
+	}
+	for _, configured := range c.Databases {
+		if configured.Name == database {
+			return errors.New(globals.ErrorDatabaseConfigured)
+		}
+	}
+	provisioned, err := provisionedDatabases()
+	if err != nil {
+		return err
+	}
+	remaining := make([]configuration.ConfigurationDatabaseEntry, 0, len(provisioned))
+	var found bool
+	for _, entry := range provisioned {
+		if entry.Name == database {
+			found = true
+			continue
+		}
+		remaining = append(remaining, entry)
+	}
+	if !found && !DatabaseExists(database) {
+		return errors.New(globals.ErrorNotExist)
+	}
+
+	log.Info("Deprovisioning database: " + database)
+	err = writeProvisionedDatabases(remaining)
+	if err != nil {
+		return err
+	}
+	removeDatabaseFiles(database)
+	log.Info("Deprovisioned database: " + database)
+	return nil
+}
+
+// provisionedDatabases returns the definitions of the databases which were provisioned at runtime
+func provisionedDatabases() ([]configuration.ConfigurationDatabaseEntry, error) {
+	c := configuration.Current()
+	databases := make([]configuration.ConfigurationDatabaseEntry, 0)
+	filePath := c.Storage.Path + "/" + globals.ProvisionedDatabasesFile
+	if !processor.DirectoryOrFileExists(filePath) {
+		return databases, nil
+	}
+	fileData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(fileData, &databases)
+	if err != nil {
+		log.Error("Error when reading provisioned databases file (" + filePath + "): " + err.Error())
+		return nil, err
+	}
+	return databases, nil
+}
+
+// writeProvisionedDatabases atomically replaces the provisioned databases file
+func writeProvisionedDatabases(databases []configuration.ConfigurationDatabaseEntry) error {
+	c := configuration.Current()
+	filePath := c.Storage.Path + "/" + globals.ProvisionedDatabasesFile
+	fileData, err := json.MarshalIndent(databases, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filePath+".tmp", fileData, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(filePath+".tmp", filePath)
+}
+
+// removeDatabaseFiles removes the database file along with its WAL and shared memory files
+func removeDatabaseFiles(database string) {
+	c := configuration.Current()
+	err := CloseDatabase(database)
+	if err != nil {
+		log.Error("Error when closing database (" + database + "): " + err.Error())
+	}
+	dbFilePath := c.Storage.Path + "/" + database + ".db"
+	for _, filePath := range []string{dbFilePath, dbFilePath + "-wal", dbFilePath + "-shm"} {
+		err = os.Remove(filePath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Error("Error when removing database file (" + filePath + "): " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package sqlWrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	sharedWrappers     = map[string]*SQLiteWrapper{}
+	sharedWrappersLock sync.Mutex
+)
+
+// Open returns the shared wrapper of an existing database and holds it until it is released (See: Release)
+// Each database is opened once (On startup or on first use) and its connections are shared by every request
+// A database which is being closed (Ex: to be restored or deleted) can not be opened until the close is done
+func Open(database string) (*SQLiteWrapper, error) {
+	if database == "" || !DatabaseExists(database) {
+		return nil, errors.New(globals.ErrorNotExist)
+	}
+	sharedWrappersLock.Lock()
+	defer sharedWrappersLock.Unlock()
+	wrapper, err := lockedSharedWrapper(database)
+	if err != nil {
+		return nil, err
+	}
+	if wrapper.closing {
+		return nil, errors.New(globals.ErrorDatabaseBusy + ": database (" + database + ") is being closed")
+	}
+	wrapper.users.Add(1)
+	return wrapper, nil
+}
+
+// Release gives back a wrapper which was returned by Open so that the database can be closed once every request is done with it
+func (wrapper *SQLiteWrapper) Release() {
+	wrapper.users.Done()
+}
+
+// sharedWrapper returns the shared wrapper of the database and opens it if it is not open yet
+// It does not hold the wrapper so it is only used by code which runs on startup or on behalf of a request which holds it (Ex: Recording a transaction)
+// Opening a database which does not exist creates its file
+func sharedWrapper(database string) (*SQLiteWrapper, error) {
+	sharedWrappersLock.Lock()
+	defer sharedWrappersLock.Unlock()
+	return lockedSharedWrapper(database)
+}
+
+// lockedSharedWrapper returns the shared wrapper of the database while the registry lock is held
+func lockedSharedWrapper(database string) (*SQLiteWrapper, error) {
+	if wrapper, ok := sharedWrappers[database]; ok {
+		return wrapper, nil
+	}
+	c := configuration.Current()
+	wrapper, err := NewSQLiteWrapper(c.Storage.Path + "/" + database + ".db")
+	if err != nil {
+		return nil, err
+	}
+	sharedWrappers[database] = wrapper
+	log.Debug("Opened database: " + database)
+	return wrapper, nil
+}
+
+// CloseDatabase closes the shared wrapper of the database so that its file can be removed or replaced
+// The wrapper can not be opened while it is closing and is only closed once the requests which hold it are done (See: drain)
+// The database is opened again on its next use
+func CloseDatabase(database string) error {
+	sharedWrappersLock.Lock()
+	wrapper, ok := sharedWrappers[database]
+	if !ok {
+		sharedWrappersLock.Unlock()
+		return nil
+	}
+	wrapper.closing = true
+	sharedWrappersLock.Unlock()
+
+	wrapper.drain()
+	sharedWrappersLock.Lock()
+	delete(sharedWrappers, database)
+	sharedWrappersLock.Unlock()
+	log.Debug("Closing database: " + database)
+	return wrapper.Close()
+}
+
+// drain waits for the requests which hold the wrapper to release it
+// It waits for as long as a lock held by another process would be (See: storage.connections.busyTimeout) so that a request which never releases the wrapper does not block closing
+func (wrapper *SQLiteWrapper) drain() {
+	drained := make(chan struct{})
+	go func() {
+		wrapper.users.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(globals.DatabaseBusyTimeout):
+		log.Warn("Timed out waiting for the requests of database (" + wrapper.name + ") to finish - Closing it anyway")
+	}
+}
+
+// CloseDatabases checkpoints the WAL of every database into its file and closes the shared wrappers - It is called on shutdown
+// The wrappers are closed once the requests which hold them are done (See: drain)
+func CloseDatabases() {
+	sharedWrappersLock.Lock()
+	wrappers := make(map[string]*SQLiteWrapper, len(sharedWrappers))
+	for database, wrapper := range sharedWrappers {
+		wrapper.closing = true
+		wrappers[database] = wrapper
+	}
+	sharedWrappersLock.Unlock()
+
+	for database, wrapper := range wrappers {
+		wrapper.drain()
+		// The writer connection is waited for up to the busy timeout in case a transaction is still open
+		ctx, cancel := context.WithTimeout(context.Background(), globals.DatabaseBusyTimeout)
+		var busy, walFrames, checkpointedFrames int
+		err := wrapper.db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &walFrames, &checkpointedFrames)
+		cancel()
+		if err != nil {
+			log.Error("Error when checkpointing database (" + database + "): " + err.Error())
+		} else if busy != 0 {
+			log.Warn("Could not fully checkpoint database (" + database + ") as it is busy - " + fmt.Sprint(checkpointedFrames) + " of " + fmt.Sprint(walFrames) + " frames were checkpointed")
+		} else {
+			log.Debug("Checkpointed database: " + database)
+		}
+		sharedWrappersLock.Lock()
+		delete(sharedWrappers, database)
+		sharedWrappersLock.Unlock()
+		err = wrapper.Close()
+		if err != nil {
+			log.Error("Error when closing database (" + database + "): " + err.Error())
+		}
+	}
+	log.Info("Databases closed")
+}
+
+// deleteDatabaseFile closes the shared wrapper of the database before deleting its file
+func deleteDatabaseFile(dbFilePath string) bool {
+	database := strings.TrimSuffix(filepath.Base(dbFilePath), filepath.Ext(dbFilePath))
+	err := CloseDatabase(database)
+	if err != nil {
+		log.Error("Error when closing database (" + database + "): " + err.Error())
+	}
+	return processor.FileDelete(dbFilePath)
+}
@@ -0,0 +1,309 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+)
+
+// rolesTableQuery creates the roles table which holds the custom roles of the database with their grants
+var rolesTableQuery = `CREATE TABLE IF NOT EXISTS ` + globals.RolesTable + ` (name TEXT PRIMARY KEY, grants TEXT NOT NULL)`
+
+// Role is a struct that holds a custom role of a database along with what it grants on the tables
+// Users are given a custom role by adding it to their roles with the role prefix (Ex: role:<name>)
+type Role struct {
+	Name   string      `json:"name" yaml:"name"`
+	Grants []RoleGrant `json:"grants" yaml:"grants"`
+}
+
+// RoleGrant is a struct that holds the actions a role may run on a table
+// The actions are limited to the listed columns - Every column of the table is granted when none are listed
+type RoleGrant struct {
+	Table   string   `json:"table" yaml:"table"`
+	Actions []string `json:"actions" yaml:"actions"`
+	Columns []string `json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+// Roles returns the custom roles of the database ordered by name
+func (wrapper *SQLiteWrapper) Roles() ([]Role, error) {
+	roles := []Role{}
+	rows, err := wrapper.reader.Query(`SELECT name, grants FROM ` + globals.RolesTable + ` ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// RoleGrants returns the grants of the named custom roles - Names which are not a role of the database are ignored
+func (wrapper *SQLiteWrapper) RoleGrants(names []string) ([]RoleGrant, error) {
+	var grants []RoleGrant
+	for _, name := range names {
+		role, exists, err := readRole(wrapper.reader, name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			grants = append(grants, role.Grants...)
+		}
+	}
+	return grants, nil
+}
+
+// RoleExists checks if the database has a custom role with the name
+func (wrapper *SQLiteWrapper) RoleExists(name string) (bool, error) {
+	_, exists, err := readRole(wrapper.reader, name)
+	return exists, err
+}
+
+// CreateRole creates a custom role without any grants
+func (wrapper *SQLiteWrapper) CreateRole(name, userID string) (Role, error) {
+	if !validIdentifier(name) {
+		return Role{}, errors.New(globals.ErrorInvalidRole + ": role name (" + name + ") may only contain letters, numbers, and underscores")
+	}
+	role := Role{Name: name, Grants: []RoleGrant{}}
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		return Role{}, err
+	}
+	tx := transaction.tx
+	_, exists, err := readRole(tx, name)
+	if err != nil {
+		transaction.Rollback(err)
+		return Role{}, err
+	}
+	if exists {
+		transaction.Rollback(nil)
+		return Role{}, errors.New(globals.ErrorRoleExists + ": " + name)
+	}
+	err = writeRole(tx, role, true)
+	if err != nil {
+		transaction.Rollback(err)
+		return Role{}, err
+	}
+	err = transaction.Commit()
+	if err != nil {
+		return Role{}, err
+	}
+	return role, auditRole(wrapper.name, userID, globals.RoleCreateTransactionAction, nil, role)
+}
+
+// GrantRole gives the role the grant on its table - A previous grant on the same table is replaced
+func (wrapper *SQLiteWrapper) GrantRole(name string, grant RoleGrant, userID string) (Role, error) {
+	return wrapper.updateRole(name, userID, globals.RoleGrantTransactionAction, func(q queryer, role *Role) error {
+		err := validateRoleGrant(q, &grant)
+		if err != nil {
+			return err
+		}
+		grants := []RoleGrant{grant}
+		for _, existing := range role.Grants {
+			if existing.Table != grant.Table {
+				grants = append(grants, existing)
+			}
+		}
+		sort.Slice(grants, func(i, j int) bool { return grants[i].Table < grants[j].Table })
+		role.Grants = grants
+		return nil
+	})
+}
+
+// RevokeRole removes the actions from the grant of the role on the table - The whole grant is removed when no actions are listed
+func (wrapper *SQLiteWrapper) RevokeRole(name, table string, actions []string, userID string) (Role, error) {
+	return wrapper.updateRole(name, userID, globals.RoleRevokeTransactionAction, func(q queryer, role *Role) error {
+		revoked := make(map[string]bool, len(actions))
+		for _, action := range actions {
+			revoked[action] = true
+		}
+		grants := []RoleGrant{}
+		found := false
+		for _, grant := range role.Grants {
+			if grant.Table != table {
+				grants = append(grants, grant)
+				continue
+			}
+			found = true
+			if len(actions) == 0 {
+				continue
+			}
+			var remaining []string
+			for _, action := range grant.Actions {
+				if !revoked[action] {
+					remaining = append(remaining, action)
+				}
+			}
+			if len(remaining) > 0 {
+				grant.Actions = remaining
+				grants = append(grants, grant)
+			}
+		}
+		if !found {
+			return errors.New(globals.ErrorInvalidRole + ": role (" + role.Name + ") does not have a grant on table (" + table + ")")
+		}
+		role.Grants = grants
+		return nil
+	})
+}
+
+// updateRole applies the change to the role in a single transaction and records it in the transaction log
+func (wrapper *SQLiteWrapper) updateRole(name, userID, action string, change func(queryer, *Role) error) (Role, error) {
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		return Role{}, err
+	}
+	tx := transaction.tx
+	previous, exists, err := readRole(tx, name)
+	if err != nil {
+		transaction.Rollback(err)
+		return Role{}, err
+	}
+	if !exists {
+		transaction.Rollback(nil)
+		return Role{}, errors.New(globals.ErrorRoleNotFound + ": " + name)
+	}
+	role := Role{Name: previous.Name, Grants: append([]RoleGrant{}, previous.Grants...)}
+	err = change(tx, &role)
+	if err != nil {
+		transaction.Rollback(err)
+		return Role{}, err
+	}
+	err = writeRole(tx, role, false)
+	if err != nil {
+		transaction.Rollback(err)
+		return Role{}, err
+	}
+	err = transaction.Commit()
+	if err != nil {
+		return Role{}, err
+	}
+	return role, auditRole(wrapper.name, userID, action, &previous, role)
+}
+
+// validateRoleGrant ensures that the table and columns of the grant exist and that its actions are known
+// The actions and columns are sorted and deduplicated so that grants are stored the same way regardless of the request
+func validateRoleGrant(q queryer, grant *RoleGrant) error {
+	if !validIdentifier(grant.Table) || strings.HasPrefix(grant.Table, globals.SystemTablePrefix) {
+		return errors.New(globals.ErrorInvalidRole + ": table (" + grant.Table + ") can not be granted")
+	}
+	if len(grant.Actions) == 0 {
+		return errors.New(globals.ErrorInvalidRole + ": at least one action is required - Valid actions are: " + strings.Join(globals.RoleActions, ", "))
+	}
+	validActions := make(map[string]bool, len(globals.RoleActions))
+	for _, action := range globals.RoleActions {
+		validActions[action] = true
+	}
+	for _, action := range grant.Actions {
+		if !validActions[action] {
+			return errors.New(globals.ErrorInvalidRole + ": unknown action (" + action + ") - Valid actions are: " + strings.Join(globals.RoleActions, ", "))
+		}
+	}
+	columns, err := tableColumns(q, grant.Table)
+	if err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		return errors.New(globals.ErrorInvalidRole + ": table (" + grant.Table + ") does not exist")
+	}
+	tableColumns := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		tableColumns[column] = true
+	}
+	for _, column := range grant.Columns {
+		if strings.HasPrefix(column, globals.SystemColumnPrefix) || strings.HasPrefix(column, globals.BlindIndexColumnPrefix) || !tableColumns[column] {
+			return errors.New(globals.ErrorInvalidRole + ": column (" + column + ") can not be granted on table (" + grant.Table + ")")
+		}
+	}
+	grant.Actions = sortedUnique(grant.Actions)
+	grant.Columns = sortedUnique(grant.Columns)
+	return nil
+}
+
+// sortedUnique returns the values sorted without duplicates
+func sortedUnique(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// readRole reads a custom role by name
+func readRole(q queryer, name string) (Role, bool, error) {
+	rows, err := q.Query(`SELECT name, grants FROM `+globals.RolesTable+` WHERE name = ?`, name)
+	if err != nil {
+		return Role{}, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return Role{}, false, rows.Err()
+	}
+	role, err := scanRole(rows)
+	if err != nil {
+		return Role{}, false, err
+	}
+	return role, true, nil
+}
+
+// scanRole scans the name and grants of a role from the current row
+func scanRole(rows *sql.Rows) (Role, error) {
+	var role Role
+	var grants string
+	err := rows.Scan(&role.Name, &grants)
+	if err != nil {
+		return Role{}, err
+	}
+	err = json.Unmarshal([]byte(grants), &role.Grants)
+	if err != nil {
+		return Role{}, errors.New("failed to parse grants of role (" + role.Name + "): " + err.Error())
+	}
+	return role, nil
+}
+
+// writeRole stores the role as part of the transaction
+func writeRole(tx statements, role Role, create bool) error {
+	grants, err := json.Marshal(role.Grants)
+	if err != nil {
+		return err
+	}
+	if create {
+		_, err = tx.Exec(`INSERT INTO `+globals.RolesTable+` (name, grants) VALUES (?, ?)`, role.Name, string(grants))
+		return err
+	}
+	_, err = tx.Exec(`UPDATE `+globals.RolesTable+` SET grants = ? WHERE name = ?`, string(grants), role.Name)
+	return err
+}
+
+// auditRole records a change to a role in the transaction log
+func auditRole(database, userID, action string, previous *Role, role Role) error {
+	oldValues := ""
+	if previous != nil {
+		encoded, err := json.Marshal(previous)
+		if err != nil {
+			return err
+		}
+		oldValues = string(encoded)
+	}
+	newValues, err := json.Marshal(role)
+	if err != nil {
+		return err
+	}
+	return createTransaction(database, userID, action, globals.RolesTable, "", oldValues, string(newValues), "", "SUCCESS", nil)
+}
@@ -0,0 +1,164 @@
+package sqlWrapper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReencryptDatabase re-encrypts every value of every table in the database which is not encrypted with the active key
+// Rows are read and updated in batches so that each write transaction is short and requests keep being served
+// Values which changed since they were read are skipped as they were written with the active key
+// The number of re-encrypted values is returned
+func ReencryptDatabase(database string, batchSize int) (int, error) {
+	if !DatabaseExists(database) {
+		return 0, errors.New(globals.ErrorNotExist)
+	}
+	wrapper, err := Open(database)
+	if err != nil {
+		return 0, err
+	}
+	defer wrapper.Release()
+
+	rows, err := wrapper.reader.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return 0, err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		err = rows.Scan(&table)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	var reencrypted int
+	for _, table := range tables {
+		count, err := reencryptTable(wrapper, table, batchSize)
+		reencrypted += count
+		if err != nil {
+			return reencrypted, errors.New("failed to re-encrypt table (" + table + "): " + err.Error())
+		}
+		if count > 0 {
+			log.Debug("Re-encrypted " + fmt.Sprint(count) + " value(s) of table: " + database + "." + table)
+		}
+	}
+	return reencrypted, nil
+}
+
+// reencryptTable re-encrypts the values of a single table in batches ordered by rowid
+func reencryptTable(wrapper *SQLiteWrapper, table string, batchSize int) (int, error) {
+	var columns []string
+	columnRows, err := wrapper.reader.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return 0, err
+	}
+	for columnRows.Next() {
+		var column string
+		err = columnRows.Scan(&column)
+		if err != nil {
+			columnRows.Close()
+			return 0, err
+		}
+		columns = append(columns, column)
+	}
+	columnRows.Close()
+	if len(columns) == 0 {
+		return 0, nil
+	}
+
+	selectQuery := `SELECT rowid`
+	for _, column := range columns {
+		selectQuery += `, "` + column + `"`
+	}
+	selectQuery += ` FROM "` + table + `" WHERE rowid > ? ORDER BY rowid LIMIT ?`
+
+	type pendingUpdate struct {
+		rowID  int64
+		column string
+		old    string
+		new    string
+	}
+	var reencrypted int
+	var lastRowID int64
+	for {
+		rows, err := wrapper.reader.Query(selectQuery, lastRowID, batchSize)
+		if err != nil {
+			return reencrypted, err
+		}
+		var updates []pendingUpdate
+		var batchRows int
+		for rows.Next() {
+			var rowID int64
+			values := make([]interface{}, len(columns))
+			scanTargets := []interface{}{&rowID}
+			for i := range values {
+				scanTargets = append(scanTargets, &values[i])
+			}
+			err = rows.Scan(scanTargets...)
+			if err != nil {
+				rows.Close()
+				return reencrypted, err
+			}
+			batchRows++
+			lastRowID = rowID
+			for i, value := range values {
+				var stored string
+				switch typedValue := value.(type) {
+				case string:
+					stored = typedValue
+				case []byte:
+					stored = string(typedValue)
+				default:
+					continue
+				}
+				newValue, changed, err := data.Reencrypt(stored)
+				if err != nil {
+					rows.Close()
+					return reencrypted, err
+				}
+				if changed {
+					updates = append(updates, pendingUpdate{rowID: rowID, column: columns[i], old: stored, new: newValue})
+				}
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return reencrypted, err
+		}
+
+		if len(updates) > 0 {
+			transaction, err := wrapper.Begin(globals.SystemUserID)
+			if err != nil {
+				return reencrypted, err
+			}
+			tx := transaction.tx
+			for _, update := range updates {
+				// The old value is compared so that values written by requests since they were read are not overwritten
+				result, err := tx.Exec(`UPDATE "`+table+`" SET "`+update.column+`" = ? WHERE rowid = ? AND "`+update.column+`" = ?`, update.new, update.rowID, update.old)
+				if err != nil {
+					transaction.Rollback(err)
+					return reencrypted, err
+				}
+				affected, _ := result.RowsAffected()
+				reencrypted += int(affected)
+			}
+			err = transaction.Commit()
+			if err != nil {
+				return reencrypted, err
+			}
+		}
+		if batchRows < batchSize {
+			return reencrypted, nil
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TableColumn is a struct that holds the description of a table column
+type TableColumn struct {
+	Name       string `json:"name" yaml:"name"`
+	Type       string `json:"type" yaml:"type"`
+	PrimaryKey bool   `json:"primaryKey" yaml:"primaryKey"`
+	Searchable bool   `json:"searchable" yaml:"searchable"`
+	Encrypted  bool   `json:"encrypted" yaml:"encrypted"`
+	Nullable   bool   `json:"nullable" yaml:"nullable"`
+	Default    string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// TableDescription is a struct that holds the description of a table
+type TableDescription struct {
+	Name    string                                          `json:"name" yaml:"name"`
+	Columns []TableColumn                                   `json:"columns" yaml:"columns"`
+	Indexes []TableIndex                                    `json:"indexes" yaml:"indexes"`
+	Policy  *configuration.ConfigurationDatabaseEntryPolicy `json:"policy,omitempty" yaml:"policy,omitempty"`
+}
+
+// alterTableActions are the migration actions which can be used to alter a table at runtime
+var alterTableActions = []string{
+	globals.MigrationActionAddColumn,
+	globals.MigrationActionDropColumn,
+	globals.MigrationActionRenameColumn,
+	globals.MigrationActionAddIndex,
+	globals.MigrationActionAddBlindIndex,
+}
+
+// ListTables returns the names of the user tables in the database
+func (wrapper *SQLiteWrapper) ListTables() ([]string, error) {
+	rows, err := wrapper.reader.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := make([]string, 0)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(name, globals.SystemTablePrefix) {
+			continue
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// TableExists returns true if the user table exists in the database
+func (wrapper *SQLiteWrapper) TableExists(table string) (bool, error) {
+	tables, err := wrapper.ListTables()
+	if err != nil {
+		return false, err
+	}
+	for _, name := range tables {
+		if name == table {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DescribeTable returns the user columns and indexes of a table
+func (wrapper *SQLiteWrapper) DescribeTable(table string) (TableDescription, error) {
+	description := TableDescription{Name: table, Columns: make([]TableColumn, 0), Indexes: make([]TableIndex, 0)}
+	if !validIdentifier(table) || strings.HasPrefix(table, globals.SystemTablePrefix) {
+		return description, errors.New(globals.ErrorInvalidTableName)
+	}
+	exists, err := wrapper.TableExists(table)
+	if err != nil {
+		return description, err
+	}
+	if !exists {
+		return description, errors.New(globals.ErrorNotExist)
+	}
+	rows, err := wrapper.reader.Query(`PRAGMA table_info(` + table + `)`)
+	if err != nil {
+		return description, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid          int
+			name         string
+			columnType   string
+			notNull      int
+			defaultValue sql.NullString
+			primaryKey   int
+		)
+		err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey)
+		if err != nil {
+			return description, err
+		}
+		if strings.HasPrefix(name, globals.SystemColumnPrefix) {
+			continue
+		}
+		description.Columns = append(description.Columns, TableColumn{Name: name, Type: columnType, PrimaryKey: primaryKey > 0, Nullable: notNull == 0, Default: defaultValue.String})
+	}
+	err = rows.Err()
+	if err != nil {
+		return description, err
+	}
+	indexed, err := blindIndexedColumns(wrapper.reader, table)
+	if err != nil {
+		return description, err
+	}
+	plaintext, err := plaintextColumns(wrapper.reader, table)
+	if err != nil {
+		return description, err
+	}
+	for i := range description.Columns {
+		description.Columns[i].Searchable = indexed[description.Columns[i].Name]
+		description.Columns[i].Encrypted = !plaintext[description.Columns[i].Name]
+	}
+	description.Indexes, err = describeIndexes(wrapper.reader, table)
+	if err != nil {
+		return description, err
+	}
+	description.Policy, err = tablePolicy(wrapper.reader, table)
+	return description, err
+}
+
+// CreateTable validates and creates a new user table along with its indexes
+func (wrapper *SQLiteWrapper) CreateTable(table configuration.ConfigurationDatabaseEntryTablesEntry, userID string) error {
+	query, err := createTableQuery(table)
+	if err != nil {
+		return err
+	}
+	err = validateIndexes(table)
+	if err != nil {
+		return err
+	}
+	columns := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		columns = append(columns, column.Name)
+	}
+	err = validatePolicy(table.Name, table.Policy, columns)
+	if err != nil {
+		return err
+	}
+	exists, err := wrapper.TableExists(table.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New(globals.ErrorTableExists)
+	}
+	err = checkReferences(wrapper.reader, table.Name, table.Columns)
+	if err != nil {
+		return err
+	}
+	log.Debug("Create table query: " + query)
+	_, err = wrapper.Execute(query, userID)
+	if err != nil {
+		return err
+	}
+	for _, plaintextQuery := range plaintextColumnQueries(table.Name, table.Columns) {
+		_, err = wrapper.exec(plaintextQuery)
+		if err != nil {
+			return err
+		}
+	}
+	err = reconcileIndexes(wrapper, []configuration.ConfigurationDatabaseEntryTablesEntry{table})
+	if err != nil {
+		return err
+	}
+	return reconcilePolicies(wrapper, []configuration.ConfigurationDatabaseEntryTablesEntry{table})
+}
+
+// AlterTable applies the alter steps to a user table in a single transaction
+func (wrapper *SQLiteWrapper) AlterTable(table string, steps []configuration.ConfigurationDatabaseEntryMigrationStep, userID string) error {
+	if !validIdentifier(table) || strings.HasPrefix(table, globals.SystemTablePrefix) {
+		return errors.New(globals.ErrorInvalidTableName)
+	}
+	if len(steps) == 0 {
+		return errors.New(globals.ErrorInvalidMigrationStep + ": at least one step is required")
+	}
+	exists, err := wrapper.TableExists(table)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New(globals.ErrorNotExist)
+	}
+
+	queries := make([]string, 0, len(steps))
+	for i := range steps {
+		step := &steps[i]
+		var allowed bool
+		for _, action := range alterTableActions {
+			if step.Action == action {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.New(globals.ErrorInvalidMigrationStep + ": action (" + step.Action + ") is not supported - Valid actions are: " + strings.Join(alterTableActions, ", "))
+		}
+		step.Table = table
+		query, _, err := migrationStepQuery(*step, nil, nil)
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Action, err)
+		}
+		queries = append(queries, query)
+	}
+
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		return err
+	}
+	tx := transaction.tx
+	newValues := fmt.Sprintf("%v", queries)
+	for i, query := range queries {
+		log.Debug("Alter table query: " + query)
+		err = execMigrationStep(tx, steps[i], query, nil)
+		if err != nil {
+			transaction.Rollback(err)
+			txErr := createTransaction(wrapper.name, userID, "ALTER(ROLLBACK)", table, "", "", newValues, "", "ERROR", err)
+			if txErr != nil {
+				log.Error("Error when logging failed table alteration: " + txErr.Error())
+			}
+			return err
+		}
+	}
+	err = transaction.Commit()
+	if err != nil {
+		txErr := createTransaction(wrapper.name, userID, "ALTER", table, "", "", newValues, "", "ERROR", err)
+		if txErr != nil {
+			log.Error("Error when logging failed table alteration: " + txErr.Error())
+		}
+		return err
+	}
+	err = reindexTableBlindIndexes(wrapper, table)
+	if err != nil {
+		log.Error("Error when building blind indexes of table (" + table + "): " + err.Error())
+	}
+	return createTransaction(wrapper.name, userID, "ALTER", table, "", "", newValues, "", "SUCCESS", nil)
+}
+
+// DropTable drops a user table
+func (wrapper *SQLiteWrapper) DropTable(table, userID string) error {
+	if !validIdentifier(table) || strings.HasPrefix(table, globals.SystemTablePrefix) {
+		return errors.New(globals.ErrorInvalidTableName)
+	}
+	exists, err := wrapper.TableExists(table)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New(globals.ErrorNotExist)
+	}
+	// The records of the table are dropped along with it
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		return err
+	}
+	_, err = transaction.Execute(`DROP TABLE ` + table)
+	if err == nil {
+		err = dropPlaintextColumns(transaction.tx, table)
+	}
+	if err == nil {
+		err = dropRecordedIndexes(transaction.tx, table)
+	}
+	if err == nil {
+		err = dropRecordedPolicy(transaction.tx, table)
+	}
+	if err != nil {
+		transaction.Rollback(err)
+		return err
+	}
+	return transaction.Commit()
+}
@@ -0,0 +1,270 @@
+package sqlWrapper
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	log "github.com/sirupsen/logrus"
+)
+
+// jwtTableQuery creates the JWT table which holds one row per session - A user can have any number of sessions
+// The access token is stored encrypted like any other value while the refresh tokens are only stored as hashes
+// Timestamps are Unix timestamps (Seconds) like the timeout which is returned on login
+var jwtTableQuery = `CREATE TABLE IF NOT EXISTS ` + globals.JWTTable + ` (session TEXT PRIMARY KEY, id TEXT NOT NULL, token TEXT NOT NULL, sha256 TEXT NOT NULL, expiration INTEGER NOT NULL, refreshSHA256 TEXT NOT NULL, previousRefreshSHA256 TEXT NOT NULL DEFAULT '', refreshExpiration INTEGER NOT NULL, createdAt INTEGER NOT NULL)`
+
+// Session is a struct that holds a login session of a user without its tokens
+type Session struct {
+	ID                string `json:"session" yaml:"session"`
+	UserID            string `json:"userID" yaml:"userID"`
+	CreatedAt         int64  `json:"createdAt" yaml:"createdAt"`
+	Expiration        int64  `json:"expiration" yaml:"expiration"`
+	RefreshExpiration int64  `json:"refreshExpiration" yaml:"refreshExpiration"`
+}
+
+// SessionTokens is a struct that holds the access token of a session and the hashes of its refresh tokens
+type SessionTokens struct {
+	Token                 string
+	TokenSHA256           string
+	RefreshSHA256         string
+	PreviousRefreshSHA256 string
+}
+
+// Sessions returns the sessions of the user ordered by creation - Every session of the database is returned when the user is empty
+func (wrapper *SQLiteWrapper) Sessions(userID string) ([]Session, error) {
+	sessions := []Session{}
+	query := `SELECT session, id, createdAt, expiration, refreshExpiration, token, sha256, refreshSHA256, previousRefreshSHA256 FROM ` + globals.JWTTable
+	var args []interface{}
+	if userID != "" {
+		query += ` WHERE id = ?`
+		args = append(args, userID)
+	}
+	rows, err := wrapper.reader.Query(query+` ORDER BY createdAt, session`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		session, _, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Session returns the session with the ID along with its tokens
+func (wrapper *SQLiteWrapper) Session(id string) (Session, SessionTokens, bool, error) {
+	return readSession(wrapper.reader, id)
+}
+
+// CreateSession stores a new session of the user with its tokens
+func (wrapper *SQLiteWrapper) CreateSession(session Session, tokens SessionTokens) error {
+	_, err := wrapper.exec(`INSERT INTO `+globals.JWTTable+` (session, id, token, sha256, expiration, refreshSHA256, previousRefreshSHA256, refreshExpiration, createdAt) VALUES (?, ?, ?, ?, ?, ?, '', ?, ?)`, session.ID, session.UserID, data.Process(tokens.Token), data.Process(tokens.TokenSHA256), session.Expiration, tokens.RefreshSHA256, session.RefreshExpiration, session.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return auditSession(wrapper.name, session.UserID, globals.SessionCreateTransactionAction, session)
+}
+
+// RotateSession replaces the tokens of the session if the refresh token hash still matches the one which was presented
+// The presented refresh token is kept as the previous one so that its reuse can be detected
+// Rotations are not recorded in the transaction log as they happen every time an access token expires
+func (wrapper *SQLiteWrapper) RotateSession(session Session, tokens SessionTokens, presentedRefreshSHA256 string) error {
+	result, err := wrapper.exec(`UPDATE `+globals.JWTTable+` SET token = ?, sha256 = ?, expiration = ?, refreshSHA256 = ?, previousRefreshSHA256 = ?, refreshExpiration = ? WHERE session = ? AND refreshSHA256 = ?`, data.Process(tokens.Token), data.Process(tokens.TokenSHA256), session.Expiration, tokens.RefreshSHA256, presentedRefreshSHA256, session.RefreshExpiration, session.ID, presentedRefreshSHA256)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// The session was revoked or refreshed by another request in the meantime
+		return errors.New(globals.ErrorSessionNotFound + ": " + session.ID)
+	}
+	return nil
+}
+
+// RevokeSessions deletes the session with the ID or every session of the owner when the ID is empty
+// Sessions of other users are not found unless the owner is empty
+func (wrapper *SQLiteWrapper) RevokeSessions(id, ownerID, userID string) ([]Session, error) {
+	if id == "" && ownerID == "" {
+		return nil, errors.New(globals.ErrorSessionNotFound + ": a session or user is required")
+	}
+	transaction, err := wrapper.Begin(userID)
+	if err != nil {
+		return nil, err
+	}
+	tx := transaction.tx
+	var sessions []Session
+	if id != "" {
+		session, _, exists, err := readSession(tx, id)
+		if err != nil {
+			transaction.Rollback(err)
+			return nil, err
+		}
+		if !exists || (ownerID != "" && session.UserID != ownerID) {
+			transaction.Rollback(nil)
+			return nil, errors.New(globals.ErrorSessionNotFound + ": " + id)
+		}
+		sessions = append(sessions, session)
+	} else {
+		rows, err := tx.Query(`SELECT session, id, createdAt, expiration, refreshExpiration, token, sha256, refreshSHA256, previousRefreshSHA256 FROM `+globals.JWTTable+` WHERE id = ?`, ownerID)
+		if err != nil {
+			transaction.Rollback(err)
+			return nil, err
+		}
+		for rows.Next() {
+			session, _, err := scanSession(rows)
+			if err != nil {
+				rows.Close()
+				transaction.Rollback(err)
+				return nil, err
+			}
+			sessions = append(sessions, session)
+		}
+		rows.Close()
+		if len(sessions) == 0 {
+			transaction.Rollback(nil)
+			return nil, errors.New(globals.ErrorSessionNotFound + ": user (" + ownerID + ") does not have any sessions")
+		}
+	}
+	for _, session := range sessions {
+		_, err = tx.Exec(`DELETE FROM `+globals.JWTTable+` WHERE session = ?`, session.ID)
+		if err != nil {
+			transaction.Rollback(err)
+			return nil, err
+		}
+	}
+	err = transaction.Commit()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		err = auditSession(wrapper.name, userID, globals.SessionRevokeTransactionAction, session)
+		if err != nil {
+			return sessions, err
+		}
+	}
+	return sessions, nil
+}
+
+// PurgeSessions deletes the sessions which were started before the max lifetime start (Zero skips it) and the sessions whose refresh token expired
+// The number of sessions which exceeded the max lifetime and of expired sessions are returned
+// Purged sessions are not recorded in the transaction log as they ended on their own
+func (wrapper *SQLiteWrapper) PurgeSessions(now, maxLifetimeStart int64) (int64, int64, error) {
+	transaction, err := wrapper.Begin(globals.SystemUserID)
+	if err != nil {
+		return 0, 0, err
+	}
+	tx := transaction.tx
+	// Sessions which exceeded the max lifetime are deleted first as their refresh token expires with it
+	var exceeded int64
+	if maxLifetimeStart > 0 {
+		result, err := tx.Exec(`DELETE FROM `+globals.JWTTable+` WHERE createdAt <= ?`, maxLifetimeStart)
+		if err == nil {
+			exceeded, err = result.RowsAffected()
+		}
+		if err != nil {
+			transaction.Rollback(err)
+			return 0, 0, err
+		}
+	}
+	var expired int64
+	result, err := tx.Exec(`DELETE FROM `+globals.JWTTable+` WHERE refreshExpiration <= ?`, now)
+	if err == nil {
+		expired, err = result.RowsAffected()
+	}
+	if err != nil {
+		transaction.Rollback(err)
+		return 0, 0, err
+	}
+	err = transaction.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+	return exceeded, expired, nil
+}
+
+// upgradeJWTTable replaces a JWT table from before sessions (One row per user) with the session table
+// The tokens of the old table can not be mapped to sessions so the users have to log in again
+func upgradeJWTTable(wrapper *SQLiteWrapper) error {
+	columns, err := tableColumns(wrapper.reader, globals.JWTTable)
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if column == "session" {
+			return nil
+		}
+	}
+	transaction, err := wrapper.Begin(globals.SystemUserID)
+	if err != nil {
+		return err
+	}
+	tx := transaction.tx
+	_, err = tx.Exec(`DROP TABLE IF EXISTS ` + globals.JWTTable)
+	if err == nil {
+		_, err = tx.Exec(jwtTableQuery)
+	}
+	if err != nil {
+		transaction.Rollback(err)
+		return err
+	}
+	err = transaction.Commit()
+	if err != nil {
+		return err
+	}
+	if len(columns) > 0 {
+		log.Warn("Upgraded JWT table of database (" + wrapper.name + ") to sessions - Users which were logged in have to log in again")
+	}
+	return nil
+}
+
+// readSession reads a session by ID along with its tokens
+func readSession(q queryer, id string) (Session, SessionTokens, bool, error) {
+	rows, err := q.Query(`SELECT session, id, createdAt, expiration, refreshExpiration, token, sha256, refreshSHA256, previousRefreshSHA256 FROM `+globals.JWTTable+` WHERE session = ?`, id)
+	if err != nil {
+		return Session{}, SessionTokens{}, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return Session{}, SessionTokens{}, false, rows.Err()
+	}
+	session, tokens, err := scanSession(rows)
+	if err != nil {
+		return Session{}, SessionTokens{}, false, err
+	}
+	return session, tokens, true, nil
+}
+
+// scanSession scans a session and its tokens from the current row
+func scanSession(rows *sql.Rows) (Session, SessionTokens, error) {
+	var session Session
+	var tokens SessionTokens
+	err := rows.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.Expiration, &session.RefreshExpiration, &tokens.Token, &tokens.TokenSHA256, &tokens.RefreshSHA256, &tokens.PreviousRefreshSHA256)
+	if err != nil {
+		return Session{}, SessionTokens{}, err
+	}
+	tokens.Token = fmt.Sprint(data.Process(tokens.Token))
+	tokens.TokenSHA256 = fmt.Sprint(data.Process(tokens.TokenSHA256))
+	return session, tokens, nil
+}
+
+// auditSession records the creation or revocation of a session in the transaction log - The tokens are never recorded
+func auditSession(database, userID, action string, session Session) error {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	oldValues, newValues := "", string(encoded)
+	if action == globals.SessionRevokeTransactionAction {
+		oldValues, newValues = newValues, ""
+	}
+	return createTransaction(database, userID, action, globals.JWTTable, session.ID, oldValues, newValues, "", "SUCCESS", nil)
+}
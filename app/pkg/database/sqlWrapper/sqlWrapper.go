@@ -1,7 +1,6 @@
 package sqlWrapper
 
 import (
-	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -9,10 +8,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	"github.com/mitchs-dev/simplQL/pkg/database/data"
+	"github.com/mitchs-dev/simplQL/pkg/metrics"
 
 	log "github.com/sirupsen/logrus"
 
@@ -22,246 +24,123 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-var c configuration.Configuration
-
 var skipDataProcess bool
 
-// SQLiteWrapper is a struct that holds the database connection
+// SQLiteWrapper is a struct that holds the database connections
+// Writes go through a single connection so that they are queued by the pool while reads are spread across read-only connections
+// Shared wrappers (See: Open) count the requests which hold them so that they are only closed once those are done
 type SQLiteWrapper struct {
-	db   *sql.DB
-	name string
+	db      *sql.DB
+	reader  *sql.DB
+	name    string
+	users   sync.WaitGroup
+	closing bool
 }
 
-// NewSQLiteWrapper creates a new SQLiteWrapper and enables WAL mode
+// NewSQLiteWrapper opens the database file with a single writer connection and a pool of read-only connections
+// WAL mode lets the readers run alongside the writer and the busy timeout makes connections wait for locks held by other processes
+// Requests should use the shared wrapper of the database (See: Open) rather than opening their own
 func NewSQLiteWrapper(dataSourceName string) (*SQLiteWrapper, error) {
-	db, err := sql.Open("sqlite3", dataSourceName)
+	// Foreign keys are enabled per connection (PRAGMA foreign_keys = ON) so they are set on every connection of the pools
+	options := "_foreign_keys=on&_busy_timeout=" + fmt.Sprint(globals.DatabaseBusyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", "file:"+dataSourceName+"?"+options+"&_txlock=immediate")
 	if err != nil {
 		return nil, err
 	}
+	// The writer connection is kept open so that the WAL is not checkpointed and removed between requests
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
 
 	// Ensure that each wrapper has WAL mode enabled
 	_, err = db.Exec("PRAGMA journal_mode = WAL;")
 	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	readers := globals.DatabaseReaderConnections
+	if readers <= 0 {
+		readers = 1
+	}
+	reader, err := sql.Open("sqlite3", "file:"+dataSourceName+"?mode=ro&"+options)
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
+	reader.SetMaxOpenConns(readers)
+	reader.SetMaxIdleConns(readers)
 
 	databaseName := filepath.Base(dataSourceName)
 	databaseNameExt := filepath.Ext(databaseName)
 	databaseName = strings.TrimSuffix(databaseName, databaseNameExt)
-	return &SQLiteWrapper{db: db, name: databaseName}, nil
+	return &SQLiteWrapper{db: db, reader: reader, name: databaseName}, nil
 }
 
-// Close closes the database connection
+// Close closes the database connections
+// Shared wrappers (See: Open) are closed with CloseDatabase or CloseDatabases instead
 func (wrapper *SQLiteWrapper) Close() error {
-	return wrapper.db.Close()
+	readerErr := wrapper.reader.Close()
+	// The writer is closed last so that the WAL is checkpointed by the last connection
+	err := wrapper.db.Close()
+	if err == nil {
+		err = readerErr
+	}
+	return err
 }
 
-// Execute executes a query without returning any rows
+// Execute executes a query without returning any rows in its own transaction
 func (wrapper *SQLiteWrapper) Execute(query, userID string, args ...interface{}) (sql.Result, error) {
-	tx, err := wrapper.db.BeginTx(context.Background(), &sql.TxOptions{
-		Isolation: sql.LevelSerializable,
-	})
+	defer metrics.ObserveQuery(wrapper.name, globals.MetricsOperationExecute, time.Now())
+	transaction, err := wrapper.Begin(userID)
 	if err != nil {
 		return nil, err
 	}
-
-	stmt, err := tx.Prepare(query)
+	result, err := transaction.Execute(query, args...)
 	if err != nil {
-		tx.Rollback()
+		rollbackErr := transaction.Rollback(err)
+		if rollbackErr != nil {
+			log.Error("Error when rolling back execution: " + rollbackErr.Error())
+		}
 		return nil, err
 	}
-	defer stmt.Close()
-
-	var newArgs []interface{}
-	if strings.Contains(query, "DELETE") {
-		for _, arg := range args {
-			switch v := arg.(type) {
-			case []string:
-				for _, str := range v {
-					newArgs = append(newArgs, str)
-				}
-			default:
-				newArgs = append(newArgs, arg)
-			}
-		}
-	} else {
-		for _, arg := range args {
-			switch v := arg.(type) {
-			case []string:
-				for _, str := range v {
-					newArg := data.Process(str)
-					newArgs = append(newArgs, newArg)
-				}
-			default:
-				newArg := data.Process(arg)
-				newArgs = append(newArgs, newArg)
-			}
-		}
+	err = transaction.Commit()
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	var oldValues string
-	if strings.Contains(query, "UPDATE") {
-		oldValueColumns := fetchSetColumns(query)
-		if oldValueColumns != "" {
-			// Extract the columns and arguments from the query
-			var oldValueWhereStatement string
-
-			// Split the query into SET and WHERE parts
-			setClauseIndex := strings.Index(query, "SET")
-			whereClauseIndex := strings.Index(query, "WHERE")
-
-			// Ensure that both SET and WHERE clauses exist
-			var (
-				setClausePlaceholderCount   int
-				whereClausePlaceholderCount int
-				setClause                   string
-				whereClause                 string
-			)
-			if setClauseIndex != -1 && whereClauseIndex != -1 && whereClauseIndex > setClauseIndex {
-				setClause = query[setClauseIndex+len("SET") : whereClauseIndex]
-				whereClause = query[whereClauseIndex+len("WHERE"):]
-
-				// Count the number of placeholders in each clause
-				setClausePlaceholderCount = strings.Count(setClause, "?")
-				whereClausePlaceholderCount = strings.Count(whereClause, "?")
-			} else {
-				return nil, errors.New("error when creating transaction: SET and WHERE clauses could not be determined or are invalid")
-			}
-			log.Debug("Set clause placeholder count: " + fmt.Sprint(setClausePlaceholderCount))
-			log.Debug("Where clause placeholder count: " + fmt.Sprint(whereClausePlaceholderCount))
-
-			// Separate the arguments based on the counts
-			setClauseArgs := newArgs[:setClausePlaceholderCount]
-			whereClauseArgs := newArgs[setClausePlaceholderCount:]
-			log.Debug("Set clause args: " + fmt.Sprintf("%v", setClauseArgs))
-			log.Debug("Where clause args: " + fmt.Sprintf("%v", whereClauseArgs))
-
-			// Construct the WHERE statement
-			rawFilters := strings.Split(whereClause, "AND")
-			var filters []string
-			for _, rawFilter := range rawFilters {
-				filters = append(filters, strings.TrimSpace(rawFilter))
-			}
-			oldValueWhereStatement = " WHERE " + strings.Join(filters, " AND ")
-
-			// Use the columns to fetch the old values
-			oldValuesQuery := "SELECT " + oldValueColumns + " FROM " + extractTableName(query) + oldValueWhereStatement
-			log.Debug("Old values query: " + oldValuesQuery + " with args: " + fmt.Sprintf("%v", whereClauseArgs) + " (arg count: " + fmt.Sprint(len(whereClauseArgs)) + ")")
-			skipDataProcess = true
-			oldValuesRow := wrapper.QueryRow(oldValuesQuery, whereClauseArgs...)
-			skipDataProcess = false
-
-			// Split the columns by comma and create a slice of interface{} to hold the values
-			columns := strings.Split(oldValueColumns, ",")
-			values := make([]interface{}, len(columns))
-			valuePointers := make([]interface{}, len(columns))
-			for i := range values {
-				valuePointers[i] = &values[i]
-			}
-
-			// Add debug logs to inspect the state before calling Scan
-			log.Debugf("Columns: %v", columns)
-			log.Debugf("Values: %v", values)
-			log.Debugf("Value Pointers: %v", valuePointers)
-			log.Debugf("Executing query: %s with args: %v", oldValuesQuery, whereClauseArgs)
-
-			err := oldValuesRow.Scan(valuePointers...)
-			if err != nil {
-				if strings.Contains(err.Error(), "no rows in result set") {
-					log.Debug("Error creating transaction - It's possible that the old values are empty: " + err.Error())
-					log.Debugf("Query: %s with args: %v", oldValuesQuery, whereClauseArgs)
-					return nil, errors.New(globals.ErrorTransactionNoEntry)
-				} else {
-					log.Error("Error when fetching old values: " + err.Error())
-					return nil, err
-				}
-			}
-
-			// Add debug logs to inspect the values after calling Scan
-			for i, col := range columns {
-				log.Debugf("Column: %s, Value: %v", col, values[i])
-			}
+// Query executes a query that returns rows
+func (wrapper *SQLiteWrapper) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	c := configuration.Current()
+	query, newArgs := prepareQuery(query, args)
+	queryStart := time.Now()
+	rows, err := wrapper.reader.Query(query, newArgs...)
+	metrics.ObserveQuery(wrapper.name, globals.MetricsOperationQuery, queryStart)
 
-			// Convert the values to a string representation
-			oldValues = fmt.Sprintf("%v", values)
+	userID := "toBeUpdated"
+	action := "SELECT"
+	if c.Logging.Transactions.LogSelectQueries {
+		log.Warn("SELECT query logging is not supported yet")
+		return rows, err
+		table := extractTableName(query)
+		if table == "" {
+			return nil, errors.New(globals.ErrorTransactionTableNameExtraction)
 		}
-	}
-
-	log.Debug("Executing query: " + query + " with args: " + fmt.Sprintf("%v", newArgs))
-	result, err := stmt.Exec(newArgs...)
-	var (
-		action    string
-		table     string
-		recordID  string
-		newValues string
-		ipAddress string
-		status    string
-	)
-	table = extractTableName(query)
-	if table == "" {
-		return nil, errors.New(globals.ErrorTransactionTableNameExtraction)
-	}
-	if table == globals.TransactionsTable {
-		log.Debug("Transaction table detected - skipping transaction creation")
-		globals.IsTransactionExecution = true
-	} else {
-		log.Debug("Transaction table not detected - creating transaction")
-		globals.IsTransactionExecution = false
-		recordID = extractRecordID(query, newArgs)
-
-		if recordID == "" && !strings.Contains(query, "CREATE") && !strings.Contains(query, globals.SystemTablePrefix) {
+		recordID := extractRecordID(query, newArgs)
+		if recordID == "" {
 			return nil, errors.New(globals.ErrorTransactionRecordIDExtraction)
 		}
-		ipAddress = ""
-		status = "ERROR"
-	}
-	switch {
-	case strings.Contains(query, "INSERT"):
-		action = "INSERT"
-		newValues = fmt.Sprintf("%v", newArgs)
-	case strings.Contains(query, "UPDATE"):
-		action = "UPDATE"
-		newValues = fmt.Sprintf("%v", newArgs)
-	case strings.Contains(query, "DELETE"):
-		action = "DELETE"
-		newValues = fmt.Sprintf("%v", newArgs)
-	case strings.Contains(query, "SELECT"):
-		action = "SELECT"
-	case strings.Contains(query, "CREATE"):
-		action = "CREATE"
-	default:
-		log.Error("Unknown action in query: " + strings.Split(query, " ")[0])
-		action = "UNKNOWN"
-	}
-
-	if err != nil {
-		tx.Rollback()
-		if action != "SELECT" && action != "UNKNOWN" {
-			action = action + "(ROLLBACK)"
-			if !globals.IsTransactionExecution {
-				err = createTransaction(wrapper.name, userID, action, table, recordID, oldValues, newValues, ipAddress, status, err)
-			} else {
-				log.Debug("Execution is for a transaction - not creating a transaction")
-			}
-		}
-		return nil, err
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		if action != "SELECT" && action != "UNKNOWN" {
+		oldValues := ""
+		newValues := ""
+		ipAddress := ""
+		var status string
+		if err != nil {
 			status = "ERROR"
-			if !globals.IsTransactionExecution {
-				err = createTransaction(wrapper.name, userID, action, table, recordID, oldValues, newValues, ipAddress, status, err)
-			} else {
-				log.Debug("Execution is for a transaction - not creating a transaction")
-			}
+		} else {
+			status = "SUCCESS"
 		}
-		return nil, err
-	}
 
-	if action != "SELECT" && action != "UNKNOWN" {
-		status = "SUCCESS"
 		if !globals.IsTransactionExecution {
 			err = createTransaction(wrapper.name, userID, action, table, recordID, oldValues, newValues, ipAddress, status, err)
 		} else {
@@ -272,11 +151,12 @@ func (wrapper *SQLiteWrapper) Execute(query, userID string, args ...interface{})
 		}
 	}
 
-	return result, nil
+	return rows, err
 }
 
-// Query executes a query that returns rows
-func (wrapper *SQLiteWrapper) Query(query string, args ...interface{}) (*sql.Rows, error) {
+// prepareQuery replaces quoted values in the query with placeholders and processes the arguments
+func prepareQuery(query string, args []interface{}) (string, []interface{}) {
+	c := configuration.Current()
 	var filterArgs []interface{}
 	var newArgs []interface{}
 
@@ -310,6 +190,12 @@ func (wrapper *SQLiteWrapper) Query(query string, args ...interface{}) (*sql.Row
 		switch v := arg.(type) {
 		case string:
 			log.Debug("Processing string argument: " + v)
+			if strings.Contains(v, "%") && !c.Storage.Encryption.Enabled {
+				// Without encryption the pattern can be matched as is
+				log.Debug("Argument is a LIKE pattern: " + v)
+				newArgs = append(newArgs, v)
+				continue
+			}
 			if strings.Contains(v, "%") {
 				v = strings.ReplaceAll(v, "%", "")
 				isLike = true
@@ -350,43 +236,7 @@ func (wrapper *SQLiteWrapper) Query(query string, args ...interface{}) (*sql.Row
 	}
 	log.Debug("Running query: " + query)
 	log.Debug("New args: ", newArgs)
-
-	rows, err := wrapper.db.Query(query, newArgs...)
-
-	userID := "toBeUpdated"
-	action := "SELECT"
-	if c.Logging.Transactions.LogSelectQueries {
-		log.Warn("SELECT query logging is not supported yet")
-		return rows, err
-		table := extractTableName(query)
-		if table == "" {
-			return nil, errors.New(globals.ErrorTransactionTableNameExtraction)
-		}
-		recordID := extractRecordID(query, newArgs)
-		if recordID == "" {
-			return nil, errors.New(globals.ErrorTransactionRecordIDExtraction)
-		}
-		oldValues := ""
-		newValues := ""
-		ipAddress := ""
-		var status string
-		if err != nil {
-			status = "ERROR"
-		} else {
-			status = "SUCCESS"
-		}
-
-		if !globals.IsTransactionExecution {
-			err = createTransaction(wrapper.name, userID, action, table, recordID, oldValues, newValues, ipAddress, status, err)
-		} else {
-			log.Debug("Execution is for a transaction - not creating a transaction")
-		}
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return rows, err
+	return query, newArgs
 }
 
 // QueryRow executes a query that returns a single row
@@ -402,21 +252,31 @@ func (wrapper *SQLiteWrapper) QueryRow(query string, args ...interface{}) *sql.R
 		log.Debug("Skipping data processing")
 		newArgs = args
 	}
-	return wrapper.db.QueryRow(query, newArgs...)
+	return wrapper.reader.QueryRow(query, newArgs...)
 }
 
 // createDatabases creates the databases specified in the configuration
 func CreateDatabases() error {
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Initializing databases")
-	for _, database := range c.Databases {
+	provisioned, err := provisionedDatabases()
+	if err != nil {
+		return err
+	}
+	databases := append(append([]configuration.ConfigurationDatabaseEntry{}, c.Databases...), provisioned...)
+	for _, database := range databases {
 		log.Debug("Initializing database: " + database.Name)
+		err := validateMigrations(database)
+		if err != nil {
+			log.Error("Invalid migrations for database (" + database.Name + "): " + err.Error())
+			return errors.New(globals.ErrorMigration)
+		}
 		dbFilePath := c.Storage.Path + "/" + database.Name + ".db"
-		wrapper, err := NewSQLiteWrapper(dbFilePath)
+		wrapper, err := sharedWrapper(database.Name)
 		if err != nil {
-			log.Fatal("Error when creating database wrapper: " + err.Error())
+			log.Error("Error when opening database (" + database.Name + "): " + err.Error())
+			return err
 		}
-		defer wrapper.Close()
 		var dbVersionRaw string
 		var createDB bool
 		err = wrapper.QueryRow("SELECT version FROM " + globals.MetadataTable).Scan(&dbVersionRaw)
@@ -428,7 +288,8 @@ func CreateDatabases() error {
 				log.Debug("Database file does not exist: " + dbFilePath + " - Will create it")
 				createDB = true
 			} else {
-				log.Fatal("Error when querying database version: " + err.Error())
+				log.Error("Error when querying database version: " + err.Error())
+				return err
 			}
 		} else {
 			createDB = false
@@ -436,93 +297,57 @@ func CreateDatabases() error {
 		if !processor.DirectoryOrFileExists(dbFilePath) {
 			log.Info("Creating database file: " + dbFilePath)
 			if !processor.CreateFileAsByte(dbFilePath, []byte{}) {
-				log.Fatal("Error when creating database file: " + dbFilePath)
+				log.Error("Error when creating database file: " + dbFilePath)
+				return errors.New(globals.ErrorDatabaseInitialization)
 			}
 		} else {
 			log.Debug("Database file already exists: " + dbFilePath)
 		}
 		if !createDB {
 			log.Debug("Database already exists: " + database.Name)
-			dbVersion, err := strconv.Atoi(data.Process(dbVersionRaw).(string))
+			dbVersion, err := strconv.Atoi(fmt.Sprint(data.Process(dbVersionRaw)))
 			if err != nil {
-				log.Fatal("Error when converting database version: " + err.Error())
+				log.Error("Error when converting database version: " + err.Error())
+				return err
 			}
-			if dbVersion != database.Version {
-				log.Warn("Database version mismatch: " + dbFilePath)
+			if dbVersion < database.Version {
+				log.Info("Database " + database.Name + "@v" + fmt.Sprint(dbVersion) + " is behind the configured version (v" + fmt.Sprint(database.Version) + ") - Running migrations")
+				err = runMigrations(wrapper, database, dbVersion)
+				if err != nil {
+					return err
+				}
+				log.Info("Database " + database.Name + "@v" + fmt.Sprint(database.Version) + " is ready")
+			} else if dbVersion > database.Version {
+				log.Error("Database " + database.Name + "@v" + fmt.Sprint(dbVersion) + " is newer than the configured version (v" + fmt.Sprint(database.Version) + ") - Refusing to start")
+				return errors.New(globals.ErrorMigration)
 			} else {
 				log.Info("Database " + database.Name + "@v" + fmt.Sprint(dbVersion) + " is ready")
 
 			}
-		} else {
-			log.Debug("Creating database: " + database.Name)
-			err = createTransactionsTable(database.Name)
+			err = upgradeSystemTables(wrapper)
 			if err != nil {
+				log.Error("Error when upgrading system tables of database (" + database.Name + "): " + err.Error())
 				return err
 			}
-			err = createMetadataTable(database.Name, database.Version)
+			err = reindexBlindIndexes(wrapper)
 			if err != nil {
+				log.Error("Error when building blind indexes of database (" + database.Name + "): " + err.Error())
 				return err
 			}
-			err = createUsersTable(database.Name)
+			err = reconcileIndexes(wrapper, database.Tables)
 			if err != nil {
+				log.Error("Error when reconciling indexes of database (" + database.Name + "): " + err.Error())
 				return err
 			}
-			err = createJWTTable(database.Name)
+			err = reconcilePolicies(wrapper, database.Tables)
 			if err != nil {
+				log.Error("Error when reconciling policies of database (" + database.Name + "): " + err.Error())
 				return err
 			}
-			// Create tables
-			log.Debug("Creating database tables for: " + database.Name)
-			for _, table := range database.Tables {
-				if strings.HasPrefix(table.Name, globals.SystemTablePrefix) {
-					log.Error("Invalid table name: " + table.Name + " - Table names cannot be prefixed with '" + globals.SystemTablePrefix + "' as this is reserved for system tables")
-					if processor.FileDelete(dbFilePath) {
-						log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
-					}
-					return errors.New(globals.ErrorInvalidTableName)
-				}
-				columns := make([]string, 0)
-				for _, column := range table.Columns {
-					var columnTypeIsValid bool
-					for _, columnType := range globals.ColumnTypes {
-						if column.Type == columnType {
-							if strings.HasPrefix(column.Name, globals.SystemColumnPrefix) {
-								log.Error("Invalid column name: " + column.Name + " - Column names cannot be prefixed with '" + globals.SystemColumnPrefix + "' as this is reserved for system columns")
-								if processor.FileDelete(dbFilePath) {
-									log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
-								}
-								return errors.New(globals.ErrorDatabaseInitialization)
-							}
-							columnTypeIsValid = true
-							break
-						} else {
-							columnTypeIsValid = false
-						}
-					}
-					if !columnTypeIsValid {
-						log.Error("Invalid column type: " + column.Type + " for column: " + column.Name + " in table: " + table.Name)
-						log.Info("Columns must be a text-type. Valid types are: " + strings.Join(globals.ColumnTypes, ", "))
-						if processor.FileDelete(dbFilePath) {
-							log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
-						}
-						return errors.New(globals.ErrorInvalidColumnType)
-					}
-					columns = append(columns, column.Name+" "+column.Type)
-					if column.PrimaryKey {
-						columns[len(columns)-1] += " PRIMARY KEY"
-					}
-				}
-				// Insert entry ID column
-				columns = append(columns, globals.TableEntryIDColumnName+" TEXT")
-				query := `CREATE TABLE IF NOT EXISTS ` + table.Name + ` (` + strings.Join(columns, ", ") + `)`
-				_, err := wrapper.Execute(query, globals.SystemUserID)
-				if err != nil {
-					log.Error("Error when creating table: " + err.Error())
-					if processor.FileDelete(dbFilePath) {
-						log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
-					}
-					return errors.New(globals.ErrorDatabaseInitialization)
-				}
+		} else {
+			_, _, err = initializeDatabase(database, "", "")
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -530,10 +355,95 @@ func CreateDatabases() error {
 	return nil
 }
 
+// initializeDatabase creates the system tables, seeds the default admin, and creates the tables and indexes of a new database
+// The name of the admin is returned along with the password if it was generated
+func initializeDatabase(database configuration.ConfigurationDatabaseEntry, adminName, adminPassword string) (string, string, error) {
+	c := configuration.Current()
+	dbFilePath := c.Storage.Path + "/" + database.Name + ".db"
+	log.Debug("Creating database: " + database.Name)
+	err := createTransactionsTable(database.Name)
+	if err != nil {
+		return "", "", err
+	}
+	err = createMetadataTable(database.Name, database.Version)
+	if err != nil {
+		return "", "", err
+	}
+	adminName, adminPassword, err = createUsersTable(database.Name, adminName, adminPassword)
+	if err != nil {
+		return "", "", err
+	}
+	err = createJWTTable(database.Name)
+	if err != nil {
+		return "", "", err
+	}
+	err = createAPIKeysTable(database.Name)
+	if err != nil {
+		return "", "", err
+	}
+	err = createRolesTable(database.Name)
+	if err != nil {
+		return "", "", err
+	}
+	wrapper, err := sharedWrapper(database.Name)
+	if err != nil {
+		return "", "", err
+	}
+	// Create tables
+	log.Debug("Creating database tables for: " + database.Name)
+	for _, table := range database.Tables {
+		query, err := createTableQuery(table)
+		if err == nil {
+			err = checkReferences(wrapper.reader, table.Name, table.Columns)
+		}
+		if err != nil {
+			if deleteDatabaseFile(dbFilePath) {
+				log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
+			}
+			return "", "", err
+		}
+		_, err = wrapper.Execute(query, globals.SystemUserID)
+		if err != nil {
+			log.Error("Error when creating table: " + err.Error())
+			if deleteDatabaseFile(dbFilePath) {
+				log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
+			}
+			return "", "", errors.New(globals.ErrorDatabaseInitialization)
+		}
+		for _, plaintextQuery := range plaintextColumnQueries(table.Name, table.Columns) {
+			_, err = wrapper.exec(plaintextQuery)
+			if err != nil {
+				log.Error("Error when recording unencrypted columns: " + err.Error())
+				if deleteDatabaseFile(dbFilePath) {
+					log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
+				}
+				return "", "", errors.New(globals.ErrorDatabaseInitialization)
+			}
+		}
+	}
+	err = reconcileIndexes(wrapper, database.Tables)
+	if err != nil {
+		log.Error("Error when creating indexes: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
+		}
+		return "", "", err
+	}
+	err = reconcilePolicies(wrapper, database.Tables)
+	if err != nil {
+		log.Error("Error when recording policies: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database.Name + ") due to failed initialization")
+		}
+		return "", "", err
+	}
+	return adminName, adminPassword, nil
+}
+
 // Creates a transaction in the database
 func createTransaction(database, userID, actionType, affectedTable, recordID, oldValues, newValues, ipAddress, status string, errorMessage error) error {
 
-	c.GetConfig()
+	c := configuration.Current()
 
 	if !c.Logging.Transactions.Enabled {
 		globals.IsTransactionExecution = false
@@ -541,11 +451,11 @@ func createTransaction(database, userID, actionType, affectedTable, recordID, ol
 		return nil
 	}
 
-	wrapper, err := NewSQLiteWrapper(c.Storage.Path + "/" + database + ".db")
+	wrapper, err := sharedWrapper(database)
 	if err != nil {
+		metrics.TransactionLogWriteFailure(database)
 		return errors.New("Error when creating transaction: " + err.Error())
 	}
-	defer wrapper.Close()
 	// If any of the values are empty, set them to NULL
 	if userID == "" {
 		userID = "NULL"
@@ -574,6 +484,9 @@ func createTransaction(database, userID, actionType, affectedTable, recordID, ol
 			}
 			log.Debug("Transaction is for a create action - generating recordID")
 			recordID = "create-" + affectedTable + "-" + generator.RandomString(globals.TableEntryIDLength)
+		} else if strings.HasPrefix(actionType, "ALTER") || strings.HasPrefix(actionType, "DROP") {
+			log.Debug("Transaction is for a schema action - generating recordID")
+			recordID = strings.ToLower(strings.TrimSuffix(actionType, "(ROLLBACK)")) + "-" + affectedTable + "-" + generator.RandomString(globals.TableEntryIDLength)
 		} else if strings.Contains(affectedTable, globals.SystemTablePrefix) {
 			log.Debug("Transaction is for a system table - generating recordID")
 			recordID = "system-" + actionType + "-" + generator.RandomString(globals.TableEntryIDLength)
@@ -608,6 +521,7 @@ func createTransaction(database, userID, actionType, affectedTable, recordID, ol
 	_, err = wrapper.Execute(query, globals.SystemUserID, args...)
 	globals.IsTransactionExecution = false
 	if err != nil {
+		metrics.TransactionLogWriteFailure(database)
 		log.Error("Error when creating transaction: " + err.Error())
 		return err
 	}
@@ -7,25 +7,24 @@ import (
 	"os"
 
 	"github.com/mitchs-dev/library-go/generator"
-	"github.com/mitchs-dev/library-go/processor"
+	"github.com/mitchs-dev/simplQL/pkg/api/auth/password"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
 	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
 	log "github.com/sirupsen/logrus"
 )
 
 func createTransactionsTable(database string) error {
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Creating transactions table for database: " + database)
 	if !c.Logging.Transactions.Enabled {
 		globals.IsTransactionExecution = false
 		log.Debug("Transactions are disabled - Skipping transaction table creation")
 	} else {
-		dbFilePath := c.Storage.Path + "/" + database + ".db"
-		wrapper, err := NewSQLiteWrapper(dbFilePath)
+		wrapper, err := sharedWrapper(database)
 		if err != nil {
 			log.Error("Error when creating transactions table: " + err.Error())
 			return errors.New(globals.ErrorDatabaseInitialization)
 		}
-		defer wrapper.Close()
 		// Create the transaction table
 		createTransactionTableQuery := `CREATE TABLE IF NOT EXISTS ` + globals.TransactionsTable + ` (id INTEGER PRIMARY KEY AUTOINCREMENT,Timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,userID INTEGER NOT NULL,actionType TEXT NOT NULL,affectedTable TEXT NOT NULL,recordID INTEGER NOT NULL,oldValues TEXT,newValues TEXT,ipAddress TEXT,status TEXT NOT NULL,errorMessage TEXT)`
 		log.Debug("Creating transactions table with query: " + createTransactionTableQuery + " for database: " + database)
@@ -40,13 +39,13 @@ func createTransactionsTable(database string) error {
 }
 
 func createMetadataTable(database string, databaseVersion int) error {
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Creating metadata table for database: " + database)
 	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	wrapper, err := NewSQLiteWrapper(c.Storage.Path + "/" + database + ".db")
+	wrapper, err := sharedWrapper(database)
 	if err != nil {
 		log.Error("Error when creating metadata table: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
 		return errors.New(globals.ErrorDatabaseInitialization)
@@ -56,7 +55,7 @@ func createMetadataTable(database string, databaseVersion int) error {
 	_, err = wrapper.Execute(query, globals.SystemUserID)
 	if err != nil {
 		log.Error("Error when creating metadata table: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
 		return errors.New(globals.ErrorDatabaseInitialization)
@@ -66,7 +65,7 @@ func createMetadataTable(database string, databaseVersion int) error {
 	_, err = wrapper.Execute(query, globals.SystemUserID, args)
 	if err != nil {
 		log.Error("Error when inserting database version: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
 		return errors.New(globals.ErrorDatabaseInitialization)
@@ -76,32 +75,35 @@ func createMetadataTable(database string, databaseVersion int) error {
 	return nil
 }
 
-func createUsersTable(database string) error {
-	c.GetConfig()
+// createUsersTable creates the users table and seeds the default admin - If the name or password are empty, the configured defaults are used
+// The name of the admin is returned along with the password if it was generated
+func createUsersTable(database, userName, userPassword string) (string, string, error) {
+	c := configuration.Current()
 	log.Debug("Creating users table for database: " + database)
 	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	wrapper, err := NewSQLiteWrapper(c.Storage.Path + "/" + database + ".db")
+	wrapper, err := sharedWrapper(database)
 	if err != nil {
 		log.Error("Error when creating users table: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
-		return errors.New(globals.ErrorDatabaseInitialization)
+		return "", "", errors.New(globals.ErrorDatabaseInitialization)
 	}
 	query := `CREATE TABLE IF NOT EXISTS ` + globals.UsersTable + ` (id TEXT PRIMARY KEY, name TEXT, password TEXT, roles TEXT)`
 	// Create users table
 	_, err = wrapper.Execute(query, globals.SystemUserID)
 	if err != nil {
 		log.Error("Error when creating users table: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
-		return errors.New(globals.ErrorDatabaseInitialization)
+		return "", "", errors.New(globals.ErrorDatabaseInitialization)
 	}
 	log.Debug("Successfully created system users table")
-	var userPassword string
 	var randomPassword bool
-	if os.Getenv(globals.SessionDefaultPasswordEnvironmentVariable) != "" {
+	if userPassword != "" {
+		randomPassword = false
+	} else if os.Getenv(globals.SessionDefaultPasswordEnvironmentVariable) != "" {
 		userPassword = os.Getenv(globals.SessionDefaultPasswordEnvironmentVariable)
 		randomPassword = false
 	} else if c.Session.Default.Password != "" {
@@ -111,8 +113,9 @@ func createUsersTable(database string) error {
 		userPassword = generator.RandomString(globals.UserPasswordLength)
 		randomPassword = true
 	}
-	var userName string
-	if os.Getenv(globals.SessionDefaultUsernameEnvironmentVariable) != "" {
+	if userName != "" {
+		log.Debug("Using provided default user name for database: " + database)
+	} else if os.Getenv(globals.SessionDefaultUsernameEnvironmentVariable) != "" {
 		userName = os.Getenv(globals.SessionDefaultUsernameEnvironmentVariable)
 	} else {
 		userName = c.Session.Default.Name
@@ -121,20 +124,28 @@ func createUsersTable(database string) error {
 	defaultRolesAsJSON, err := json.Marshal(globals.DefaultRoles)
 	if err != nil {
 		log.Error("Error when marshalling default roles: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
-		return errors.New(globals.ErrorDatabaseInitialization)
+		return "", "", errors.New(globals.ErrorDatabaseInitialization)
+	}
+	userPasswordHash, err := password.Hash(userPassword)
+	if err != nil {
+		log.Error("Error when hashing default user password: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database + ") due to failed initialization")
+		}
+		return "", "", errors.New(globals.ErrorDatabaseInitialization)
 	}
 	query = `INSERT INTO ` + globals.UsersTable + ` (id,name, password, roles) VALUES (?,?, ?, ?)`
-	args := []interface{}{userID, userName, userPassword, string(defaultRolesAsJSON)}
+	args := []interface{}{userID, userName, userPasswordHash, string(defaultRolesAsJSON)}
 	_, err = wrapper.Execute(query, globals.SystemUserID, args...)
 	if err != nil {
 		log.Error("Error when inserting default user: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
-		return errors.New(globals.ErrorDatabaseInitialization)
+		return "", "", errors.New(globals.ErrorDatabaseInitialization)
 	}
 	if randomPassword {
 		log.Info("Generated default user: " + userName + " with password: " + userPassword + " for database: " + database)
@@ -142,28 +153,28 @@ func createUsersTable(database string) error {
 		log.Warn("This is the only time these credentials will be displayed")
 	} else {
 		log.Info("Created default user: " + userName + " for database: " + database)
+		userPassword = ""
 	}
-	return nil
+	return userName, userPassword, nil
 }
 
 func createJWTTable(database string) error {
-	c.GetConfig()
+	c := configuration.Current()
 	log.Debug("Creating JWT table for database: " + database)
 	dbFilePath := c.Storage.Path + "/" + database + ".db"
-	wrapper, err := NewSQLiteWrapper(c.Storage.Path + "/" + database + ".db")
+	wrapper, err := sharedWrapper(database)
 	if err != nil {
 		log.Error("Error when creating JWT table: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
 		return errors.New(globals.ErrorDatabaseInitialization)
 	}
-	query := `CREATE TABLE IF NOT EXISTS ` + globals.JWTTable + ` (id TEXT PRIMARY KEY, token TEXT, sha256 TEXt, expiration TEXT)`
 	// Create JWT table
-	_, err = wrapper.Execute(query, globals.SystemUserID)
+	_, err = wrapper.Execute(jwtTableQuery, globals.SystemUserID)
 	if err != nil {
 		log.Error("Error when creating JWT table: " + err.Error())
-		if processor.FileDelete(dbFilePath) {
+		if deleteDatabaseFile(dbFilePath) {
 			log.Warn("Deleted database (" + database + ") due to failed initialization")
 		}
 		return errors.New(globals.ErrorDatabaseInitialization)
@@ -171,3 +182,66 @@ func createJWTTable(database string) error {
 	log.Debug("Successfully created system JWT table")
 	return nil
 }
+
+func createAPIKeysTable(database string) error {
+	c := configuration.Current()
+	log.Debug("Creating API keys table for database: " + database)
+	dbFilePath := c.Storage.Path + "/" + database + ".db"
+	wrapper, err := sharedWrapper(database)
+	if err != nil {
+		log.Error("Error when creating API keys table: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database + ") due to failed initialization")
+		}
+		return errors.New(globals.ErrorDatabaseInitialization)
+	}
+	_, err = wrapper.exec(apiKeysTableQuery)
+	if err != nil {
+		log.Error("Error when creating API keys table: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database + ") due to failed initialization")
+		}
+		return errors.New(globals.ErrorDatabaseInitialization)
+	}
+	log.Debug("Successfully created system API keys table")
+	return nil
+}
+
+func createRolesTable(database string) error {
+	c := configuration.Current()
+	log.Debug("Creating roles table for database: " + database)
+	dbFilePath := c.Storage.Path + "/" + database + ".db"
+	wrapper, err := sharedWrapper(database)
+	if err != nil {
+		log.Error("Error when creating roles table: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database + ") due to failed initialization")
+		}
+		return errors.New(globals.ErrorDatabaseInitialization)
+	}
+	_, err = wrapper.exec(rolesTableQuery)
+	if err != nil {
+		log.Error("Error when creating roles table: " + err.Error())
+		if deleteDatabaseFile(dbFilePath) {
+			log.Warn("Deleted database (" + database + ") due to failed initialization")
+		}
+		return errors.New(globals.ErrorDatabaseInitialization)
+	}
+	log.Debug("Successfully created system roles table")
+	return nil
+}
+
+// upgradeSystemTables brings the system tables of a database which was created by an older version of the server up to date
+// The JWT table is upgraded to sessions and the API keys and roles tables are created if they are missing
+func upgradeSystemTables(wrapper *SQLiteWrapper) error {
+	err := upgradeJWTTable(wrapper)
+	if err != nil {
+		return err
+	}
+	_, err = wrapper.exec(apiKeysTableQuery)
+	if err != nil {
+		return err
+	}
+	_, err = wrapper.exec(rolesTableQuery)
+	return err
+}
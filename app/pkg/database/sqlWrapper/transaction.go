@@ -0,0 +1,387 @@
+package sqlWrapper
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/data"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Transaction groups statements into a single serializable SQLite transaction
+// The statements are recorded in the transactions table once the outcome of the transaction is known
+type Transaction struct {
+	wrapper *SQLiteWrapper
+	conn    *sql.Conn
+	tx      statements
+	userID  string
+	records []transactionRecord
+	done    bool
+	id      string
+}
+
+// transactionRecord holds the details of an executed statement until the transaction is committed or rolled back
+type transactionRecord struct {
+	action    string
+	table     string
+	recordID  string
+	oldValues string
+	newValues string
+	err       error
+}
+
+// statements runs the statements of a transaction - It is implemented by SQL transactions and deferred transactions (See: deferredTx)
+type statements interface {
+	queryer
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+	Commit() error
+	Rollback() error
+}
+
+// Begin starts a new transaction on behalf of the user on the writer connection
+// The connection is waited for for as long as a lock held by another process would be (See: storage.connections.busyTimeout)
+func (wrapper *SQLiteWrapper) Begin(userID string) (*Transaction, error) {
+	return wrapper.begin(userID, false)
+}
+
+// BeginDeferred starts a new transaction like Begin but only takes the write lock of the database file once the transaction first writes
+// It is used by transactions which stay open across requests so that they do not lock out other processes while they only read
+// The transaction still holds the writer connection so the writes of other requests wait for it either way
+func (wrapper *SQLiteWrapper) BeginDeferred(userID string) (*Transaction, error) {
+	return wrapper.begin(userID, true)
+}
+
+// begin pins the writer connection and starts a transaction on it
+func (wrapper *SQLiteWrapper) begin(userID string, deferred bool) (*Transaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), globals.DatabaseBusyTimeout)
+	conn, err := wrapper.db.Conn(ctx)
+	cancel()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, errors.New(globals.ErrorDatabaseBusy + ": timed out waiting for the writer connection of database (" + wrapper.name + ")")
+		}
+		return nil, err
+	}
+	var tx statements
+	if deferred {
+		// Transactions begun through database/sql always use the mode of the connection string (_txlock=immediate)
+		_, err = conn.ExecContext(context.Background(), "BEGIN DEFERRED")
+		tx = deferredTx{conn: conn}
+	} else {
+		tx, err = conn.BeginTx(context.Background(), &sql.TxOptions{
+			Isolation: sql.LevelSerializable,
+		})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Transaction{wrapper: wrapper, conn: conn, tx: tx, userID: userID}, nil
+}
+
+// deferredTx runs the statements of a transaction which was begun on the connection with BEGIN DEFERRED
+type deferredTx struct {
+	conn *sql.Conn
+}
+
+func (tx deferredTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.conn.ExecContext(context.Background(), query, args...)
+}
+
+func (tx deferredTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (tx deferredTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+func (tx deferredTx) Prepare(query string) (*sql.Stmt, error) {
+	return tx.conn.PrepareContext(context.Background(), query)
+}
+
+// Commit commits the transaction and rolls it back if the commit failed so that the connection is not returned to the pool with the transaction still open
+func (tx deferredTx) Commit() error {
+	_, err := tx.conn.ExecContext(context.Background(), "COMMIT")
+	if err != nil {
+		tx.Rollback()
+	}
+	return err
+}
+
+// Rollback rolls back the transaction and discards the connection if that failed so that the transaction does not outlive it
+func (tx deferredTx) Rollback() error {
+	_, err := tx.conn.ExecContext(context.Background(), "ROLLBACK")
+	if err != nil {
+		tx.conn.Raw(func(interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	return err
+}
+
+// exec executes a statement on a system table in its own transaction on the writer connection
+// The statement runs as it is (Its args are not processed) and is not recorded in the transaction log
+func (wrapper *SQLiteWrapper) exec(query string, args ...interface{}) (sql.Result, error) {
+	transaction, err := wrapper.Begin(globals.SystemUserID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := transaction.tx.Exec(query, args...)
+	if err != nil {
+		transaction.Rollback(err)
+		return nil, err
+	}
+	return result, transaction.Commit()
+}
+
+// Track records the final outcome of the transaction under the ID once it is committed or rolled back
+func (transaction *Transaction) Track(transactionID string) {
+	transaction.id = transactionID
+}
+
+// Execute executes a query without returning any rows as part of the transaction
+func (transaction *Transaction) Execute(query string, args ...interface{}) (sql.Result, error) {
+	if transaction.done {
+		return nil, sql.ErrTxDone
+	}
+	stmt, err := transaction.tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var newArgs []interface{}
+	if strings.Contains(query, "DELETE") {
+		for _, arg := range args {
+			switch v := arg.(type) {
+			case []string:
+				for _, str := range v {
+					newArgs = append(newArgs, str)
+				}
+			default:
+				newArgs = append(newArgs, arg)
+			}
+		}
+	} else {
+		for _, arg := range args {
+			switch v := arg.(type) {
+			case []string:
+				for _, str := range v {
+					newArg := data.Process(str)
+					newArgs = append(newArgs, newArg)
+				}
+			default:
+				newArg := data.Process(arg)
+				// SQLite cannot bind slices - store them as JSON
+				if strSlice, ok := newArg.([]string); ok {
+					strSliceJSON, err := json.Marshal(strSlice)
+					if err != nil {
+						return nil, err
+					}
+					newArg = string(strSliceJSON)
+				}
+				newArgs = append(newArgs, newArg)
+			}
+		}
+	}
+
+	var oldValues string
+	if strings.Contains(query, "UPDATE") {
+		oldValues, err = transaction.oldValues(query, newArgs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debug("Executing query: " + query + " with args: " + fmt.Sprintf("%v", newArgs))
+	result, err := stmt.Exec(newArgs...)
+	err = constraintError(err)
+	record := transactionRecord{
+		table:     extractTableName(query),
+		oldValues: oldValues,
+		err:       err,
+	}
+	if record.table == "" {
+		return nil, errors.New(globals.ErrorTransactionTableNameExtraction)
+	}
+	if record.table == globals.TransactionsTable {
+		log.Debug("Transaction table detected - skipping transaction creation")
+		globals.IsTransactionExecution = true
+		return result, err
+	}
+	log.Debug("Transaction table not detected - creating transaction")
+	globals.IsTransactionExecution = false
+	record.recordID = extractRecordID(query, newArgs)
+	if record.recordID == "" && !strings.Contains(query, "CREATE") && !strings.HasPrefix(query, "DROP") && !strings.Contains(query, globals.SystemTablePrefix) {
+		return nil, errors.New(globals.ErrorTransactionRecordIDExtraction)
+	}
+
+	switch {
+	case strings.HasPrefix(query, "DROP"):
+		record.action = "DROP"
+	case strings.HasPrefix(query, "CREATE"):
+		// Table definitions may contain keywords of other actions (Ex: ON DELETE CASCADE)
+		record.action = "CREATE"
+	case strings.Contains(query, "INSERT"):
+		record.action = "INSERT"
+		record.newValues = fmt.Sprintf("%v", newArgs)
+	case strings.Contains(query, "UPDATE"):
+		record.action = "UPDATE"
+		record.newValues = fmt.Sprintf("%v", newArgs)
+	case strings.Contains(query, "DELETE"):
+		record.action = "DELETE"
+		record.newValues = fmt.Sprintf("%v", newArgs)
+	case strings.Contains(query, "SELECT"):
+		record.action = "SELECT"
+	case strings.Contains(query, "CREATE"):
+		record.action = "CREATE"
+	default:
+		log.Error("Unknown action in query: " + strings.Split(query, " ")[0])
+		record.action = "UNKNOWN"
+	}
+	if record.action != "SELECT" && record.action != "UNKNOWN" {
+		transaction.records = append(transaction.records, record)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Query executes a query that returns rows as part of the transaction
+func (transaction *Transaction) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if transaction.done {
+		return nil, sql.ErrTxDone
+	}
+	query, newArgs := prepareQuery(query, args)
+	return transaction.tx.Query(query, newArgs...)
+}
+
+// Commit commits the transaction and records each statement as successful
+func (transaction *Transaction) Commit() error {
+	if transaction.done {
+		return sql.ErrTxDone
+	}
+	transaction.done = true
+	err := transaction.tx.Commit()
+	// The writer connection is released before the statements are recorded as recording them needs it
+	transaction.conn.Close()
+	if err != nil {
+		for _, record := range transaction.records {
+			txErr := createTransaction(transaction.wrapper.name, transaction.userID, record.action, record.table, record.recordID, record.oldValues, record.newValues, "", "ERROR", err)
+			if txErr != nil {
+				log.Error("Error when logging failed execution: " + txErr.Error())
+			}
+		}
+		transaction.recordOutcome(globals.TransactionActionCommit, "ERROR", err)
+		return err
+	}
+	for _, record := range transaction.records {
+		err = createTransaction(transaction.wrapper.name, transaction.userID, record.action, record.table, record.recordID, record.oldValues, record.newValues, "", "SUCCESS", nil)
+		if err != nil {
+			return err
+		}
+	}
+	transaction.recordOutcome(globals.TransactionActionCommit, "SUCCESS", nil)
+	return nil
+}
+
+// Rollback rolls back the transaction and records each statement as rolled back
+// The cause is recorded for statements which did not fail themselves
+func (transaction *Transaction) Rollback(cause error) error {
+	if transaction.done {
+		return sql.ErrTxDone
+	}
+	transaction.done = true
+	err := transaction.tx.Rollback()
+	transaction.conn.Close()
+	for _, record := range transaction.records {
+		recordErr := record.err
+		if recordErr == nil {
+			recordErr = cause
+		}
+		txErr := createTransaction(transaction.wrapper.name, transaction.userID, record.action+"(ROLLBACK)", record.table, record.recordID, record.oldValues, record.newValues, "", "ERROR", recordErr)
+		if txErr != nil {
+			log.Error("Error when logging failed execution: " + txErr.Error())
+		}
+	}
+	transaction.recordOutcome(globals.TransactionActionRollback, "ERROR", cause)
+	return err
+}
+
+// recordOutcome records the outcome of a tracked transaction
+func (transaction *Transaction) recordOutcome(action, status string, cause error) {
+	if transaction.id == "" {
+		return
+	}
+	statements := fmt.Sprint(len(transaction.records)) + " statement(s)"
+	err := createTransaction(transaction.wrapper.name, transaction.userID, action, globals.TransactionsTable, transaction.id, "", statements, "", status, cause)
+	if err != nil {
+		log.Error("Error when logging transaction outcome: " + err.Error())
+	}
+}
+
+// oldValues fetches the current values of the columns which are set by an UPDATE query
+func (transaction *Transaction) oldValues(query string, newArgs []interface{}) (string, error) {
+	oldValueColumns := fetchSetColumns(query)
+	if oldValueColumns == "" {
+		return "", nil
+	}
+
+	// Split the query into SET and WHERE parts
+	setClauseIndex := strings.Index(query, "SET")
+	whereClauseIndex := strings.Index(query, "WHERE")
+
+	// Ensure that both SET and WHERE clauses exist
+	if setClauseIndex == -1 || whereClauseIndex == -1 || whereClauseIndex < setClauseIndex {
+		return "", errors.New("error when creating transaction: SET and WHERE clauses could not be determined or are invalid")
+	}
+	setClause := query[setClauseIndex+len("SET") : whereClauseIndex]
+	whereClause := query[whereClauseIndex+len("WHERE"):]
+
+	// Separate the arguments based on the number of placeholders in each clause
+	setClausePlaceholderCount := strings.Count(setClause, "?")
+	if setClausePlaceholderCount > len(newArgs) {
+		return "", errors.New("error when creating transaction: SET clause has more placeholders than arguments")
+	}
+	whereClauseArgs := newArgs[setClausePlaceholderCount:]
+	log.Debug("Where clause args: " + fmt.Sprintf("%v", whereClauseArgs))
+
+	// Use the columns to fetch the old values
+	oldValuesQuery := "SELECT " + oldValueColumns + " FROM " + extractTableName(query) + " WHERE " + strings.TrimSpace(whereClause)
+	log.Debug("Old values query: " + oldValuesQuery + " with args: " + fmt.Sprintf("%v", whereClauseArgs) + " (arg count: " + fmt.Sprint(len(whereClauseArgs)) + ")")
+
+	// Split the columns by comma and create a slice of interface{} to hold the values
+	columns := strings.Split(oldValueColumns, ",")
+	values := make([]interface{}, len(columns))
+	valuePointers := make([]interface{}, len(columns))
+	for i := range values {
+		valuePointers[i] = &values[i]
+	}
+
+	err := transaction.tx.QueryRow(oldValuesQuery, whereClauseArgs...).Scan(valuePointers...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug("Error creating transaction - It's possible that the old values are empty: " + err.Error())
+			return "", errors.New(globals.ErrorTransactionNoEntry)
+		}
+		log.Error("Error when fetching old values: " + err.Error())
+		return "", err
+	}
+	for i, col := range columns {
+		log.Debugf("Column: %s, Value: %v", col, values[i])
+	}
+
+	// Convert the values to a string representation
+	return fmt.Sprintf("%v", values), nil
+}
@@ -0,0 +1,301 @@
+// transactions holds the client-managed transactions which stay open across requests
+package transactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mitchs-dev/library-go/generator"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/mitchs-dev/simplQL/pkg/database/sqlWrapper"
+	log "github.com/sirupsen/logrus"
+)
+
+// openTransaction is a transaction which is pinned to the writer connection of the database until it is committed, rolled back, or times out
+// While it is open it blocks every write to the database - Writes of other requests wait for it for up to the busy timeout (See: storage.connections.busyTimeout)
+// It is begun in deferred mode so that the database file is only locked for other processes once it first writes
+// The transaction holds the database (See: sqlWrapper.Open) so that it is not closed while the transaction is open
+type openTransaction struct {
+	database    string
+	userID      string
+	wrapper     *sqlWrapper.SQLiteWrapper
+	transaction *sqlWrapper.Transaction
+	lastUsed    time.Time
+	closed      bool
+	// lock serializes the requests which use the transaction
+	lock sync.Mutex
+}
+
+var (
+	openTransactions = map[string]*openTransaction{}
+	// beginning counts the transactions of each database which are being opened and are not in openTransactions yet
+	beginning = map[string]int{}
+	// restoring holds the databases which are being restored - Transactions cannot be opened on them meanwhile
+	restoring            = map[string]bool{}
+	openTransactionsLock sync.Mutex
+	reaperOnce           sync.Once
+)
+
+// Begin opens a new transaction on the database on behalf of the user and returns its ID
+// It fails while the database is being restored (See: Restore)
+func Begin(database, userID string) (string, error) {
+	openTransactionsLock.Lock()
+	if restoring[database] {
+		openTransactionsLock.Unlock()
+		return "", errors.New(globals.ErrorDatabaseBusy + ": database (" + database + ") is being restored")
+	}
+	beginning[database]++
+	openTransactionsLock.Unlock()
+	defer func() {
+		openTransactionsLock.Lock()
+		beginning[database]--
+		if beginning[database] == 0 {
+			delete(beginning, database)
+		}
+		openTransactionsLock.Unlock()
+	}()
+
+	wrapper, err := sqlWrapper.Open(database)
+	if err != nil {
+		return "", err
+	}
+	transaction, err := wrapper.BeginDeferred(userID)
+	if err != nil {
+		wrapper.Release()
+		return "", err
+	}
+	transactionID := globals.TransactionIDPrefix + generator.RandomString(globals.TransactionIDLength)
+	transaction.Track(transactionID)
+
+	openTransactionsLock.Lock()
+	openTransactions[transactionID] = &openTransaction{
+		database:    database,
+		userID:      userID,
+		wrapper:     wrapper,
+		transaction: transaction,
+		lastUsed:    time.Now(),
+	}
+	openTransactionsLock.Unlock()
+	reaperOnce.Do(func() { go reaper() })
+
+	log.Info("Opened transaction (" + transactionID + ") on database: " + database + " for user: " + userID)
+	return transactionID, nil
+}
+
+// Acquire returns the open transaction for the ID along with a function which must be called once the request is done with it
+// Requests using the same transaction are run one at a time
+func Acquire(transactionID, database, userID string) (*sqlWrapper.Transaction, func(), error) {
+	openTx, err := lookup(transactionID, database, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	openTx.lock.Lock()
+	if openTx.closed {
+		openTx.lock.Unlock()
+		return nil, nil, errors.New(globals.ErrorTransactionNotFound)
+	}
+	openTx.lastUsed = time.Now()
+	release := func() {
+		openTx.lastUsed = time.Now()
+		openTx.lock.Unlock()
+	}
+	return openTx.transaction, release, nil
+}
+
+// Commit commits the open transaction for the ID
+func Commit(transactionID, database, userID string) error {
+	openTx, err := remove(transactionID, database, userID)
+	if err != nil {
+		return err
+	}
+	defer openTx.wrapper.Release()
+	log.Info("Committing transaction (" + transactionID + ") on database: " + database)
+	return openTx.transaction.Commit()
+}
+
+// Rollback rolls back the open transaction for the ID
+func Rollback(transactionID, database, userID string, cause error) error {
+	openTx, err := remove(transactionID, database, userID)
+	if err != nil {
+		return err
+	}
+	defer openTx.wrapper.Release()
+	log.Info("Rolling back transaction (" + transactionID + ") on database: " + database)
+	return openTx.transaction.Rollback(cause)
+}
+
+// Abort rolls back an acquired transaction after a request using it failed
+// The caller must still call the release function returned by Acquire
+func Abort(transactionID string, cause error) {
+	openTransactionsLock.Lock()
+	openTx, exists := openTransactions[transactionID]
+	delete(openTransactions, transactionID)
+	openTransactionsLock.Unlock()
+	if !exists {
+		return
+	}
+	openTx.closed = true
+	defer openTx.wrapper.Release()
+	log.Warn("Rolling back transaction (" + transactionID + ") after a failed request: " + cause.Error())
+	err := openTx.transaction.Rollback(cause)
+	if err != nil {
+		log.Error("Failed to roll back transaction (" + transactionID + "): " + err.Error())
+	}
+}
+
+// lookup returns the open transaction if it belongs to the database and user
+func lookup(transactionID, database, userID string) (*openTransaction, error) {
+	openTransactionsLock.Lock()
+	defer openTransactionsLock.Unlock()
+	openTx, exists := openTransactions[transactionID]
+	if !exists || openTx.database != database || openTx.userID != userID {
+		return nil, errors.New(globals.ErrorTransactionNotFound)
+	}
+	return openTx, nil
+}
+
+// remove unregisters the open transaction and waits for any request which is using it
+func remove(transactionID, database, userID string) (*openTransaction, error) {
+	openTransactionsLock.Lock()
+	openTx, exists := openTransactions[transactionID]
+	if !exists || openTx.database != database || openTx.userID != userID {
+		openTransactionsLock.Unlock()
+		return nil, errors.New(globals.ErrorTransactionNotFound)
+	}
+	delete(openTransactions, transactionID)
+	openTransactionsLock.Unlock()
+
+	openTx.lock.Lock()
+	defer openTx.lock.Unlock()
+	if openTx.closed {
+		return nil, errors.New(globals.ErrorTransactionNotFound)
+	}
+	openTx.closed = true
+	return openTx, nil
+}
+
+// reaper rolls back transactions which have been idle for longer than the idle timeout
+// It checks often enough that writes waiting for an idle transaction are not timed out before it is rolled back
+func reaper() {
+	interval := globals.TransactionIdleTimeout / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		openTransactionsLock.Lock()
+		var expired []string
+		for transactionID, openTx := range openTransactions {
+			// Transactions which are in use by a request are not idle
+			if !openTx.lock.TryLock() {
+				continue
+			}
+			if time.Since(openTx.lastUsed) > globals.TransactionIdleTimeout {
+				expired = append(expired, transactionID)
+			}
+			openTx.lock.Unlock()
+		}
+		openTransactionsLock.Unlock()
+
+		for _, transactionID := range expired {
+			openTransactionsLock.Lock()
+			openTx, exists := openTransactions[transactionID]
+			openTransactionsLock.Unlock()
+			if !exists {
+				continue
+			}
+			log.Warn("Transaction (" + transactionID + ") has been idle for longer than " + globals.TransactionIdleTimeout.String() + " - Rolling back")
+			err := Rollback(transactionID, openTx.database, openTx.userID, errors.New(globals.ErrorTransactionTimedOut))
+			if err != nil && err.Error() != globals.ErrorTransactionNotFound {
+				log.Error("Failed to roll back idle transaction (" + transactionID + "): " + err.Error())
+			}
+		}
+	}
+}
+
+// count returns the number of open transactions on the database including those which are being opened
+// The caller must hold openTransactionsLock
+func count(database string) int {
+	count := beginning[database]
+	for _, openTx := range openTransactions {
+		if openTx.database == database {
+			count++
+		}
+	}
+	return count
+}
+
+// Restore runs the restore of the database if no transaction is open on it
+// Transactions cannot be opened on the database until the restore is done since they would hold a connection to the database which is being replaced
+func Restore(database string, restore func() error) error {
+	openTransactionsLock.Lock()
+	if restoring[database] {
+		openTransactionsLock.Unlock()
+		return errors.New(globals.ErrorDatabaseBusy + ": database (" + database + ") is already being restored")
+	}
+	openCount := count(database)
+	if openCount > 0 {
+		openTransactionsLock.Unlock()
+		return errors.New(globals.ErrorDatabaseBusy + ": " + fmt.Sprint(openCount) + " open transaction(s) must be committed or rolled back first")
+	}
+	restoring[database] = true
+	openTransactionsLock.Unlock()
+	defer func() {
+		openTransactionsLock.Lock()
+		delete(restoring, database)
+		openTransactionsLock.Unlock()
+	}()
+	return restore()
+}
+
+// RollbackAll rolls back every open transaction with the cause - It is called on shutdown once requests are drained
+// Transactions which are still in use by a request when the context is done are left open and counted as remaining
+func RollbackAll(ctx context.Context, cause error) (rolledBack, remaining int) {
+	openTransactionsLock.Lock()
+	transactionIDs := make([]string, 0, len(openTransactions))
+	for transactionID := range openTransactions {
+		transactionIDs = append(transactionIDs, transactionID)
+	}
+	openTransactionsLock.Unlock()
+
+	for _, transactionID := range transactionIDs {
+		openTransactionsLock.Lock()
+		openTx, exists := openTransactions[transactionID]
+		openTransactionsLock.Unlock()
+		if !exists {
+			continue
+		}
+		// Wait for the request which is using the transaction without blocking past the context
+		if !openTx.lockBefore(ctx) {
+			log.Warn("Transaction (" + transactionID + ") is still in use - Leaving it open")
+			remaining++
+			continue
+		}
+		openTx.lock.Unlock()
+		err := Rollback(transactionID, openTx.database, openTx.userID, cause)
+		if err != nil && err.Error() != globals.ErrorTransactionNotFound {
+			log.Error("Failed to roll back transaction (" + transactionID + "): " + err.Error())
+			continue
+		}
+		if err == nil {
+			rolledBack++
+		}
+	}
+	return rolledBack, remaining
+}
+
+// lockBefore locks the transaction unless the context is done first
+func (openTx *openTransaction) lockBefore(ctx context.Context) bool {
+	for !openTx.lock.TryLock() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return true
+}
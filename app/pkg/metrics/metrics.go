@@ -0,0 +1,159 @@
+// metrics holds the Prometheus metrics which are exposed by the system/metrics endpoint
+package metrics
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/configuration"
+	"github.com/mitchs-dev/simplQL/pkg/configurationAndInitialization/globals"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// registry is used instead of the default registry so that only the metrics of this package are exposed
+var registry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "requests_total",
+		Help:      "Number of requests handled by category-action and status code",
+	}, []string{"category", "action", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of requests by category-action",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"category", "action"})
+	authFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "auth_failures_total",
+		Help:      "Number of failed authentications by reason",
+	}, []string{"reason"})
+	sqliteQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "sqlite_query_duration_seconds",
+		Help:      "Duration of SQLite executions and queries by database",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"database", "operation"})
+	transactionLogWriteFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "transaction_log_write_failures_total",
+		Help:      "Number of entries which could not be written to the transactions table by database",
+	}, []string{"database"})
+	configReloadsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "config_reloads_total",
+		Help:      "Number of configuration reloads by result (applied, rejected)",
+	}, []string{"result"})
+	sessionsPurgedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: globals.MetricsNamespace,
+		Name:      "sessions_purged_total",
+		Help:      "Number of sessions deleted by the session purge by database and reason (expired, max_lifetime)",
+	}, []string{"database", "reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		requestsTotal,
+		requestDuration,
+		authFailuresTotal,
+		sqliteQueryDuration,
+		transactionLogWriteFailuresTotal,
+		configReloadsTotal,
+		sessionsPurgedTotal,
+		databaseSizeCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns the handler which serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorLog: log.StandardLogger()})
+}
+
+// ObserveRequest records a request which started at the given time once it has been handled
+// Requests which did not match the request schema are recorded with the category and action set to globals.MetricsLabelUnknown
+func ObserveRequest(category, action string, code int, start time.Time) {
+	requestsTotal.WithLabelValues(category, action, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues(category, action).Observe(time.Since(start).Seconds())
+}
+
+// AuthFailure records a failed authentication
+func AuthFailure(reason string) {
+	authFailuresTotal.WithLabelValues(reason).Inc()
+}
+
+// ObserveQuery records the duration of an execution or query on the database which started at the given time
+func ObserveQuery(database, operation string, start time.Time) {
+	sqliteQueryDuration.WithLabelValues(database, operation).Observe(time.Since(start).Seconds())
+}
+
+// TransactionLogWriteFailure records an entry which could not be written to the transactions table
+func TransactionLogWriteFailure(database string) {
+	transactionLogWriteFailuresTotal.WithLabelValues(database).Inc()
+}
+
+// ConfigReload records a reload of the configuration with its result (See: globals.MetricsReloadApplied and globals.MetricsReloadRejected)
+func ConfigReload(result string) {
+	configReloadsTotal.WithLabelValues(result).Inc()
+}
+
+// SessionsPurged records sessions which were deleted by the session purge (See: globals.SessionPurgeReasonExpired and globals.SessionPurgeReasonMaxLifetime)
+func SessionsPurged(database, reason string, count int64) {
+	sessionsPurgedTotal.WithLabelValues(database, reason).Add(float64(count))
+}
+
+// databaseSizeCollector reports the size of each database file and its WAL file when the metrics are scraped
+type databaseSizeCollector struct{}
+
+var (
+	databaseFileSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(globals.MetricsNamespace, "", "database_file_size_bytes"),
+		"Size of the database file",
+		[]string{"database"}, nil,
+	)
+	databaseWALSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(globals.MetricsNamespace, "", "database_wal_size_bytes"),
+		"Size of the write-ahead log file of the database",
+		[]string{"database"}, nil,
+	)
+)
+
+func (databaseSizeCollector) Describe(descriptions chan<- *prometheus.Desc) {
+	descriptions <- databaseFileSizeDesc
+	descriptions <- databaseWALSizeDesc
+}
+
+func (databaseSizeCollector) Collect(metrics chan<- prometheus.Metric) {
+	c := configuration.Current()
+	databaseFiles, err := filepath.Glob(filepath.Join(c.Storage.Path, "*.db"))
+	if err != nil {
+		log.Error("Failed to list database files for metrics: " + err.Error())
+		return
+	}
+	for _, databaseFile := range databaseFiles {
+		database := strings.TrimSuffix(filepath.Base(databaseFile), ".db")
+		fileInfo, err := os.Stat(databaseFile)
+		if err != nil {
+			log.Warn("Failed to read the size of database (" + database + "): " + err.Error())
+			continue
+		}
+		metrics <- prometheus.MustNewConstMetric(databaseFileSizeDesc, prometheus.GaugeValue, float64(fileInfo.Size()), database)
+		// The WAL file only exists while a connection to the database is open
+		var walSize int64
+		walInfo, err := os.Stat(databaseFile + "-wal")
+		if err == nil {
+			walSize = walInfo.Size()
+		}
+		metrics <- prometheus.MustNewConstMetric(databaseWALSizeDesc, prometheus.GaugeValue, float64(walSize), database)
+	}
+}